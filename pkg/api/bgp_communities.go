@@ -2,6 +2,8 @@ package api
 
 import (
 	"fmt"
+	"log"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -106,6 +108,17 @@ func (c BGPCommunityMap) Lookup(community string) (string, error) {
 	return label, nil
 }
 
+// containsLabel checks if the label of the community
+// contains the lower case text. Communities without
+// a label do not match.
+func (c BGPCommunityMap) containsLabel(community, text string) bool {
+	label, err := c.Lookup(community)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(label), text)
+}
+
 // Set assignes a label to a community
 func (c BGPCommunityMap) Set(community string, label string) {
 	path := strings.Split(community, ":")
@@ -174,6 +187,19 @@ func (c BGPCommunityMap) Communities() Communities {
 	return communities
 }
 
+// CommunityAliases map short names to communities,
+// e.g. bh = 65535:666
+type CommunityAliases map[string]string
+
+// Expand replaces an alias with its community.
+// Text not matching an alias is returned unchanged.
+func (a CommunityAliases) Expand(text string) string {
+	if community, ok := a[strings.TrimSpace(text)]; ok {
+		return community
+	}
+	return text
+}
+
 // BGPCommunity types: Standard, Extended and Large
 const (
 	BGPCommunityTypeStd = iota
@@ -196,10 +222,307 @@ func (c BGPCommunityRange) Type() int {
 	return BGPCommunityTypeLarge
 }
 
+// String renders the range, e.g. 65000:100-200
+func (c BGPCommunityRange) String() string {
+	parts := make([]string, 0, len(c))
+	for _, r := range c {
+		parts = append(parts, rangeString(r))
+	}
+	return strings.Join(parts, ":")
+}
+
+// rangeString renders a range tuple as min-max or
+// as single value if min and max are equal.
+func rangeString(r any) string {
+	var bounds []string
+	switch v := r.(type) {
+	case []int:
+		for _, b := range v {
+			bounds = append(bounds, strconv.Itoa(b))
+		}
+	case []any:
+		for _, b := range v {
+			bounds = append(bounds, fmt.Sprint(b))
+		}
+	case []string:
+		bounds = v
+	}
+	if len(bounds) != 2 {
+		return ""
+	}
+	if bounds[0] == bounds[1] {
+		return bounds[0]
+	}
+	return bounds[0] + "-" + bounds[1]
+}
+
 // A BGPCommunitiesSet is a set of communities, large and extended.
 // The communities are described as ranges.
 type BGPCommunitiesSet struct {
 	Standard []BGPCommunityRange `json:"standard"`
 	Extended []BGPCommunityRange `json:"extended"`
 	Large    []BGPCommunityRange `json:"large"`
+
+	// Labels are the configured community labels
+	// used by Label.
+	Labels BGPCommunityMap `json:"-"`
+}
+
+// MaxCommunityRangeExpansion limits the number of
+// communities a range is expanded into.
+const MaxCommunityRangeExpansion = 1024
+
+// rangeValues enumerates the values of a range tuple.
+// Extended community types are only expanded if both
+// bounds are the same.
+func rangeValues(r any) ([]string, bool) {
+	var min, max int
+	switch bounds := r.(type) {
+	case []string:
+		if len(bounds) != 2 || bounds[0] != bounds[1] {
+			return nil, false
+		}
+		return []string{bounds[0]}, true
+	case []int:
+		if len(bounds) != 2 {
+			return nil, false
+		}
+		min, max = bounds[0], bounds[1]
+	case []any:
+		if len(bounds) != 2 {
+			return nil, false
+		}
+		var okMin, okMax bool
+		min, okMin = bounds[0].(int)
+		max, okMax = bounds[1].(int)
+		if !okMin || !okMax {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	if max < min {
+		return nil, false
+	}
+	values := make([]string, 0, max-min+1)
+	for v := min; v <= max; v++ {
+		values = append(values, strconv.Itoa(v))
+	}
+	return values, true
+}
+
+// rangeSize is the number of values of a range tuple.
+func rangeSize(r any) int {
+	switch bounds := r.(type) {
+	case []string:
+		return 1
+	case []int:
+		if len(bounds) == 2 {
+			return bounds[1] - bounds[0] + 1
+		}
+	case []any:
+		if len(bounds) == 2 {
+			min, _ := bounds[0].(int)
+			max, _ := bounds[1].(int)
+			return max - min + 1
+		}
+	}
+	return 0
+}
+
+// Expand enumerates all communities within the range,
+// e.g. 65000:100-102 is expanded into 65000:100,
+// 65000:101 and 65000:102.
+// If the range contains more than limit communities,
+// it is not expanded and false is returned.
+func (c BGPCommunityRange) Expand(limit int) ([]string, bool) {
+	total := 1
+	for _, r := range c {
+		total *= max(rangeSize(r), 0)
+		if total > limit {
+			return nil, false
+		}
+	}
+	communities := []string{""}
+	for i, r := range c {
+		values, ok := rangeValues(r)
+		if !ok {
+			return nil, false
+		}
+		expanded := make([]string, 0, len(communities)*len(values))
+		for _, prefix := range communities {
+			for _, v := range values {
+				if i > 0 {
+					v = prefix + ":" + v
+				}
+				expanded = append(expanded, v)
+			}
+		}
+		communities = expanded
+	}
+	return communities, true
+}
+
+// clone makes a deep copy of the communities map.
+func (c BGPCommunityMap) clone() BGPCommunityMap {
+	m := make(BGPCommunityMap, len(c))
+	for k, v := range c {
+		if sub, ok := v.(BGPCommunityMap); ok {
+			v = sub.clone()
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// ExpandLabels returns a copy of the labels, where each
+// community within the ranges of the set is added with its
+// label. This way, labels can be resolved without
+// understanding ranges or wildcards.
+// Ranges with more than limit communities are skipped.
+func (s *BGPCommunitiesSet) ExpandLabels(
+	labels BGPCommunityMap,
+	limit int,
+) BGPCommunityMap {
+	expanded := labels.clone()
+	ranges := slices.Concat(s.Standard, s.Extended, s.Large)
+	for _, r := range ranges {
+		communities, ok := r.Expand(limit)
+		if !ok {
+			log.Println(
+				"Not expanding community range, too large or invalid:", r)
+			continue
+		}
+		for _, community := range communities {
+			label, err := labels.Lookup(community)
+			if err != nil {
+				continue
+			}
+			expanded.Set(community, label)
+		}
+	}
+	return expanded
+}
+
+// rangeContains checks if a value is within the bounds
+// of a range tuple. Tuples are lists of two ints.
+func rangeContains(r any, value int) bool {
+	switch bounds := r.(type) {
+	case []int:
+		return len(bounds) == 2 &&
+			value >= bounds[0] && value <= bounds[1]
+	case []any:
+		if len(bounds) != 2 {
+			return false
+		}
+		min, okMin := bounds[0].(int)
+		max, okMax := bounds[1].(int)
+		return okMin && okMax && value >= min && value <= max
+	}
+	return false
+}
+
+// Matches checks if a standard or large community
+// is within the range, including the bounds.
+func (c BGPCommunityRange) Matches(community Community) bool {
+	if len(c) != len(community) {
+		return false
+	}
+	for i, v := range community {
+		if !rangeContains(c[i], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesExt checks if an extended community
+// is within the range, including the bounds.
+func (c BGPCommunityRange) MatchesExt(community ExtCommunity) bool {
+	if len(c) != 3 || len(community) != 3 {
+		return false
+	}
+	kind, ok := c[0].([]string)
+	if !ok || len(kind) == 0 || kind[0] != community[0] {
+		return false
+	}
+	for i := 1; i < 3; i++ {
+		v, ok := community[i].(int)
+		if !ok || !rangeContains(c[i], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches checks if the community is within any
+// range of the set. Communities with two components
+// are matched against the standard, with three against
+// the large community ranges.
+func (s *BGPCommunitiesSet) Matches(community Community) bool {
+	var ranges []BGPCommunityRange
+	switch len(community) {
+	case 2:
+		ranges = s.Standard
+	case 3:
+		ranges = s.Large
+	}
+	for _, r := range ranges {
+		if r.Matches(community) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesExt checks if the extended community is
+// within any extended community range of the set.
+func (s *BGPCommunitiesSet) MatchesExt(community ExtCommunity) bool {
+	for _, r := range s.Extended {
+		if r.MatchesExt(community) {
+			return true
+		}
+	}
+	return false
+}
+
+// Label looks up the configured label of a Community or
+// ExtCommunity in the labels of the set, which may
+// contain wildcards. If there is no label for the
+// community, the labels of the ranges of the set
+// containing the community are used, e.g. a label
+// configured for 65000:100-200.
+// If no label is found, false is returned.
+func (s *BGPCommunitiesSet) Label(c any) (string, bool) {
+	var (
+		key    string
+		ranges []BGPCommunityRange
+	)
+	switch community := c.(type) {
+	case Community:
+		key = community.String()
+		for _, r := range slices.Concat(s.Standard, s.Large) {
+			if r.Matches(community) {
+				ranges = append(ranges, r)
+			}
+		}
+	case ExtCommunity:
+		key = community.String()
+		for _, r := range s.Extended {
+			if r.MatchesExt(community) {
+				ranges = append(ranges, r)
+			}
+		}
+	default:
+		return "", false
+	}
+	if label, err := s.Labels.Lookup(key); err == nil {
+		return label, true
+	}
+	for _, r := range ranges {
+		if label, err := s.Labels.Lookup(r.String()); err == nil {
+			return label, true
+		}
+	}
+	return "", false
 }
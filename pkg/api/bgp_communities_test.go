@@ -1,6 +1,7 @@
 package api
 
 import (
+	"slices"
 	"testing"
 )
 
@@ -90,3 +91,177 @@ func TestAPICommunities(t *testing.T) {
 		t.Error("unexpected len(communities) = ", len(comm))
 	}
 }
+
+func TestBGPCommunityRangeMatches(t *testing.T) {
+	r := BGPCommunityRange{[]int{2342, 2342}, []int{100, 200}}
+	tests := []struct {
+		community Community
+		match     bool
+	}{
+		{Community{2342, 99}, false},
+		{Community{2342, 100}, true},
+		{Community{2342, 150}, true},
+		{Community{2342, 200}, true},
+		{Community{2342, 201}, false},
+		{Community{2341, 150}, false},
+		{Community{2342, 150, 1}, false},
+	}
+	for _, tt := range tests {
+		if r.Matches(tt.community) != tt.match {
+			t.Error("unexpected match for", tt.community)
+		}
+	}
+}
+
+func TestBGPCommunitiesSetMatches(t *testing.T) {
+	set := &BGPCommunitiesSet{
+		Standard: []BGPCommunityRange{
+			{[]any{65535, 65535}, []any{666, 666}},
+		},
+		Large: []BGPCommunityRange{
+			{[]int{2342, 2342}, []int{65530, 65535}, []int{665, 667}},
+		},
+		Extended: []BGPCommunityRange{
+			{[]string{"rt", "rt"}, []int{1324, 1324}, []int{4200000000, 4200010000}},
+		},
+	}
+
+	tests := []struct {
+		community Community
+		match     bool
+	}{
+		{Community{65535, 666}, true},
+		{Community{65535, 667}, false},
+		{Community{2342, 65530, 665}, true},
+		{Community{2342, 65535, 667}, true},
+		{Community{2342, 65529, 665}, false},
+		{Community{2342, 65535, 668}, false},
+		{Community{65535}, false},
+	}
+	for _, tt := range tests {
+		if set.Matches(tt.community) != tt.match {
+			t.Error("unexpected match for", tt.community)
+		}
+	}
+
+	extTests := []struct {
+		community ExtCommunity
+		match     bool
+	}{
+		{ExtCommunity{"rt", 1324, 4200000000}, true},
+		{ExtCommunity{"rt", 1324, 4200010000}, true},
+		{ExtCommunity{"rt", 1324, 4200010001}, false},
+		{ExtCommunity{"rt", 1324, 4199999999}, false},
+		{ExtCommunity{"ro", 1324, 4200000000}, false},
+	}
+	for _, tt := range extTests {
+		if set.MatchesExt(tt.community) != tt.match {
+			t.Error("unexpected match for", tt.community)
+		}
+	}
+}
+
+func TestBGPCommunitiesSetLabel(t *testing.T) {
+	labels := BGPCommunityMap{}
+	labels.Set("65000:100", "customer")
+	labels.Set("65000:*", "transit")
+	labels.Set("65000:1:2", "large")
+	labels.Set("rt:65000:100", "route target")
+	labels.Set("65002:100", "prepend once")
+	labels.Set("65003:1-10", "no export to peers")
+
+	set := &BGPCommunitiesSet{
+		Labels: labels,
+		Standard: []BGPCommunityRange{
+			{[]int{65000, 65000}, []int{100, 200}},
+			{[]int{65001, 65001}, []int{1, 1}},
+			{[]int{65003, 65003}, []int{1, 10}},
+		},
+		Large: []BGPCommunityRange{
+			{[]int{65000, 65000}, []int{1, 1}, []int{2, 2}},
+		},
+		Extended: []BGPCommunityRange{
+			{[]string{"rt", "rt"}, []int{65000, 65000}, []int{100, 100}},
+		},
+	}
+
+	tests := []struct {
+		community any
+		label     string
+		ok        bool
+	}{
+		{Community{65000, 100}, "customer", true},
+		{Community{65002, 100}, "prepend once", true},     // not in set
+		{Community{65000, 300}, "transit", true},          // wildcard
+		{Community{65003, 5}, "no export to peers", true}, // ranged
+		{Community{65003, 11}, "", false},                 // miss
+		{Community{65001, 1}, "", false},                  // no label
+		{Community{65000, 1, 2}, "large", true},           // large
+		{ExtCommunity{"rt", 65000, 100}, "route target", true},
+		{ExtCommunity{"ro", 65000, 100}, "", false}, // miss
+		{"65000:100", "", false},                    // unsupported
+	}
+	for _, test := range tests {
+		label, ok := set.Label(test.community)
+		if label != test.label || ok != test.ok {
+			t.Error("unexpected label for", test.community, label, ok)
+		}
+	}
+}
+
+func TestBGPCommunityRangeExpand(t *testing.T) {
+	r := BGPCommunityRange{[]int{65000, 65000}, []int{100, 102}}
+	communities, ok := r.Expand(MaxCommunityRangeExpansion)
+	if !ok {
+		t.Fatal("expected range to be expanded")
+	}
+	expected := []string{"65000:100", "65000:101", "65000:102"}
+	if !slices.Equal(communities, expected) {
+		t.Error("unexpected communities:", communities)
+	}
+
+	r = BGPCommunityRange{
+		[]string{"rt", "rt"}, []int{65000, 65000}, []int{1, 2}}
+	communities, ok = r.Expand(MaxCommunityRangeExpansion)
+	if !ok || !slices.Equal(communities, []string{"rt:65000:1", "rt:65000:2"}) {
+		t.Error("unexpected communities:", communities)
+	}
+
+	// Over the limit
+	r = BGPCommunityRange{[]int{65000, 65000}, []int{0, 65535}}
+	if _, ok := r.Expand(MaxCommunityRangeExpansion); ok {
+		t.Error("expected range to exceed the limit")
+	}
+	r = BGPCommunityRange{
+		[]int{0, 4294967295}, []int{0, 4294967295}, []int{0, 4294967295}}
+	if _, ok := r.Expand(MaxCommunityRangeExpansion); ok {
+		t.Error("expected large range to exceed the limit")
+	}
+}
+
+func TestBGPCommunitiesSetExpandLabels(t *testing.T) {
+	labels := BGPCommunityMap{}
+	labels.Set("65000:*", "blackhole")
+	labels.Set("65001:*", "other")
+
+	set := &BGPCommunitiesSet{
+		Standard: []BGPCommunityRange{
+			{[]int{65000, 65000}, []int{666, 667}},
+			{[]int{65001, 65001}, []int{0, 65535}}, // over the limit
+		},
+	}
+	expanded := set.ExpandLabels(labels, MaxCommunityRangeExpansion)
+
+	comms := expanded["65000"].(BGPCommunityMap)
+	if comms["666"] != "blackhole" || comms["667"] != "blackhole" {
+		t.Error("unexpected expanded labels:", comms)
+	}
+	if len(expanded["65001"].(BGPCommunityMap)) != 1 {
+		t.Error("range over the limit should not be expanded")
+	}
+
+	// The original labels are not modified
+	if len(labels["65000"].(BGPCommunityMap)) != 1 {
+		t.Error("labels should not be modified:", labels)
+	}
+}
@@ -5,3 +5,7 @@ import "errors"
 // ErrTooManyRoutes is returned when the result set
 // of a route query exceeds the maximum allowed number of routes.
 var ErrTooManyRoutes = errors.New("too many routes")
+
+// ErrInvalidCommunity is returned when a BGP community
+// can not be parsed.
+var ErrInvalidCommunity = errors.New("invalid community")
@@ -1,7 +1,11 @@
 package api
 
 import (
+	"fmt"
+	"net"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -51,6 +55,28 @@ type ConfigResponse struct {
 	PrefixLookupEnabled bool `json:"prefix_lookup_enabled"`
 }
 
+// RejectReason looks up the reason for rejecting a route
+// with a standard or large community. Wildcards in the
+// reasons are resolved. If there is no reason for the
+// community, false is returned.
+func (c *ConfigResponse) RejectReason(com Community) (string, bool) {
+	return c.lookupRejectReason(com.String())
+}
+
+// RejectReasonExt looks up the reason for rejecting a
+// route with an extended community. See RejectReason.
+func (c *ConfigResponse) RejectReasonExt(com ExtCommunity) (string, bool) {
+	return c.lookupRejectReason(com.String())
+}
+
+func (c *ConfigResponse) lookupRejectReason(key string) (string, bool) {
+	reason, err := BGPCommunityMap(c.RejectReasons).Lookup(key)
+	if err != nil {
+		return "", false
+	}
+	return reason, true
+}
+
 // Noexport options
 type Noexport struct {
 	LoadOnDemand bool `json:"load_on_demand"`
@@ -87,13 +113,70 @@ type CacheStatus struct {
 	OrigTTL  int       `json:"orig_ttl"`
 }
 
+// Expires returns the point in time when the response
+// becomes stale. If no TTL is set, the expiry is derived
+// from the cache status, with the OrigTTL in seconds.
+// A zero time is returned when no TTL is known.
+func (m *Meta) Expires() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+	if !m.TTL.IsZero() {
+		return m.TTL
+	}
+	if m.CacheStatus.OrigTTL > 0 && !m.CacheStatus.CachedAt.IsZero() {
+		ttl := time.Duration(m.CacheStatus.OrigTTL) * time.Second
+		return m.CacheStatus.CachedAt.Add(ttl)
+	}
+	return time.Time{}
+}
+
+// IsExpired checks if the response is stale at the
+// given time. Responses without a TTL are always expired.
+func (m *Meta) IsExpired(now time.Time) bool {
+	expires := m.Expires()
+	if expires.IsZero() {
+		return true
+	}
+	return !now.Before(expires)
+}
+
+// cacheTTL returns the remaining validity at the given time.
+// The duration is negative if the response is expired.
+func (m *Meta) cacheTTL(now time.Time) time.Duration {
+	expires := m.Expires()
+	if expires.IsZero() {
+		return -1
+	}
+	return expires.Sub(now)
+}
+
+// CacheTTL returns the remaining validity of the
+// response, based on the meta information.
+func (res *Response) CacheTTL() time.Duration {
+	return res.Meta.cacheTTL(time.Now().UTC())
+}
+
+// SourceState is the state of a source in a store.
+type SourceState string
+
+// Source states as reported by the stores
+const (
+	SourceStateInit  SourceState = "INIT"
+	SourceStateReady SourceState = "READY"
+	SourceStateBusy  SourceState = "BUSY"
+	SourceStateError SourceState = "ERROR"
+)
+
 // SourceStatus is the current status of a source in
 // a store.
 type SourceStatus struct {
 	RefreshInterval time.Duration `json:"refresh_interval"`
 	LastRefresh     time.Time     `json:"last_refresh"`
-	State           string        `json:"state"`
+	State           SourceState   `json:"state"`
 	Initialized     bool          `json:"initialized"`
+	LastError       string        `json:"last_error,omitempty"`
+	ErrorCount      int           `json:"error_count,omitempty"`
 }
 
 // StoreStatus is meta data for a store
@@ -102,12 +185,100 @@ type StoreStatus struct {
 	Sources     map[string]*SourceStatus `json:"sources"`
 }
 
+// OverallState rolls up the states of all sources
+// into a single state. An error in any source takes
+// precedence over sources not yet initialized, which
+// in turn take precedence over busy sources.
+// A store without sources is considered initializing.
+func (s *StoreStatus) OverallState() SourceState {
+	if len(s.Sources) == 0 {
+		return SourceStateInit
+	}
+	hasInit := false
+	hasBusy := false
+	for _, src := range s.Sources {
+		switch {
+		case src.State == SourceStateError:
+			return SourceStateError
+		case !src.Initialized || src.State == SourceStateInit:
+			hasInit = true
+		case src.State == SourceStateBusy:
+			hasBusy = true
+		}
+	}
+	if hasInit {
+		return SourceStateInit
+	}
+	if hasBusy {
+		return SourceStateBusy
+	}
+	return SourceStateReady
+}
+
+// IsReady checks if all sources are initialized and
+// none of them is in an error state. Busy sources
+// are refreshing but still provide data.
+func (s *StoreStatus) IsReady() bool {
+	state := s.OverallState()
+	return state == SourceStateReady || state == SourceStateBusy
+}
+
+// isInitialized checks if the store and all of
+// its sources were refreshed at least once.
+func (s *StoreStatus) isInitialized() bool {
+	if s == nil || !s.Initialized {
+		return false
+	}
+	for _, src := range s.Sources {
+		if !src.Initialized {
+			return false
+		}
+	}
+	return true
+}
+
+// StaleSources returns the sorted IDs of all sources
+// which were not refreshed within their refresh interval
+// plus the grace period maxAge. Sources which were never
+// refreshed are considered stale.
+func (s *StoreStatus) StaleSources(now time.Time, maxAge time.Duration) []string {
+	stale := []string{}
+	for id, src := range s.Sources {
+		if src.LastRefresh.IsZero() {
+			stale = append(stale, id)
+			continue
+		}
+		deadline := src.LastRefresh.Add(src.RefreshInterval + maxAge)
+		if now.After(deadline) {
+			stale = append(stale, id)
+		}
+	}
+	slices.Sort(stale)
+	return stale
+}
+
 // StoreStatusMeta is the meta response for all stores
 type StoreStatusMeta struct {
 	Routes    *StoreStatus `json:"routes,omitempty"`
 	Neighbors *StoreStatus `json:"neighbors,omitempty"`
 }
 
+// AllInitialized checks if all stores present in the
+// meta are initialized. A meta without any store status
+// is not initialized.
+func (m *StoreStatusMeta) AllInitialized() bool {
+	if m.Routes == nil && m.Neighbors == nil {
+		return false
+	}
+	if m.Routes != nil && !m.Routes.isInitialized() {
+		return false
+	}
+	if m.Neighbors != nil && !m.Neighbors.isInitialized() {
+		return false
+	}
+	return true
+}
+
 // Status ... TODO: ?
 type Status struct {
 	ServerTime   time.Time `json:"server_time"`
@@ -162,6 +333,12 @@ type RouteServersResponse struct {
 type LookupRouteServer struct {
 	ID   *string `json:"id"`
 	Name string  `json:"name"`
+
+	// SelfAddresses are the addresses of the route server
+	SelfAddresses []net.IP `json:"self_addresses,omitempty"`
+
+	// Blackholes are the blackhole next hop addresses
+	Blackholes []net.IP `json:"blackholes,omitempty"`
 }
 
 // Community is a BGP community
@@ -171,23 +348,46 @@ func (com Community) String() string {
 	if len(com) < 1 {
 		return ""
 	}
-	s := ""
+	return string(com.appendString(make([]byte, 0, 8*len(com))))
+}
+
+// appendString appends the string representation
+// of the community to the buffer.
+func (com Community) appendString(buf []byte) []byte {
 	for i, v := range com {
 		if i > 0 {
-			s += ":"
+			buf = append(buf, ':')
+		}
+		if v == CommunityWildcard {
+			buf = append(buf, '*')
+			continue
 		}
-		s += strconv.Itoa(v)
+		buf = strconv.AppendInt(buf, int64(v), 10)
 	}
-	return s
+	return buf
+}
+
+// OTCSet matches any only to customer (OTC)
+// attribute. In a query it is written as OTCValueSet.
+const OTCSet = -1
+
+// OTCValueSet is the query value for OTCSet
+const OTCValueSet = "set"
+
+// CommunityWildcard is a community component
+// matching any value, e.g. 65000:*
+const CommunityWildcard = -1
+
+// hasWildcard checks if any component of the
+// community is a wildcard.
+func (com Community) hasWildcard() bool {
+	return slices.Contains(com, CommunityWildcard)
 }
 
 // Communities is a collection of bgp communities
 type Communities []Community
 
 // Unique deduplicates communities.
-/*
-We can skip this. Worst case is, that the
-cardinality is off.
 func (communities Communities) Unique() Communities {
 	seen := map[string]bool{}
 	result := make(Communities, 0, len(communities))
@@ -203,34 +403,121 @@ func (communities Communities) Unique() Communities {
 
 	return result
 }
-*/
+
+// ParseCommunity parses a standard BGP community
+// like 65000:100. Whitespace around the components
+// is ignored.
+func ParseCommunity(s string) (Community, error) {
+	return parseCommunityParts(s, 2)
+}
+
+// ParseLargeCommunity parses a large BGP community
+// like 65000:100:200.
+func ParseLargeCommunity(s string) (Community, error) {
+	return parseCommunityParts(s, 3)
+}
+
+// CommunityFromString is the inverse of Community.String.
+// Standard and large communities are accepted, as well
+// as wildcards.
+func CommunityFromString(s string) (Community, error) {
+	community, err := parseCommunityTokens(s, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(community) != 2 && len(community) != 3 {
+		return nil, fmt.Errorf(
+			"%w: %q: expected 2 or 3 components, got %d",
+			ErrInvalidCommunity, s, len(community))
+	}
+	return community, nil
+}
+
+func parseCommunityParts(s string, parts int) (Community, error) {
+	community, err := parseCommunityTokens(s, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(community) != parts {
+		return nil, fmt.Errorf(
+			"%w: %q: expected %d components, got %d",
+			ErrInvalidCommunity, s, parts, len(community))
+	}
+	if parts == 2 {
+		// Standard communities are 16 bit ASN and value,
+		// 32 bit ASNs require large communities.
+		for _, v := range community {
+			if v > maxCommunityValue {
+				return nil, fmt.Errorf(
+					"%w: %q: component %d exceeds 16 bit, use a large community",
+					ErrInvalidCommunity, s, v)
+			}
+		}
+	}
+	return community, nil
+}
+
+// parseCommunityTokens parses the numeric components of
+// a community. Wildcards are only accepted if allowed.
+func parseCommunityTokens(s string, wildcards bool) (Community, error) {
+	tokens := strings.Split(s, ":")
+	community := make(Community, 0, len(tokens))
+	for _, t := range tokens {
+		v, err := parseCommunityToken(s, t, wildcards)
+		if err != nil {
+			return nil, err
+		}
+		community = append(community, v)
+	}
+	return community, nil
+}
+
+// parseCommunityToken parses a single component
+// of the community s.
+func parseCommunityToken(s, token string, wildcards bool) (int, error) {
+	token = strings.TrimSpace(token)
+	if wildcards && token == "*" {
+		return CommunityWildcard, nil
+	}
+	v, err := strconv.Atoi(token)
+	if err != nil || v < 0 || v > maxLargeCommunityValue {
+		return 0, fmt.Errorf(
+			"%w: %q: invalid component %q", ErrInvalidCommunity, s, token)
+	}
+	return v, nil
+}
 
 // ExtCommunity is a BGP extended community
 type ExtCommunity []any
 
+// String renders the extended community, e.g. rt:65000:100.
+// Components of unexpected types are formatted with %v.
 func (com ExtCommunity) String() string {
 	if len(com) < 1 {
 		return ""
 	}
-	res := ""
-	for i, v := range com {
-		if i == 0 {
-			res += v.(string)
-			continue
-		}
-		if i > 0 {
-			res += ":"
+	parts := make([]string, 0, len(com))
+	for _, v := range com {
+		switch c := v.(type) {
+		case string:
+			parts = append(parts, c)
+		case int:
+			if c == CommunityWildcard {
+				parts = append(parts, "*")
+				continue
+			}
+			parts = append(parts, strconv.Itoa(c))
+		default:
+			parts = append(parts, fmt.Sprintf("%v", c))
 		}
-		res += strconv.Itoa(v.(int))
 	}
-	return res
+	return strings.Join(parts, ":")
 }
 
 // ExtCommunities is a collection of extended bgp communities.
 type ExtCommunities []ExtCommunity
 
 // Unique deduplicates extended communities.
-/*
 func (communities ExtCommunities) Unique() ExtCommunities {
 	seen := map[string]bool{}
 	result := make(ExtCommunities, 0, len(communities))
@@ -246,7 +533,37 @@ func (communities ExtCommunities) Unique() ExtCommunities {
 
 	return result
 }
-*/
+
+// ParseExtCommunity parses an extended BGP community
+// with a type prefix, like rt:65000:100.
+func ParseExtCommunity(s string) (ExtCommunity, error) {
+	return parseExtCommunityTokens(s, false)
+}
+
+// parseExtCommunityTokens parses the type and the
+// numeric components of an extended community.
+func parseExtCommunityTokens(s string, wildcards bool) (ExtCommunity, error) {
+	tokens := strings.Split(s, ":")
+	if len(tokens) != 3 {
+		return nil, fmt.Errorf("%w: %q", ErrExtCommunityIncomplete, s)
+	}
+	kind := strings.TrimSpace(tokens[0])
+	if kind == "" {
+		return nil, fmt.Errorf("%w: %q", ErrExtCommunityIncomplete, s)
+	}
+	community := ExtCommunity{kind}
+	for _, t := range tokens[1:] {
+		if strings.TrimSpace(t) == "" {
+			return nil, fmt.Errorf("%w: %q", ErrExtCommunityIncomplete, s)
+		}
+		v, err := parseCommunityToken(s, t, wildcards)
+		if err != nil {
+			return nil, err
+		}
+		community = append(community, v)
+	}
+	return community, nil
+}
 
 // BGPInfo is a set of BGP attributes
 type BGPInfo struct {
@@ -259,68 +576,159 @@ type BGPInfo struct {
 	LocalPref        int            `json:"local_pref"`
 	Med              int            `json:"med"`
 	OTC              *int           `json:"otc"`
+
+	// Sorted communities for binary search
+	communitiesIdx      Communities
+	largeCommunitiesIdx Communities
 }
 
-// HasCommunity checks for the presence of a BGP community.
-func (bgp *BGPInfo) HasCommunity(community Community) bool {
-	if len(community) != 2 {
-		return false // This can never match.
+// communitiesLinearSearchMax is the number of communities
+// up to which a linear search is used even if a sorted index
+// is present. See BenchmarkHasCommunity for the threshold.
+const communitiesLinearSearchMax = 32
+
+// compareCommunities compares communities component wise.
+func compareCommunities(a, b Community) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
 	}
+	return len(a) - len(b)
+}
 
-	for _, com := range bgp.Communities {
-		if len(com) != len(community) {
+// makeCommunitiesIndex creates a sorted copy of the communities
+// if there are enough communities to benefit from a binary search.
+func makeCommunitiesIndex(communities Communities) Communities {
+	if len(communities) <= communitiesLinearSearchMax {
+		return nil
+	}
+	idx := slices.Clone(communities)
+	slices.SortFunc(idx, compareCommunities)
+	return idx
+}
+
+// IndexCommunities builds a sorted index of the standard
+// and large communities used by HasCommunity and
+// HasLargeCommunity on routes with many communities.
+//
+// The index is not updated when the communities are
+// modified afterwards.
+func (bgp *BGPInfo) IndexCommunities() {
+	bgp.communitiesIdx = makeCommunitiesIndex(bgp.Communities)
+	bgp.largeCommunitiesIdx = makeCommunitiesIndex(bgp.LargeCommunities)
+}
+
+// matchCommunity checks if any community in the haystack
+// matches the needle. Communities of any length are compared
+// element by element. Wildcard components of the needle
+// match any value.
+func matchCommunity(haystack Communities, needle Community) bool {
+	if !needle.hasWildcard() {
+		return slices.ContainsFunc(haystack, func(com Community) bool {
+			return slices.Equal(com, needle)
+		})
+	}
+	for _, com := range haystack {
+		if len(com) != len(needle) {
 			continue // This can't match.
 		}
-
-		if com[0] == community[0] &&
-			com[1] == community[1] {
+		matched := true
+		for i, v := range needle {
+			if v != CommunityWildcard && com[i] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
 			return true
 		}
 	}
-
 	return false
 }
 
-// HasExtCommunity checks for the presence of an
-// extended community.
-func (bgp *BGPInfo) HasExtCommunity(community ExtCommunity) bool {
-	if len(community) != 3 {
-		return false // This can never match.
-	}
-
-	for _, com := range bgp.ExtCommunities {
-		if len(com) != len(community) {
+// matchExtCommunity checks if any extended community in
+// the haystack matches the needle like matchCommunity.
+// Wildcards are either CommunityWildcard or "*".
+func matchExtCommunity(haystack ExtCommunities, needle ExtCommunity) bool {
+	for _, com := range haystack {
+		if len(com) != len(needle) {
 			continue // This can't match.
 		}
-
-		if com[0] == community[0] &&
-			com[1] == community[1] &&
-			com[2] == community[2] {
+		matched := true
+		for i, v := range needle {
+			if com[i] != v && v != CommunityWildcard && v != "*" {
+				matched = false
+				break
+			}
+		}
+		if matched {
 			return true
 		}
 	}
-
 	return false
 }
 
+// hasCommunity checks for the presence of a community
+// using the sorted index if available.
+func hasCommunity(communities, idx Communities, community Community) bool {
+	if len(idx) > 0 && !community.hasWildcard() {
+		_, found := slices.BinarySearchFunc(
+			idx, community, compareCommunities)
+		return found
+	}
+	return matchCommunity(communities, community)
+}
+
+// HasCommunity checks for the presence of a BGP community.
+func (bgp *BGPInfo) HasCommunity(community Community) bool {
+	if bgp == nil {
+		return false
+	}
+	return hasCommunity(bgp.Communities, bgp.communitiesIdx, community)
+}
+
+// HasExtCommunity checks for the presence of an
+// extended community.
+func (bgp *BGPInfo) HasExtCommunity(community ExtCommunity) bool {
+	if bgp == nil {
+		return false
+	}
+	return matchExtCommunity(bgp.ExtCommunities, community)
+}
+
 // HasLargeCommunity checks for the presence of a large community.
 func (bgp *BGPInfo) HasLargeCommunity(community Community) bool {
-	// TODO: This is an almost 1:1 match to the function above.
-	if len(community) != 3 {
-		return false // This can never match.
+	if bgp == nil {
+		return false
 	}
+	return hasCommunity(bgp.LargeCommunities, bgp.largeCommunitiesIdx, community)
+}
 
-	for _, com := range bgp.LargeCommunities {
-		if len(com) != len(community) {
-			continue // This can't match.
+// IsBlackhole checks if any standard, extended or large
+// community of the route is within the ranges of the
+// blackhole communities set.
+func (bgp *BGPInfo) IsBlackhole(set BGPCommunitiesSet) bool {
+	if bgp == nil {
+		return false
+	}
+	for _, c := range bgp.Communities {
+		if set.Matches(c) {
+			return true
 		}
-
-		if com[0] == community[0] &&
-			com[1] == community[1] &&
-			com[2] == community[2] {
+	}
+	for _, c := range bgp.ExtCommunities {
+		if set.MatchesExt(c) {
+			return true
+		}
+	}
+	for _, c := range bgp.LargeCommunities {
+		if set.Matches(c) {
 			return true
 		}
 	}
-
 	return false
 }
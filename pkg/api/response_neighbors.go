@@ -81,12 +81,17 @@ func (n *Neighbor) MatchLargeCommunity(Community) bool {
 }
 
 // MatchName is a case insensitive match of
-// the neighbor's description
+// the neighbor's description. The name is split
+// into space separated tokens, which all must be
+// contained in the description.
 func (n *Neighbor) MatchName(name string) bool {
-	name = strings.ToLower(name)
 	neighName := strings.ToLower(n.Description)
-
-	return strings.Contains(neighName, name)
+	for _, token := range strings.Fields(strings.ToLower(name)) {
+		if !strings.Contains(neighName, token) {
+			return false
+		}
+	}
+	return true
 }
 
 // RoutesChannel has the routes stats per channel,
@@ -106,13 +111,6 @@ type NeighborsResponse struct {
 	Neighbors Neighbors `json:"neighbors"`
 }
 
-// CacheTTL returns the duration of validity
-// of the neighbor response.
-func (res *NeighborsResponse) CacheTTL() time.Duration {
-	now := time.Now().UTC()
-	return res.Response.Meta.TTL.Sub(now)
-}
-
 // NeighborsLookupResults is a mapping of lookup neighbors.
 // The sourceID is used as a key.
 type NeighborsLookupResults map[string]Neighbors
@@ -3,15 +3,30 @@ package api
 import (
 	"encoding/json"
 	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Address families. When filtering routes,
+// AddrFamilyBoth matches routes of either family.
 const (
+	AddrFamilyBoth = 0
 	AddrFamilyIPv4 = 1
 	AddrFamilyIPv6 = 2
 )
 
 // Route is a prefix with BGP information.
+//
+// A Route is Filterable on its own, e.g. for endpoints
+// without neighbor or route server context. As this
+// information is missing, MatchSourceID, MatchASN and
+// MatchPeerAddress match any value, while MatchNextHopSelf
+// and MatchRejectStatus never match. Blackholes are
+// only detected by their communities.
 type Route struct {
 	// ID         string  `json:"id"`
 	NeighborID *string `json:"neighbor_id"`
@@ -30,11 +45,19 @@ type Route struct {
 	Details *json.RawMessage `json:"details"`
 }
 
+// Route implements Filterable
+var _ Filterable = (*Route)(nil)
+
 func (r *Route) String() string {
 	s, _ := json.Marshal(r)
 	return string(s)
 }
 
+// bgpInfo provides the BGP attributes of the route
+func (r *Route) bgpInfo() *BGPInfo {
+	return r.BGP
+}
+
 // MatchAddrFamily checks if the route matches the given address family
 func (r *Route) MatchAddrFamily(family uint8) bool {
 	return r.AddrFamily == family
@@ -65,6 +88,188 @@ func (r *Route) MatchLargeCommunity(community Community) bool {
 	return r.BGP.HasLargeCommunity(community)
 }
 
+// MatchCommunityRange checks for the presence of a BGP
+// community or large community within the range.
+func (r *Route) MatchCommunityRange(community BGPCommunityRange) bool {
+	if r.BGP == nil {
+		return false
+	}
+	communities := r.BGP.Communities
+	if len(community) == 3 {
+		communities = r.BGP.LargeCommunities
+	}
+	for _, c := range communities {
+		if community.Matches(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPeerAddress is not defined for routes without
+// neighbor information
+func (r *Route) MatchPeerAddress(addr string) bool {
+	return true // Like the ASN
+}
+
+// MatchBlackhole checks if the route carries one of the
+// blackhole communities. Without a route server, the
+// next hop is not considered.
+func (r *Route) MatchBlackhole(
+	isBlackhole bool,
+	communities BGPCommunitiesSet,
+) bool {
+	return r.BGP.IsBlackhole(communities) == isBlackhole
+}
+
+// MatchMed compares the multi exit discriminator
+// using the operator.
+func (r *Route) MatchMed(value int, op string) bool {
+	if r.BGP == nil {
+		return false
+	}
+	return compareInt(r.BGP.Med, value, op)
+}
+
+// MatchLocalPref compares the local preference
+// using the operator.
+func (r *Route) MatchLocalPref(value int, op string) bool {
+	if r.BGP == nil {
+		return false
+	}
+	return compareInt(r.BGP.LocalPref, value, op)
+}
+
+// MatchOTC matches the ASN of the only to customer
+// attribute. Routes without the attribute never match.
+func (r *Route) MatchOTC(asn int) bool {
+	if r.BGP == nil || r.BGP.OTC == nil {
+		return false
+	}
+	if asn == OTCSet {
+		return true
+	}
+	return *r.BGP.OTC == asn
+}
+
+// MatchCommunityLabel checks if any community of the
+// route has a label containing the text. Communities
+// without a label never match.
+func (r *Route) MatchCommunityLabel(
+	label string,
+	labels BGPCommunityMap,
+) bool {
+	if r.BGP == nil {
+		return false
+	}
+	for _, c := range r.BGP.Communities {
+		if labels.containsLabel(c.String(), label) {
+			return true
+		}
+	}
+	for _, c := range r.BGP.ExtCommunities {
+		if labels.containsLabel(c.String(), label) {
+			return true
+		}
+	}
+	for _, c := range r.BGP.LargeCommunities {
+		if labels.containsLabel(c.String(), label) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchNextHopSelf is not defined for routes without
+// a route server. The next hop is never considered self.
+func (r *Route) MatchNextHopSelf() bool {
+	return false
+}
+
+// MatchRejectStatus is not defined for routes without
+// a state. As the reject status is unknown, nothing
+// is matched.
+func (r *Route) MatchRejectStatus(rejected bool) bool {
+	warnRejectStatusUnknown()
+	return false
+}
+
+// MatchPrefixLength checks if the length of the
+// network prefix is within min and max.
+func (r *Route) MatchPrefixLength(min, max int) bool {
+	idx := strings.LastIndexByte(r.Network, '/')
+	if idx < 0 {
+		return false
+	}
+	length, err := strconv.Atoi(r.Network[idx+1:])
+	if err != nil {
+		return false
+	}
+	return length >= min && length <= max
+}
+
+// MatchPrefix checks if the network of the route is
+// the prefix, within the prefix or covering it, depending
+// on the mode. Routes of a different address family or
+// with an invalid network never match.
+func (r *Route) MatchPrefix(network *net.IPNet, mode string) bool {
+	_, routeNetwork, err := net.ParseCIDR(r.Network)
+	if err != nil {
+		return false
+	}
+	return matchPrefix(routeNetwork, network, mode)
+}
+
+// MatchRpkiStatus checks the RPKI status of the route
+// as indicated by its large communities.
+func (r *Route) MatchRpkiStatus(
+	status string,
+	communities RpkiCommunities,
+) bool {
+	return communities.Status(r.BGP) == status
+}
+
+// MatchNextHop checks if the next hop of the route
+// is the given address.
+func (r *Route) MatchNextHop(addr string) bool {
+	if r.BGP == nil || r.BGP.NextHop == nil {
+		return false
+	}
+	nextHop := net.ParseIP(*r.BGP.NextHop)
+	if nextHop == nil {
+		return false
+	}
+	return nextHop.Equal(net.ParseIP(addr))
+}
+
+// MatchOriginASN checks if the route was originated
+// by the ASN, which is the last ASN in the AS path.
+// Routes with an empty AS path do not match.
+func (r *Route) MatchOriginASN(asn int) bool {
+	if r.BGP == nil || len(r.BGP.AsPath) == 0 {
+		return false
+	}
+	return r.BGP.AsPath[len(r.BGP.AsPath)-1] == asn
+}
+
+// MatchASPathLength checks if the length of the AS path
+// is within min and max. With ignorePrepends, consecutive
+// duplicate ASNs in the AS path are counted as one hop.
+func (r *Route) MatchASPathLength(min, max int, ignorePrepends bool) bool {
+	if r.BGP == nil {
+		return false
+	}
+	length := len(r.BGP.AsPath)
+	if ignorePrepends {
+		for i := 1; i < len(r.BGP.AsPath); i++ {
+			if r.BGP.AsPath[i] == r.BGP.AsPath[i-1] {
+				length--
+			}
+		}
+	}
+	return length >= min && length <= max
+}
+
 // Routes is a collection of routes
 type Routes []*Route
 
@@ -100,6 +305,9 @@ func (routes Routes) ToLookupRoutes(
 			RouteServer: rs,
 		}
 		lr.Route.Details = nil
+		if lr.Route.BGP != nil {
+			lr.Route.BGP.IndexCommunities()
+		}
 		lookupRoutes = append(lookupRoutes, lr)
 	}
 	return lookupRoutes
@@ -113,12 +321,6 @@ type RoutesResponse struct {
 	NotExported Routes `json:"not_exported"`
 }
 
-// CacheTTL returns the cache ttl of the response
-func (res *RoutesResponse) CacheTTL() time.Duration {
-	now := time.Now().UTC()
-	return res.Response.Meta.TTL.Sub(now)
-}
-
 // Merge combines two routes responses by appending
 func (res *RoutesResponse) Merge(other *RoutesResponse) {
 	res.Imported = append(res.Imported, other.Imported...)
@@ -150,6 +352,7 @@ type FilteredResponse struct {
 	FiltersAvailable    *SearchFilters `json:"filters_available"`
 	FiltersApplied      *SearchFilters `json:"filters_applied"`
 	FiltersNotAvailable []string       `json:"filters_not_available"`
+	FilterWarnings      []string       `json:"filter_warnings,omitempty"`
 }
 
 const (
@@ -189,6 +392,19 @@ func (r *LookupRoute) MatchASN(asn int) bool {
 	return r.Neighbor.MatchASN(asn)
 }
 
+// MatchPeerAddress matches the neighbor's address.
+// Routes without a neighbor address do not match.
+func (r *LookupRoute) MatchPeerAddress(addr string) bool {
+	if r.Neighbor == nil || r.Neighbor.Address == "" {
+		return false
+	}
+	peer := net.ParseIP(r.Neighbor.Address)
+	if peer == nil {
+		return false
+	}
+	return peer.Equal(net.ParseIP(addr))
+}
+
 // MatchCommunity checks for the presence of a BGP community.
 func (r *LookupRoute) MatchCommunity(community Community) bool {
 	return r.Route.BGP.HasCommunity(community)
@@ -209,6 +425,70 @@ func (r *LookupRoute) MatchAddrFamily(family uint8) bool {
 	return r.Route.MatchAddrFamily(family)
 }
 
+// MatchBlackhole checks if the route carries one of the
+// blackhole communities or the next hop is one of the
+// route server's blackhole addresses.
+func (r *LookupRoute) MatchBlackhole(
+	isBlackhole bool,
+	communities BGPCommunitiesSet,
+) bool {
+	return r.isBlackhole(communities) == isBlackhole
+}
+
+func (r *LookupRoute) isBlackhole(communities BGPCommunitiesSet) bool {
+	if r.Route.BGP.IsBlackhole(communities) {
+		return true
+	}
+	if r.Route.BGP == nil || r.Route.BGP.NextHop == nil {
+		return false
+	}
+	return containsIPAddr(r.RouteServer.Blackholes, *r.Route.BGP.NextHop)
+}
+
+// MatchNextHopSelf checks if the next hop is one of
+// the route server's own addresses.
+func (r *LookupRoute) MatchNextHopSelf() bool {
+	if r.Route.BGP == nil || r.Route.BGP.NextHop == nil {
+		return false
+	}
+	return containsIPAddr(r.RouteServer.SelfAddresses, *r.Route.BGP.NextHop)
+}
+
+// containsIPAddr checks if the address is in the list.
+// Invalid addresses are never contained.
+func containsIPAddr(addrs []net.IP, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	return slices.ContainsFunc(addrs, ip.Equal)
+}
+
+// MatchRejectStatus checks if the route was filtered
+// by the route server. Routes with an unknown state
+// do not match.
+func (r *LookupRoute) MatchRejectStatus(rejected bool) bool {
+	switch r.State {
+	case RouteStateFiltered:
+		return rejected
+	case RouteStateImported:
+		return !rejected
+	}
+	warnRejectStatusUnknown()
+	return false
+}
+
+// rejectStatusWarning is used for logging routes
+// without reject information only once.
+var rejectStatusWarning sync.Once
+
+func warnRejectStatusUnknown() {
+	rejectStatusWarning.Do(func() {
+		log.Println(
+			"Route without reject information, status filter will not match")
+	})
+}
+
 // MatchNeighborQuery matches a neighbor query
 func (r *LookupRoute) MatchNeighborQuery(query *NeighborQuery) bool {
 	if r.RouteServer.ID != query.SourceID {
@@ -282,4 +562,8 @@ type PaginatedRoutesLookupResponse struct {
 	Filtered *RoutesLookup `json:"filtered"`
 
 	Status *StoreStatusMeta `json:"status"`
+
+	// IgnoredTokens of the query could not be
+	// assigned to a filter.
+	IgnoredTokens []string `json:"ignored_tokens"`
 }
@@ -2,6 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -134,7 +138,88 @@ func TestHasCommunity(t *testing.T) {
 	}
 }
 
-/*
+func TestMatchCommunity(t *testing.T) {
+	haystack := Communities{
+		{23, 42},
+		{1000, 23, 42},
+		{1, 2, 3, 4},
+	}
+	tests := []struct {
+		needle  Community
+		matched bool
+	}{
+		{Community{23, 42}, true},
+		{Community{42, 23}, false},
+		{Community{1000, 23, 42}, true},
+		{Community{1000, 23, 43}, false},
+		{Community{1, 2, 3, 4}, true},
+		{Community{23}, false},
+		{Community{23, 42, 1}, false},
+		{Community{1000, 23}, false},
+		{Community{}, false},
+		{Community{23, CommunityWildcard}, true},
+		{Community{1000, CommunityWildcard, CommunityWildcard}, true},
+		{Community{CommunityWildcard, CommunityWildcard, 41}, false},
+	}
+	for _, test := range tests {
+		if matchCommunity(haystack, test.needle) != test.matched {
+			t.Error("expected", test.needle, "match:", test.matched)
+		}
+	}
+
+	ext := ExtCommunities{{"rt", 23, 42}, {"ro", 1, 2}}
+	extTests := []struct {
+		needle  ExtCommunity
+		matched bool
+	}{
+		{ExtCommunity{"rt", 23, 42}, true},
+		{ExtCommunity{"rt", 42, 23}, false},
+		{ExtCommunity{"rt", 23}, false},
+		{ExtCommunity{"ro", CommunityWildcard, 2}, true},
+		{ExtCommunity{"*", 1, CommunityWildcard}, true},
+	}
+	for _, test := range extTests {
+		if matchExtCommunity(ext, test.needle) != test.matched {
+			t.Error("expected", test.needle, "match:", test.matched)
+		}
+	}
+}
+
+func TestBGPInfoIsBlackhole(t *testing.T) {
+	set := BGPCommunitiesSet{
+		Standard: []BGPCommunityRange{
+			{[]int{65535, 65535}, []int{666, 666}},
+		},
+		Large: []BGPCommunityRange{
+			{[]int{65000, 65000}, []int{666, 666}, []int{0, 100}},
+		},
+		Extended: []BGPCommunityRange{
+			{[]string{"rt", "rt"}, []int{65000, 65000}, []int{666, 666}},
+		},
+	}
+
+	routes := []struct {
+		bgp    *BGPInfo
+		expect bool
+	}{
+		{&BGPInfo{Communities: Communities{{65000, 1}, {65535, 666}}}, true},
+		{&BGPInfo{LargeCommunities: Communities{{65000, 666, 42}}}, true},
+		{&BGPInfo{ExtCommunities: ExtCommunities{{"rt", 65000, 666}}}, true},
+		{&BGPInfo{
+			Communities:      Communities{{65000, 666}},
+			LargeCommunities: Communities{{65000, 666, 101}},
+			ExtCommunities:   ExtCommunities{{"ro", 65000, 666}},
+		}, false},
+		{&BGPInfo{}, false},
+		{nil, false},
+	}
+	for _, r := range routes {
+		if r.bgp.IsBlackhole(set) != r.expect {
+			t.Error("unexpected blackhole match for", r.bgp)
+		}
+	}
+}
+
 func TestUniqueCommunities(t *testing.T) {
 	all := Communities{Community{23, 42}, Community{42, 123}, Community{23, 42}}
 	unique := all.Unique()
@@ -146,13 +231,371 @@ func TestUniqueCommunities(t *testing.T) {
 
 func TestUniqueExtCommunities(t *testing.T) {
 	all := ExtCommunities{
-		ExtCommunity{"rt", "23", "42"},
-		ExtCommunity{"ro", "42", "123"},
-		ExtCommunity{"rt", "23", "42"}}
+		ExtCommunity{"rt", 23, 42},
+		ExtCommunity{"ro", 42, 123},
+		ExtCommunity{"rt", 23, 42}}
 	unique := all.Unique()
 	if len(unique) != 2 {
 		t.Error("len(unique) should be < len(all)")
 	}
 	t.Log("All:", all, "Unique:", unique)
 }
-*/
+
+func TestConfigResponseRejectReason(t *testing.T) {
+	reasons := BGPCommunityMap{}
+	reasons.Set("65000:1:23", "prefix not registered")
+	reasons.Set("65000:*", "generic rejection")
+	reasons.Set("rt:65000:42", "extended rejection")
+	cfg := &ConfigResponse{RejectReasons: reasons}
+
+	reason, ok := cfg.RejectReason(Community{65000, 1, 23})
+	if !ok || reason != "prefix not registered" {
+		t.Error("unexpected reason:", reason, ok)
+	}
+	reason, ok = cfg.RejectReason(Community{65000, 5})
+	if !ok || reason != "generic rejection" {
+		t.Error("unexpected reason:", reason, ok)
+	}
+	reason, ok = cfg.RejectReasonExt(ExtCommunity{"rt", 65000, 42})
+	if !ok || reason != "extended rejection" {
+		t.Error("unexpected reason:", reason, ok)
+	}
+
+	if _, ok := cfg.RejectReason(Community{65001, 23}); ok {
+		t.Error("expected no reason for 65001:23")
+	}
+	if _, ok := cfg.RejectReasonExt(ExtCommunity{"ro", 65000, 42}); ok {
+		t.Error("expected no reason for ro:65000:42")
+	}
+}
+
+func TestStoreStatusOverallState(t *testing.T) {
+	status := &StoreStatus{}
+	if status.OverallState() != SourceStateInit {
+		t.Error("expected store without sources to be INIT")
+	}
+
+	status.Sources = map[string]*SourceStatus{
+		"rs1": {State: SourceStateReady, Initialized: true},
+		"rs2": {State: SourceStateReady, Initialized: true},
+	}
+	if status.OverallState() != SourceStateReady {
+		t.Error("expected READY, got:", status.OverallState())
+	}
+	if !status.IsReady() {
+		t.Error("expected store to be ready")
+	}
+
+	status.Sources["rs2"].State = SourceStateBusy
+	if status.OverallState() != SourceStateBusy {
+		t.Error("expected BUSY, got:", status.OverallState())
+	}
+	if !status.IsReady() {
+		t.Error("expected busy store to be ready")
+	}
+
+	status.Sources["rs3"] = &SourceStatus{State: SourceStateBusy}
+	if status.OverallState() != SourceStateInit {
+		t.Error("expected INIT, got:", status.OverallState())
+	}
+	if status.IsReady() {
+		t.Error("expected uninitialized store not to be ready")
+	}
+
+	status.Sources["rs1"].State = SourceStateError
+	if status.OverallState() != SourceStateError {
+		t.Error("expected ERROR, got:", status.OverallState())
+	}
+	if status.IsReady() {
+		t.Error("expected store with errors not to be ready")
+	}
+}
+
+func TestStoreStatusMetaAllInitialized(t *testing.T) {
+	meta := &StoreStatusMeta{}
+	if meta.AllInitialized() {
+		t.Error("expected empty meta not to be initialized")
+	}
+
+	meta.Routes = &StoreStatus{
+		Initialized: true,
+		Sources: map[string]*SourceStatus{
+			"rs1": {Initialized: true},
+		},
+	}
+	if !meta.AllInitialized() {
+		t.Error("expected routes store to be initialized")
+	}
+
+	meta.Neighbors = &StoreStatus{
+		Initialized: true,
+		Sources: map[string]*SourceStatus{
+			"rs1": {Initialized: true},
+			"rs2": {Initialized: false},
+		},
+	}
+	if meta.AllInitialized() {
+		t.Error("expected uninitialized source to fail the check")
+	}
+
+	meta.Neighbors.Sources["rs2"].Initialized = true
+	if !meta.AllInitialized() {
+		t.Error("expected all stores to be initialized")
+	}
+}
+
+func TestStoreStatusStaleSources(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	status := &StoreStatus{
+		Sources: map[string]*SourceStatus{
+			"fresh": {
+				RefreshInterval: 5 * time.Minute,
+				LastRefresh:     now.Add(-4 * time.Minute),
+				Initialized:     true,
+			},
+			"grace": {
+				RefreshInterval: 5 * time.Minute,
+				LastRefresh:     now.Add(-6 * time.Minute),
+				Initialized:     true,
+			},
+			"stale": {
+				RefreshInterval: 5 * time.Minute,
+				LastRefresh:     now.Add(-30 * time.Minute),
+				Initialized:     true,
+			},
+			"never": {
+				RefreshInterval: 5 * time.Minute,
+			},
+		},
+	}
+
+	stale := status.StaleSources(now, 2*time.Minute)
+	if !slices.Equal(stale, []string{"never", "stale"}) {
+		t.Error("unexpected stale sources:", stale)
+	}
+
+	stale = status.StaleSources(now, 0)
+	if !slices.Equal(stale, []string{"grace", "never", "stale"}) {
+		t.Error("unexpected stale sources without grace:", stale)
+	}
+}
+
+func TestSourceStatusSerialization(t *testing.T) {
+	status := &SourceStatus{State: SourceStateReady}
+	result, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(result), `"state":"READY"`) {
+		t.Error("unexpected serialization:", string(result))
+	}
+	if strings.Contains(string(result), "last_error") {
+		t.Error("expected last_error to be omitted:", string(result))
+	}
+
+	status.State = SourceStateError
+	status.LastError = "connection refused"
+	status.ErrorCount = 3
+	result, err = json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(result), `"last_error":"connection refused"`) ||
+		!strings.Contains(string(result), `"error_count":3`) {
+		t.Error("unexpected serialization:", string(result))
+	}
+}
+
+func makeTestBGPInfoCommunities(n int) *BGPInfo {
+	bgp := &BGPInfo{
+		Communities:      make(Communities, 0, n),
+		LargeCommunities: make(Communities, 0, n),
+	}
+	for i := n; i > 0; i-- {
+		bgp.Communities = append(bgp.Communities, Community{65000, i})
+		bgp.LargeCommunities = append(
+			bgp.LargeCommunities, Community{65000, 1, i})
+	}
+	return bgp
+}
+
+func TestHasCommunityIndexed(t *testing.T) {
+	for _, n := range []int{2, communitiesLinearSearchMax + 1, 200} {
+		bgp := makeTestBGPInfoCommunities(n)
+		bgp.IndexCommunities()
+		for i := 1; i <= n; i++ {
+			if !bgp.HasCommunity(Community{65000, i}) {
+				t.Error("expected community 65000:", i, "n:", n)
+			}
+			if !bgp.HasLargeCommunity(Community{65000, 1, i}) {
+				t.Error("expected large community 65000:1:", i, "n:", n)
+			}
+		}
+		if bgp.HasCommunity(Community{65000, n + 1}) {
+			t.Error("unexpected community, n:", n)
+		}
+		if bgp.HasLargeCommunity(Community{65000, 2, 1}) {
+			t.Error("unexpected large community, n:", n)
+		}
+	}
+}
+
+func BenchmarkHasCommunity(b *testing.B) {
+	for _, n := range []int{2, 4, 8, 16, 32, 64, 128, 512} {
+		bgp := makeTestBGPInfoCommunities(n)
+		needle := Community{65000, 1} // last element
+		b.Run(fmt.Sprintf("linear/%d", n), func(b *testing.B) {
+			for b.Loop() {
+				bgp.HasCommunity(needle)
+			}
+		})
+
+		indexed := makeTestBGPInfoCommunities(n)
+		indexed.communitiesIdx = slices.Clone(indexed.Communities)
+		slices.SortFunc(indexed.communitiesIdx, compareCommunities)
+		b.Run(fmt.Sprintf("sorted/%d", n), func(b *testing.B) {
+			for b.Loop() {
+				indexed.HasCommunity(needle)
+			}
+		})
+	}
+}
+
+func TestParseCommunity(t *testing.T) {
+	community, err := ParseCommunity(" 65000 : 100 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(community, Community{65000, 100}) {
+		t.Error("unexpected community:", community)
+	}
+
+	large, err := ParseLargeCommunity("65000: 100 :200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(large, Community{65000, 100, 200}) {
+		t.Error("unexpected large community:", large)
+	}
+
+	invalid := []string{"", "65000", "65000:100:200", "65000:foo", "*:100"}
+	for _, s := range invalid {
+		if _, err := ParseCommunity(s); !errors.Is(err, ErrInvalidCommunity) {
+			t.Error("expected invalid community error for", s, "got:", err)
+		}
+	}
+	if _, err := ParseLargeCommunity("65000:100"); err == nil {
+		t.Error("expected error for incomplete large community")
+	}
+
+	_, err = ParseCommunity("65000:1o0")
+	if err == nil || !strings.Contains(err.Error(), `"1o0"`) {
+		t.Error("expected error naming the token, got:", err)
+	}
+}
+
+func TestParseExtCommunity(t *testing.T) {
+	community, err := ParseExtCommunity("rt: 65000 :100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if community.String() != "rt:65000:100" {
+		t.Error("unexpected ext community:", community)
+	}
+
+	if _, err := ParseExtCommunity("rt:65000"); !errors.Is(err, ErrExtCommunityIncomplete) {
+		t.Error("expected incomplete error, got:", err)
+	}
+	if _, err := ParseExtCommunity("rt:65000:x"); !errors.Is(err, ErrInvalidCommunity) {
+		t.Error("expected invalid community error, got:", err)
+	}
+}
+
+func TestCommunityFromString(t *testing.T) {
+	communities := []Community{
+		{65000, 100},
+		{65000, 100, 200},
+		{0, CommunityWildcard},
+		{4200000000, 1, 2},
+	}
+	for _, c := range communities {
+		parsed, err := CommunityFromString(c.String())
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !slices.Equal(parsed, c) {
+			t.Error("expected", c, "got:", parsed)
+		}
+	}
+
+	invalid := []string{"", "65000", "1:2:3:4", "65000:-1", "a:b"}
+	for _, s := range invalid {
+		if _, err := CommunityFromString(s); err == nil {
+			t.Error("expected error for", s)
+		}
+	}
+}
+
+func TestExtCommunityStringMixedTypes(t *testing.T) {
+	tests := []struct {
+		community ExtCommunity
+		expected  string
+	}{
+		{ExtCommunity{"rt", 65000, 100}, "rt:65000:100"},
+		{ExtCommunity{"rt", CommunityWildcard, 100}, "rt:*:100"},
+		{ExtCommunity{1, 2, 3}, "1:2:3"},
+		{ExtCommunity{"ro", float64(65000), float64(100)}, "ro:65000:100"},
+		{ExtCommunity{}, ""},
+	}
+	for _, test := range tests {
+		if s := test.community.String(); s != test.expected {
+			t.Error("expected", test.expected, "got:", s)
+		}
+	}
+}
+
+func TestMetaIsExpired(t *testing.T) {
+	now := time.Now().UTC()
+	past := &Meta{TTL: now.Add(-time.Minute)}
+	future := &Meta{TTL: now.Add(time.Minute)}
+	cached := &Meta{
+		CacheStatus: CacheStatus{
+			CachedAt: now,
+			OrigTTL:  300,
+		},
+	}
+
+	if !past.IsExpired(now) {
+		t.Error("expected response with TTL in the past to be expired")
+	}
+	if future.IsExpired(now) {
+		t.Error("expected response with TTL in the future to be valid")
+	}
+	if cached.IsExpired(now.Add(time.Minute)) {
+		t.Error("expected response to be valid within the orig TTL")
+	}
+	if !cached.IsExpired(now.Add(10 * time.Minute)) {
+		t.Error("expected response to be expired after the orig TTL")
+	}
+	if !(&Meta{}).IsExpired(now) {
+		t.Error("expected response without TTL to be expired")
+	}
+
+	var res CacheableResponse = &NeighborsResponse{
+		Response: Response{Meta: past},
+	}
+	if res.CacheTTL() >= 0 {
+		t.Error("expected negative cache TTL, got:", res.CacheTTL())
+	}
+	res = &RoutesResponse{
+		Response: Response{Meta: future},
+	}
+	if res.CacheTTL() <= 0 {
+		t.Error("expected positive cache TTL, got:", res.CacheTTL())
+	}
+	res = &StatusResponse{}
+	if res.CacheTTL() >= 0 {
+		t.Error("expected response without meta to be expired")
+	}
+}
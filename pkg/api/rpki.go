@@ -0,0 +1,96 @@
+package api
+
+import "strconv"
+
+// RPKI validation states
+const (
+	RpkiStatusValid      = "valid"
+	RpkiStatusInvalid    = "invalid"
+	RpkiStatusUnknown    = "unknown"
+	RpkiStatusNotChecked = "not_checked"
+)
+
+// RpkiCommunities are the large communities indicating
+// the RPKI validation state of a route, as configured
+// in the rpki section of the config, e.g.
+//
+//	valid = 9999:1000:1
+//	invalid = 9999:1000:4-*
+//
+// Invalid communities may include a range start
+// followed by "*" as fourth element.
+type RpkiCommunities struct {
+	Valid      [][]string
+	Unknown    [][]string
+	NotChecked [][]string
+	Invalid    [][]string
+}
+
+// matchRpkiCommunity checks if the large community
+// equals one of the configured communities.
+func matchRpkiCommunity(com Community, configured [][]string) bool {
+	for _, match := range configured {
+		if len(match) < 3 {
+			continue
+		}
+		if strconv.Itoa(com[0]) == match[0] &&
+			strconv.Itoa(com[1]) == match[1] &&
+			strconv.Itoa(com[2]) == match[2] {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRpkiInvalid checks for an invalid community,
+// which can be a range.
+func matchRpkiInvalid(com Community, configured [][]string) bool {
+	for _, invalid := range configured {
+		if len(invalid) < 3 {
+			continue
+		}
+		if strconv.Itoa(com[0]) != invalid[0] ||
+			strconv.Itoa(com[1]) != invalid[1] {
+			continue
+		}
+		if len(invalid) > 3 && invalid[3] == "*" {
+			start, err := strconv.Atoi(invalid[2])
+			if err == nil && com[2] >= start {
+				return true
+			}
+			continue
+		}
+		if strconv.Itoa(com[2]) == invalid[2] {
+			return true
+		}
+	}
+	return false
+}
+
+// Status determines the RPKI status of a route from its
+// large communities. The first community indicating a
+// status is used. If no status is indicated, an empty
+// string is returned.
+func (c RpkiCommunities) Status(bgp *BGPInfo) string {
+	if bgp == nil {
+		return ""
+	}
+	for _, com := range bgp.LargeCommunities {
+		if len(com) != 3 {
+			continue
+		}
+		if matchRpkiCommunity(com, c.Valid) {
+			return RpkiStatusValid
+		}
+		if matchRpkiCommunity(com, c.Unknown) {
+			return RpkiStatusUnknown
+		}
+		if matchRpkiCommunity(com, c.NotChecked) {
+			return RpkiStatusNotChecked
+		}
+		if matchRpkiInvalid(com, c.Invalid) {
+			return RpkiStatusInvalid
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRpkiCommunitiesStatus(t *testing.T) {
+	rpki := RpkiCommunities{
+		Valid:      [][]string{{"9999", "1000", "1"}},
+		Unknown:    [][]string{{"9999", "1000", "2"}},
+		NotChecked: [][]string{{"9999", "1000", "3"}},
+		Invalid:    [][]string{{"9999", "1000", "4", "*"}},
+	}
+
+	tests := []struct {
+		communities Communities
+		status      string
+	}{
+		{Communities{{9999, 1000, 1}}, RpkiStatusValid},
+		{Communities{{9999, 1000, 2}}, RpkiStatusUnknown},
+		{Communities{{9999, 1000, 3}}, RpkiStatusNotChecked},
+		{Communities{{9999, 1000, 4}}, RpkiStatusInvalid},
+		{Communities{{1, 2, 3}, {9999, 1000, 7}}, RpkiStatusInvalid},
+		{Communities{{1, 2, 3}}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		bgp := &BGPInfo{LargeCommunities: test.communities}
+		if status := rpki.Status(bgp); status != test.status {
+			t.Error("expected", test.status, "for", test.communities,
+				"got:", status)
+		}
+	}
+
+	if rpki.Status(nil) != "" {
+		t.Error("expected no status without bgp info")
+	}
+}
+
+func TestRpkiCommunitiesStatusMultipleInvalid(t *testing.T) {
+	rpki := RpkiCommunities{
+		Invalid: [][]string{
+			{"9033", "65666", "2"},
+			{"9033", "65666", "3", "*"},
+		},
+	}
+
+	tests := []struct {
+		communities Communities
+		status      string
+	}{
+		{Communities{{9033, 65666, 2}}, RpkiStatusInvalid},
+		{Communities{{9033, 65666, 5}}, RpkiStatusInvalid},
+		{Communities{{9033, 65666, 1}}, ""},
+	}
+	for _, test := range tests {
+		bgp := &BGPInfo{LargeCommunities: test.communities}
+		if status := rpki.Status(bgp); status != test.status {
+			t.Error("expected", test.status, "for", test.communities,
+				"got:", status)
+		}
+	}
+}
+
+func TestSearchFilterRpkiStatus(t *testing.T) {
+	fc := &FilterContext{
+		RpkiCommunities: RpkiCommunities{
+			Valid:   [][]string{{"9999", "1000", "1"}},
+			Invalid: [][]string{{"9999", "1000", "4", "*"}},
+		},
+	}
+
+	route := makeTestLookupRoute()
+	route.BGP.LargeCommunities = Communities{{9999, 1000, 5}}
+
+	filters, err := fc.FiltersFromQuery(url.Values{"rpki_status": {"invalid,unknown"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected invalid route to match")
+	}
+
+	// Without configured communities the status is unknown
+	filters, err = FiltersFromQuery(url.Values{"rpki_status": {"invalid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected route not to match without rpki communities")
+	}
+
+	filters, err = fc.FiltersFromQuery(url.Values{"rpki_status": {"valid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected invalid route not to match valid")
+	}
+
+	if _, err := FiltersFromQuery(url.Values{"rpki_status": {"broken"}}); err == nil {
+		t.Error("expected error for unknown rpki status")
+	}
+}
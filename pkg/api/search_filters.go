@@ -1,11 +1,17 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/url"
+	"regexp"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // SearchKeys are filterable attributes
@@ -16,6 +22,36 @@ const (
 	SearchKeyExtCommunities   = "ext_communities"
 	SearchKeyLargeCommunities = "large_communities"
 	SearchKeyAddrFamily       = "addr_family"
+	SearchKeyNextHopSelf      = "next_hop_self"
+	SearchKeyRejectStatus     = "status"
+	SearchKeyPrefixLength     = "prefix_length"
+	SearchKeyRpkiStatus       = "rpki_status"
+	SearchKeyNextHop          = "next_hop"
+	SearchKeyOriginASN        = "origin_asn"
+	SearchKeyASPathLength     = "as_path_length"
+	SearchKeyPeerAddress      = "peer_address"
+	SearchKeyBlackhole        = "blackhole"
+	SearchKeyMed              = "med"
+	SearchKeyLocalPref        = "local_pref"
+	SearchKeyOTC              = "otc"
+	SearchKeyCommunityLabel   = "community_label"
+	SearchKeyPrefix           = "prefix"
+)
+
+// SearchKeyCommunitiesOp selects how the community
+// filters are combined.
+const SearchKeyCommunitiesOp = "communities_op"
+
+// Operators for combining the filters of a group
+const (
+	SearchFilterOpAll = "all"
+	SearchFilterOpAny = "any"
+)
+
+// Reject status filter values
+const (
+	RejectStatusRejected = "rejected"
+	RejectStatusAccepted = "accepted"
 )
 
 // Filterable objects provide methods for matching
@@ -24,21 +60,168 @@ type Filterable interface {
 	MatchSourceID(sourceID string) bool
 	MatchASN(asn int) bool
 	MatchCommunity(community Community) bool
+	MatchCommunityRange(community BGPCommunityRange) bool
 	MatchExtCommunity(community ExtCommunity) bool
 	MatchLargeCommunity(community Community) bool
 	MatchAddrFamily(family uint8) bool
+	MatchNextHopSelf() bool
+	MatchRejectStatus(rejected bool) bool
+	MatchPrefixLength(min, max int) bool
+	MatchRpkiStatus(status string, communities RpkiCommunities) bool
+	MatchNextHop(addr string) bool
+	MatchOriginASN(asn int) bool
+	MatchASPathLength(min, max int, ignorePrepends bool) bool
+	MatchPeerAddress(addr string) bool
+	MatchBlackhole(isBlackhole bool, communities BGPCommunitiesSet) bool
+	MatchMed(value int, op string) bool
+	MatchLocalPref(value int, op string) bool
+	MatchOTC(asn int) bool
+	MatchCommunityLabel(label string, labels BGPCommunityMap) bool
+	MatchPrefix(network *net.IPNet, mode string) bool
+}
+
+// MultiPathFilterable is implemented by filterables
+// carrying multiple paths for the same prefix, e.g.
+// when ADD-PATH is used. Each path is filterable
+// on its own.
+type MultiPathFilterable interface {
+	Filterable
+	Paths() []Filterable
 }
 
 // FilterValue can be anything
 type FilterValue any
 
+// IntRange is a filter value matching all integers
+// from Min to Max, including both.
+type IntRange struct {
+	Min int
+	Max int
+}
+
+// String renders the range as min-max or as
+// single value if min and max are equal.
+func (r IntRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	return strconv.Itoa(r.Min) + "-" + strconv.Itoa(r.Max)
+}
+
+// MarshalText encodes the range as string
+func (r IntRange) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// Comparison operators for IntComparison filters
+const (
+	CmpOpEq = "="
+	CmpOpGt = ">"
+	CmpOpLt = "<"
+	CmpOpGe = ">="
+	CmpOpLe = "<="
+)
+
+// IntComparison is a filter value matching all
+// integers satisfying the comparison, e.g. >100.
+type IntComparison struct {
+	Op    string
+	Value int
+}
+
+// String renders the comparison like >100. The
+// equality operator is omitted.
+func (c IntComparison) String() string {
+	if c.Op == CmpOpEq {
+		return strconv.Itoa(c.Value)
+	}
+	return c.Op + strconv.Itoa(c.Value)
+}
+
+// MarshalText encodes the comparison as string
+func (c IntComparison) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// Prefix match modes for PrefixMatch filters
+const (
+	PrefixMatchExact    = "exact"
+	PrefixMatchWithin   = "within"
+	PrefixMatchCovering = "covering"
+)
+
+// PrefixMatch is a filter value for matching the
+// network of a route against a prefix: exact matches
+// the prefix only, within matches the prefix and all
+// more specifics and covering matches the prefix and
+// all less specifics.
+type PrefixMatch struct {
+	Network *net.IPNet
+	Mode    string
+}
+
+// String renders the prefix match like covering:10.0.0.0/24.
+// The default mode within is omitted.
+func (p PrefixMatch) String() string {
+	if p.Mode == PrefixMatchWithin {
+		return p.Network.String()
+	}
+	return p.Mode + ":" + p.Network.String()
+}
+
+// MarshalText encodes the prefix match as string
+func (p PrefixMatch) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// matchPrefix checks if the route network is related
+// to the prefix by the mode. Networks of different
+// address families never match.
+func matchPrefix(route, prefix *net.IPNet, mode string) bool {
+	routeLen, routeBits := route.Mask.Size()
+	prefixLen, prefixBits := prefix.Mask.Size()
+	if routeBits != prefixBits {
+		return false
+	}
+	switch mode {
+	case PrefixMatchExact:
+		return routeLen == prefixLen && route.IP.Equal(prefix.IP)
+	case PrefixMatchWithin:
+		return routeLen >= prefixLen && prefix.Contains(route.IP)
+	case PrefixMatchCovering:
+		return routeLen <= prefixLen && route.Contains(prefix.IP)
+	}
+	return false
+}
+
+// compareInt compares a and b using the operator
+func compareInt(a, b int, op string) bool {
+	switch op {
+	case CmpOpEq:
+		return a == b
+	case CmpOpGt:
+		return a > b
+	case CmpOpLt:
+		return a < b
+	case CmpOpGe:
+		return a >= b
+	case CmpOpLe:
+		return a <= b
+	}
+	return false
+}
+
 // SearchFilter is a key value pair with
 // an indicator how many results the predicate
 // does cover.
+//
+// A negated filter matches routes not matching
+// the value.
 type SearchFilter struct {
 	Cardinality int         `json:"cardinality"`
 	Name        string      `json:"name"`
 	Value       FilterValue `json:"value"`
+	Negate      bool        `json:"negate,omitempty"`
 }
 
 // A SearchFilterCmpFunc can be implemented for various
@@ -50,6 +233,26 @@ func searchFilterCmpInt(a FilterValue, b FilterValue) bool {
 	return a.(int) == b.(int)
 }
 
+// Compare integer ranges
+func searchFilterCmpIntRange(a FilterValue, b FilterValue) bool {
+	return a.(IntRange) == b.(IntRange)
+}
+
+// Compare integer comparisons
+func searchFilterCmpIntComparison(a FilterValue, b FilterValue) bool {
+	return a.(IntComparison) == b.(IntComparison)
+}
+
+// Compare prefix matches
+func searchFilterCmpPrefixMatch(a FilterValue, b FilterValue) bool {
+	return a.(PrefixMatch).String() == b.(PrefixMatch).String()
+}
+
+// Compare booleans
+func searchFilterCmpBool(a FilterValue, b FilterValue) bool {
+	return a.(bool) == b.(bool)
+}
+
 // Compare strings
 func searchFilterCmpString(a FilterValue, b FilterValue) bool {
 	var (
@@ -59,10 +262,10 @@ func searchFilterCmpString(a FilterValue, b FilterValue) bool {
 	_, ptrA := a.(*string)
 	_, ptrB := b.(*string)
 
-	// Compare pointers, this is ok because we can assume
-	// using pool values for both.
-	if ptrA && ptrB {
-		return a == b
+	// Identical pointers, e.g. from the same pool,
+	// are equal without comparing the strings.
+	if ptrA && ptrB && a == b {
+		return true
 	}
 
 	// Otherwise fall back to string compare
@@ -80,7 +283,9 @@ func searchFilterCmpString(a FilterValue, b FilterValue) bool {
 	return valA == valB
 }
 
-// Compare communities
+// Compare communities. A wildcard component of the
+// filter a matches any value, while a wildcard in b
+// only matches a wildcard.
 func searchFilterCmpCommunity(a FilterValue, b FilterValue) bool {
 	ca := a.(Community)
 	cb := b.(Community)
@@ -91,6 +296,9 @@ func searchFilterCmpCommunity(a FilterValue, b FilterValue) bool {
 
 	// Compare components
 	for i := range ca {
+		if ca[i] == CommunityWildcard {
+			continue
+		}
 		if ca[i] != cb[i] {
 			return false
 		}
@@ -98,6 +306,11 @@ func searchFilterCmpCommunity(a FilterValue, b FilterValue) bool {
 	return true
 }
 
+// Compare community ranges
+func searchFilterCmpCommunityRange(a FilterValue, b FilterValue) bool {
+	return a.(BGPCommunityRange).String() == b.(BGPCommunityRange).String()
+}
+
 // Compare extended communities
 func searchFilterCmpExtCommunity(a FilterValue, b FilterValue) bool {
 	ca := a.(ExtCommunity)
@@ -114,14 +327,28 @@ func searchFilterCmpExtCommunity(a FilterValue, b FilterValue) bool {
 // by applying the appropriate compare function
 // to the serach filter value.
 func (f *SearchFilter) Equal(other *SearchFilter) bool {
+	if f.Negate != other.Negate {
+		return false
+	}
+
 	var cmp SearchFilterCmpFunc
 	switch other.Value.(type) {
 	case Community:
 		cmp = searchFilterCmpCommunity
 	case ExtCommunity:
 		cmp = searchFilterCmpExtCommunity
+	case BGPCommunityRange:
+		cmp = searchFilterCmpCommunityRange
 	case int:
 		cmp = searchFilterCmpInt
+	case IntRange:
+		cmp = searchFilterCmpIntRange
+	case IntComparison:
+		cmp = searchFilterCmpIntComparison
+	case PrefixMatch:
+		cmp = searchFilterCmpPrefixMatch
+	case bool:
+		cmp = searchFilterCmpBool
 	case string:
 		cmp = searchFilterCmpString
 	case *string:
@@ -136,13 +363,94 @@ func (f *SearchFilter) Equal(other *SearchFilter) bool {
 	return cmp(f.Value, other.Value)
 }
 
+// searchKeyLabels are human readable labels for
+// the filter groups.
+var searchKeyLabels = map[string]string{
+	SearchKeySources:          "Source",
+	SearchKeyASNS:             "ASN",
+	SearchKeyCommunities:      "Community",
+	SearchKeyExtCommunities:   "Ext. Community",
+	SearchKeyLargeCommunities: "Large Community",
+	SearchKeyAddrFamily:       "Address Family",
+	SearchKeyNextHopSelf:      "Next Hop Self",
+	SearchKeyRejectStatus:     "Status",
+	SearchKeyPrefixLength:     "Prefix Length",
+	SearchKeyRpkiStatus:       "RPKI",
+	SearchKeyNextHop:          "Next Hop",
+	SearchKeyOriginASN:        "Origin ASN",
+	SearchKeyASPathLength:     "AS Path Length",
+	SearchKeyPeerAddress:      "Peer Address",
+	SearchKeyBlackhole:        "Blackhole",
+	SearchKeyMed:              "MED",
+	SearchKeyLocalPref:        "Local Pref",
+	SearchKeyOTC:              "OTC",
+	SearchKeyCommunityLabel:   "Community Label",
+	SearchKeyPrefix:           "Prefix",
+}
+
+// Describe renders a human readable description of
+// the filter in a group identified by key, e.g.
+//
+//	ASN 64500 (Example Net)
+//	Community 65000:100
+//
+// The name is appended if it adds information
+// to the value.
+func (f *SearchFilter) Describe(groupKey string) string {
+	label, ok := searchKeyLabels[groupKey]
+	if !ok {
+		label = groupKey
+	}
+
+	// The address family is better represented by its name
+	if groupKey == SearchKeyAddrFamily && f.Name != "" {
+		return label + " " + f.Name
+	}
+
+	value := filterValueAsString(f.Value)
+	if f.Negate {
+		label += " !"
+	} else {
+		label += " "
+	}
+	if f.Name == "" || f.Name == value {
+		return label + value
+	}
+	return label + value + " (" + f.Name + ")"
+}
+
 // SearchFilterGroup contains filtergroups and
 // an index.
 type SearchFilterGroup struct {
 	Key string `json:"key"`
 
 	Filters    []*SearchFilter `json:"filters"`
-	filtersIdx map[string]int
+	filtersIdx map[filterKey]int
+
+	// negated is the number of negated filters
+	negated int
+
+	// wildcards is the number of community filters
+	// with wildcards or ranges, e.g. 65000:* or 65000:1-9
+	wildcards int
+
+	// Op is the operator for matching the filters of the
+	// group: with "all" each filter must match, with "any"
+	// a single match is sufficient. The community groups
+	// default to all, all other groups to any.
+	Op string `json:"op"`
+
+	// filterSets are any-of sub-groups used by MatchAll:
+	// a filter of each set must match.
+	filterSets [][]*SearchFilter
+
+	// mu guards adding and removing filters if the
+	// group was created by NewConcurrentSearchFilters.
+	mu *sync.Mutex
+
+	// fctx is the context the filters were parsed with.
+	// It provides the configuration for matching routes.
+	fctx *FilterContext
 }
 
 // FindFilter tries to lookup a filter in
@@ -169,6 +477,8 @@ func filterValueAsString(value any) string {
 		return strconv.Itoa(v)
 	case uint8:
 		return strconv.Itoa(int(v))
+	case bool:
+		return strconv.FormatBool(v)
 	case *string:
 		return *v
 	case string:
@@ -177,26 +487,98 @@ func filterValueAsString(value any) string {
 		return v.String()
 	case ExtCommunity:
 		return v.String()
+	case BGPCommunityRange:
+		return v.String()
+	case IntRange:
+		return v.String()
+	case IntComparison:
+		return v.String()
+	case PrefixMatch:
+		return v.String()
 	}
 	panic("unexpected filter value: " + fmt.Sprintf("%v", value))
 }
 
+// filterRef is the key of the filter in the index.
+// Negated filters are prefixed with a '!'.
+func filterRef(filter *SearchFilter) string {
+	ref := filterValueAsString(filter.Value)
+	if filter.Negate {
+		return "!" + ref
+	}
+	return ref
+}
+
+// filterKey is the key of a filter in the index.
+//
+// Communities are packed into the key, so building
+// the key does not allocate. All other values are
+// referenced by their string representation.
+type filterKey struct {
+	community [3]int
+	parts     int // The number of community components
+	ref       string
+	negate    bool
+}
+
+// makeFilterKey creates the index key for a filter value
+func makeFilterKey(value any, negate bool) filterKey {
+	if c, ok := value.(Community); ok && len(c) > 0 && len(c) <= 3 {
+		return communityFilterKey(c, negate)
+	}
+	return filterKey{
+		ref:    filterValueAsString(value),
+		negate: negate,
+	}
+}
+
+// communityFilterKey packs the community into a key
+func communityFilterKey(c Community, negate bool) filterKey {
+	key := filterKey{
+		parts:  len(c),
+		negate: negate,
+	}
+	copy(key.community[:], c)
+	return key
+}
+
+// filterIndexKey is the key of the filter in the index
+func filterIndexKey(filter *SearchFilter) filterKey {
+	return makeFilterKey(filter.Value, filter.Negate)
+}
+
 // GetFilterByValue retrieves a filter by matching
-// a string representation of it's filter value.
+// it's filter value.
 func (g *SearchFilterGroup) GetFilterByValue(value any) *SearchFilter {
-	ref := filterValueAsString(value)
-	idx, ok := g.filtersIdx[ref]
+	return g.getFilterByKey(makeFilterKey(value, false))
+}
+
+// getIndexedFilter retrieves a filter with the same
+// value and negation from the index.
+func (g *SearchFilterGroup) getIndexedFilter(filter *SearchFilter) *SearchFilter {
+	return g.getFilterByKey(filterIndexKey(filter))
+}
+
+func (g *SearchFilterGroup) getFilterByKey(key filterKey) *SearchFilter {
+	idx, ok := g.filtersIdx[key]
 	if !ok {
 		return nil // We don't have this particular filter
 	}
 	return g.Filters[idx]
 }
 
-// AddFilter adds a filter to a group
+// AddFilter adds a filter to a group.
+//
+// Unless the group was created by NewConcurrentSearchFilters,
+// the group must not be modified concurrently.
 func (g *SearchFilterGroup) AddFilter(filter *SearchFilter) {
+	if g.mu != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+	}
 	// Check if a filter with this value is present, if not:
 	// append and update index; otherwise incrementc cardinality
-	if presentFilter := g.GetFilterByValue(filter.Value); presentFilter != nil {
+	if presentFilter := g.getIndexedFilter(filter); presentFilter != nil {
 		presentFilter.Cardinality++
 		return
 	}
@@ -205,8 +587,13 @@ func (g *SearchFilterGroup) AddFilter(filter *SearchFilter) {
 	idx := len(g.Filters)
 	filter.Cardinality = 1
 	g.Filters = append(g.Filters, filter)
-	ref := filterValueAsString(filter.Value)
-	g.filtersIdx[ref] = idx
+	g.filtersIdx[filterIndexKey(filter)] = idx
+	if filter.Negate {
+		g.negated++
+	}
+	if filter.hasWildcard() {
+		g.wildcards++
+	}
 }
 
 // AddFilters adds a list of filters to a group.
@@ -216,14 +603,115 @@ func (g *SearchFilterGroup) AddFilters(filters []*SearchFilter) {
 	}
 }
 
+// RemoveFilter decrements the cardinality of a filter
+// in the group. Filters reaching a cardinality of zero
+// are dropped from the group.
+func (g *SearchFilterGroup) RemoveFilter(filter *SearchFilter) {
+	if g.mu != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+	}
+	idx, ok := g.filtersIdx[filterIndexKey(filter)]
+	if !ok {
+		return // Nothing to remove
+	}
+	presentFilter := g.Filters[idx]
+	presentFilter.Cardinality--
+	if presentFilter.Cardinality > 0 {
+		return
+	}
+
+	// Drop the filter and update the index
+	g.Filters = append(g.Filters[:idx], g.Filters[idx+1:]...)
+	g.rebuildIndex()
+}
+
 // Rebuild the filter index
 func (g *SearchFilterGroup) rebuildIndex() {
-	idx := make(map[string]int)
+	idx := make(map[filterKey]int)
+	negated := 0
+	wildcards := 0
 	for i, filter := range g.Filters {
-		ref := filterValueAsString(filter.Value)
-		idx[ref] = i
+		idx[filterIndexKey(filter)] = i
+		if filter.Negate {
+			negated++
+		}
+		if filter.hasWildcard() {
+			wildcards++
+		}
 	}
 	g.filtersIdx = idx // replace index
+	g.negated = negated
+	g.wildcards = wildcards
+}
+
+// SortByCardinality sorts the filters of the group by
+// cardinality, e.g. to show the most common ASNs first.
+// Filters with the same cardinality are ordered by name
+// and value. The order of the filters does not affect
+// matching.
+func (g *SearchFilterGroup) SortByCardinality(desc bool) {
+	if g.mu != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+	}
+	g.sortByCardinality(desc)
+	g.rebuildIndex()
+}
+
+func (g *SearchFilterGroup) sortByCardinality(desc bool) {
+	slices.SortStableFunc(g.Filters, func(a, b *SearchFilter) int {
+		if c := a.Cardinality - b.Cardinality; c != 0 {
+			if desc {
+				return -c
+			}
+			return c
+		}
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(
+			filterValueAsString(a.Value),
+			filterValueAsString(b.Value))
+	})
+}
+
+// TopN keeps the n filters with the highest cardinality
+// and drops the rest, e.g. to limit the number of
+// community facets in a response. The number of dropped
+// filters is returned. The remaining filters are sorted
+// as by SortByCardinality. A negative n keeps all filters.
+func (g *SearchFilterGroup) TopN(n int) int {
+	if g.mu != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+	}
+	g.sortByCardinality(true)
+	dropped := 0
+	if n >= 0 && len(g.Filters) > n {
+		dropped = len(g.Filters) - n
+		clear(g.Filters[n:])
+		g.Filters = g.Filters[:n]
+	}
+	g.rebuildIndex()
+	return dropped
+}
+
+// hasWildcard checks if the filter value is a
+// community with a wildcard component or a range.
+// These can not be looked up in the index.
+func (f *SearchFilter) hasWildcard() bool {
+	switch v := f.Value.(type) {
+	case Community:
+		return v.hasWildcard()
+	case BGPCommunityRange:
+		return true
+	case ExtCommunity:
+		return slices.ContainsFunc(v, func(c any) bool {
+			return c == CommunityWildcard || c == "*"
+		})
+	}
+	return false
 }
 
 // A SearchFilterComparator compares route with a filter
@@ -247,11 +735,13 @@ func searchFilterMatchASN(route Filterable, value any) bool {
 }
 
 func searchFilterMatchCommunity(route Filterable, value any) bool {
-	community, ok := value.(Community)
-	if !ok {
-		return false
+	switch community := value.(type) {
+	case Community:
+		return route.MatchCommunity(community)
+	case BGPCommunityRange:
+		return route.MatchCommunityRange(community)
 	}
-	return route.MatchCommunity(community)
+	return false
 }
 
 func searchFilterMatchExtCommunity(route Filterable, value any) bool {
@@ -263,11 +753,13 @@ func searchFilterMatchExtCommunity(route Filterable, value any) bool {
 }
 
 func searchFilterMatchLargeCommunity(route Filterable, value any) bool {
-	community, ok := value.(Community)
-	if !ok {
-		return false
+	switch community := value.(type) {
+	case Community:
+		return route.MatchLargeCommunity(community)
+	case BGPCommunityRange:
+		return route.MatchCommunityRange(community)
 	}
-	return route.MatchLargeCommunity(community)
+	return false
 }
 
 func searchFilterMatchAddrFamily(route Filterable, value any) bool {
@@ -275,10 +767,132 @@ func searchFilterMatchAddrFamily(route Filterable, value any) bool {
 	if !ok {
 		return false
 	}
+	if family == AddrFamilyBoth {
+		return true
+	}
 	return route.MatchAddrFamily(uint8(family))
 }
 
-func selectCmpFuncByKey(key string) SearchFilterComparator {
+func searchFilterMatchNextHopSelf(route Filterable, value any) bool {
+	isSelf, ok := value.(bool)
+	if !ok {
+		return false
+	}
+	return route.MatchNextHopSelf() == isSelf
+}
+
+func searchFilterMatchRejectStatus(route Filterable, value any) bool {
+	status, ok := value.(string)
+	if !ok {
+		return false
+	}
+	return route.MatchRejectStatus(status == RejectStatusRejected)
+}
+
+func searchFilterMatchPrefixLength(route Filterable, value any) bool {
+	length, ok := value.(IntRange)
+	if !ok {
+		return false
+	}
+	return route.MatchPrefixLength(length.Min, length.Max)
+}
+
+func (c *FilterContext) matchRpkiStatus(route Filterable, value any) bool {
+	status, ok := value.(string)
+	if !ok {
+		return false
+	}
+	return route.MatchRpkiStatus(status, c.RpkiCommunities)
+}
+
+func searchFilterMatchNextHop(route Filterable, value any) bool {
+	addr, ok := value.(string)
+	if !ok {
+		return false
+	}
+	return route.MatchNextHop(addr)
+}
+
+func searchFilterMatchOriginASN(route Filterable, value any) bool {
+	asn, ok := value.(int)
+	if !ok {
+		return false
+	}
+	return route.MatchOriginASN(asn)
+}
+
+func (c *FilterContext) matchASPathLength(route Filterable, value any) bool {
+	length, ok := value.(IntRange)
+	if !ok {
+		return false
+	}
+	return route.MatchASPathLength(
+		length.Min, length.Max, c.ASPathLengthIgnorePrepends)
+}
+
+func searchFilterMatchPeerAddress(route Filterable, value any) bool {
+	addr, ok := value.(string)
+	if !ok {
+		return false
+	}
+	return route.MatchPeerAddress(addr)
+}
+
+func (c *FilterContext) matchBlackhole(route Filterable, value any) bool {
+	isBlackhole, ok := value.(bool)
+	if !ok {
+		return false
+	}
+	return route.MatchBlackhole(isBlackhole, c.BlackholeCommunities)
+}
+
+func searchFilterMatchMed(route Filterable, value any) bool {
+	med, ok := value.(IntComparison)
+	if !ok {
+		return false
+	}
+	return route.MatchMed(med.Value, med.Op)
+}
+
+func searchFilterMatchLocalPref(route Filterable, value any) bool {
+	localPref, ok := value.(IntComparison)
+	if !ok {
+		return false
+	}
+	return route.MatchLocalPref(localPref.Value, localPref.Op)
+}
+
+func searchFilterMatchOTC(route Filterable, value any) bool {
+	asn, ok := value.(int)
+	if !ok {
+		return false
+	}
+	return route.MatchOTC(asn)
+}
+
+func (c *FilterContext) matchCommunityLabel(route Filterable, value any) bool {
+	label, ok := value.(string)
+	if !ok {
+		return false
+	}
+	return route.MatchCommunityLabel(label, c.CommunityLabels)
+}
+
+func searchFilterMatchPrefix(route Filterable, value any) bool {
+	prefix, ok := value.(PrefixMatch)
+	if !ok {
+		return false
+	}
+	return route.MatchPrefix(prefix.Network, prefix.Mode)
+}
+
+// selectCmpFuncByKey gets the comparator for the key.
+// Comparators depending on the configuration use the
+// filter context, or an empty context if c is nil.
+func selectCmpFuncByKey(key string, c *FilterContext) SearchFilterComparator {
+	if c == nil {
+		c = &emptyFilterContext
+	}
 	var cmp SearchFilterComparator
 	switch key {
 	case SearchKeySources:
@@ -293,6 +907,34 @@ func selectCmpFuncByKey(key string) SearchFilterComparator {
 		cmp = searchFilterMatchLargeCommunity
 	case SearchKeyAddrFamily:
 		cmp = searchFilterMatchAddrFamily
+	case SearchKeyNextHopSelf:
+		cmp = searchFilterMatchNextHopSelf
+	case SearchKeyRejectStatus:
+		cmp = searchFilterMatchRejectStatus
+	case SearchKeyPrefixLength:
+		cmp = searchFilterMatchPrefixLength
+	case SearchKeyRpkiStatus:
+		cmp = c.matchRpkiStatus
+	case SearchKeyNextHop:
+		cmp = searchFilterMatchNextHop
+	case SearchKeyOriginASN:
+		cmp = searchFilterMatchOriginASN
+	case SearchKeyASPathLength:
+		cmp = c.matchASPathLength
+	case SearchKeyPeerAddress:
+		cmp = searchFilterMatchPeerAddress
+	case SearchKeyBlackhole:
+		cmp = c.matchBlackhole
+	case SearchKeyMed:
+		cmp = searchFilterMatchMed
+	case SearchKeyLocalPref:
+		cmp = searchFilterMatchLocalPref
+	case SearchKeyOTC:
+		cmp = searchFilterMatchOTC
+	case SearchKeyCommunityLabel:
+		cmp = c.matchCommunityLabel
+	case SearchKeyPrefix:
+		cmp = searchFilterMatchPrefix
 	default:
 		cmp = nil
 	}
@@ -302,18 +944,30 @@ func selectCmpFuncByKey(key string) SearchFilterComparator {
 
 // MatchAny checks if a route matches any filter
 // in a filter group.
+//
+// Negated filters must all match, while any of
+// the other filters must match.
 func (g *SearchFilterGroup) MatchAny(route Filterable) bool {
 	// Check if we have any filter to match
 	if len(g.Filters) == 0 {
 		return true // no filter, everything matches
 	}
 
+	// Large community groups are matched using the index
+	if matched, ok := g.matchCompiled(route, false); ok {
+		return matched
+	}
+
 	// Get comparator
-	cmp := selectCmpFuncByKey(g.Key)
+	cmp := selectCmpFuncByKey(g.Key, g.fctx)
 	if cmp == nil {
 		return false // This should not have happened!
 	}
 
+	if g.negated > 0 {
+		return g.matchAnyNegated(route, cmp)
+	}
+
 	// Check if any of the given filters matches
 	for _, filter := range g.Filters {
 		if cmp(route, filter.Value) {
@@ -323,6 +977,27 @@ func (g *SearchFilterGroup) MatchAny(route Filterable) bool {
 	return false
 }
 
+// matchAnyNegated checks that the route matches none
+// of the negated filters and any of the other filters.
+func (g *SearchFilterGroup) matchAnyNegated(
+	route Filterable,
+	cmp SearchFilterComparator,
+) bool {
+	matched := g.negated == len(g.Filters)
+	for _, filter := range g.Filters {
+		if filter.Negate {
+			if cmp(route, filter.Value) {
+				return false
+			}
+			continue
+		}
+		if !matched && cmp(route, filter.Value) {
+			matched = true
+		}
+	}
+	return matched
+}
+
 // MatchAll checks if a route matches all predicates
 // in the filter group.
 func (g *SearchFilterGroup) MatchAll(route Filterable) bool {
@@ -331,15 +1006,26 @@ func (g *SearchFilterGroup) MatchAll(route Filterable) bool {
 		return true // no filter, everything matches. Like above.
 	}
 
+	// Large community groups are matched using the index
+	if matched, ok := g.matchCompiled(route, true); ok {
+		return matched
+	}
+
 	// Get comparator
-	cmp := selectCmpFuncByKey(g.Key)
+	cmp := selectCmpFuncByKey(g.Key, g.fctx)
 	if cmp == nil {
 		return false // This again should not have happened!
 	}
 
+	// When filter sets are present, a filter
+	// of each set must match.
+	if len(g.filterSets) > 0 {
+		return g.matchFilterSets(route, cmp)
+	}
+
 	// Assert that all filters match.
 	for _, filter := range g.Filters {
-		if !cmp(route, filter.Value) {
+		if cmp(route, filter.Value) == filter.Negate {
 			return false
 		}
 	}
@@ -348,71 +1034,389 @@ func (g *SearchFilterGroup) MatchAll(route Filterable) bool {
 	return true
 }
 
-// SearchFilters is a collection of filter groups
-type SearchFilters []*SearchFilterGroup
+// matchOp matches the route using MatchAny if the
+// group operator is any, otherwise MatchAll is used.
+func (g *SearchFilterGroup) matchOp(route Filterable) bool {
+	if g.Op == SearchFilterOpAny {
+		return g.MatchAny(route)
+	}
+	return g.MatchAll(route)
+}
 
-// NewSearchFilters creates a new collection
-// of search filter groups.
-func NewSearchFilters() *SearchFilters {
-	// Define groups: CAVEAT! the order is relevant
-	groups := &SearchFilters{
-		&SearchFilterGroup{
-			Key:        SearchKeySources,
-			Filters:    []*SearchFilter{},
-			filtersIdx: make(map[string]int),
-		},
+// compiledMatchMin is the number of filters in a community
+// group from which on the filter index is used for matching,
+// instead of comparing the route with each filter.
+// See BenchmarkMatchCommunitiesCompiled.
+const compiledMatchMin = 32
+
+// bgpInfoFilterable is implemented by filterables
+// providing access to their BGP attributes.
+type bgpInfoFilterable interface {
+	bgpInfo() *BGPInfo
+}
+
+// matchCompiled matches a route against a large community
+// group by looking up the route's communities in the filter
+// index: The costs depend on the number of communities of the
+// route instead of the number of filters.
+//
+// The second return value is false if the group can not be
+// matched this way and the filters must be compared one by one.
+func (g *SearchFilterGroup) matchCompiled(
+	route Filterable,
+	all bool,
+) (bool, bool) {
+	if len(g.Filters) < compiledMatchMin ||
+		len(g.filterSets) > 0 ||
+		g.negated > 0 ||
+		g.wildcards > 0 ||
+		len(g.filtersIdx) != len(g.Filters) {
+		return false, false
+	}
+	r, ok := route.(bgpInfoFilterable)
+	if !ok {
+		return false, false
+	}
+	bgp := r.bgpInfo()
+	if bgp == nil {
+		return false, false
+	}
+
+	var (
+		communities Communities
+		n           int
+	)
+	switch g.Key {
+	case SearchKeyCommunities:
+		communities = bgp.Communities
+		n = len(communities)
+	case SearchKeyLargeCommunities:
+		communities = bgp.LargeCommunities
+		n = len(communities)
+	case SearchKeyExtCommunities:
+		n = len(bgp.ExtCommunities)
+	default:
+		return false, false
+	}
+
+	// All filters must be present on the route
+	if all && n < len(g.Filters) {
+		return false, true
+	}
+
+	var (
+		matched []bool
+		count   int
+	)
+	if all {
+		matched = make([]bool, len(g.Filters))
+	}
+
+	// lookup checks the key in the index and
+	// returns true if the route matches any filter.
+	lookup := func(key filterKey) bool {
+		i, ok := g.filtersIdx[key]
+		if !ok {
+			return false
+		}
+		if !all {
+			return true
+		}
+		if !matched[i] {
+			matched[i] = true
+			count++
+		}
+		return false
+	}
+
+	for _, c := range communities {
+		if lookup(makeFilterKey(c, false)) {
+			return true, true
+		}
+	}
+	for _, c := range bgp.ExtCommunities {
+		if g.Key != SearchKeyExtCommunities {
+			break
+		}
+		if lookup(makeFilterKey(c, false)) {
+			return true, true
+		}
+	}
+
+	if !all {
+		return false, true
+	}
+	return count == len(g.Filters), true
+}
+
+// matchFilterSets checks that any filter of
+// each filter set matches.
+//
+// Negated filters are always combined with AND: a route
+// matching a negated filter of any set is excluded. A set
+// of only negated filters does not require a match.
+func (g *SearchFilterGroup) matchFilterSets(
+	route Filterable,
+	cmp SearchFilterComparator,
+) bool {
+	for _, set := range g.filterSets {
+		for _, filter := range set {
+			if filter.Negate && cmp(route, filter.Value) {
+				return false
+			}
+		}
+	}
+	for _, set := range g.filterSets {
+		matched := true
+		for _, filter := range set {
+			if filter.Negate {
+				continue
+			}
+			if cmp(route, filter.Value) {
+				matched = true
+				break
+			}
+			matched = false
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// AddFilterSet adds a set of filters to the group. In
+// MatchAll any filter of a set must match, the sets
+// of a group are combined with AND.
+func (g *SearchFilterGroup) AddFilterSet(filters []*SearchFilter) {
+	g.AddFilters(filters)
+	g.filterSets = append(g.filterSets, filters)
+}
+
+// SearchFilters is a collection of filter groups
+type SearchFilters []*SearchFilterGroup
+
+// NewConcurrentSearchFilters creates a collection of
+// search filter groups, where filters can be added and
+// removed from multiple goroutines, e.g. while ingesting
+// routes from multiple sources.
+// Reading the filters is only safe after all writers
+// are done.
+func NewConcurrentSearchFilters() *SearchFilters {
+	groups := NewSearchFilters()
+	for _, group := range *groups {
+		group.mu = &sync.Mutex{}
+	}
+	return groups
+}
+
+// NewSearchFilters creates a new collection
+// of search filter groups.
+func NewSearchFilters() *SearchFilters {
+	// Define groups: CAVEAT! the order is relevant
+	groups := &SearchFilters{
+		&SearchFilterGroup{
+			Key:        SearchKeySources,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
 		&SearchFilterGroup{
 			Key:        SearchKeyASNS,
 			Filters:    []*SearchFilter{},
-			filtersIdx: make(map[string]int),
+			filtersIdx: make(map[filterKey]int),
 		},
 		&SearchFilterGroup{
 			Key:        SearchKeyCommunities,
 			Filters:    []*SearchFilter{},
-			filtersIdx: make(map[string]int),
+			filtersIdx: make(map[filterKey]int),
 		},
 		&SearchFilterGroup{
 			Key:        SearchKeyExtCommunities,
 			Filters:    []*SearchFilter{},
-			filtersIdx: make(map[string]int),
+			filtersIdx: make(map[filterKey]int),
 		},
 		&SearchFilterGroup{
 			Key:        SearchKeyLargeCommunities,
 			Filters:    []*SearchFilter{},
-			filtersIdx: make(map[string]int),
+			filtersIdx: make(map[filterKey]int),
 		},
 		&SearchFilterGroup{
 			Key:        SearchKeyAddrFamily,
 			Filters:    []*SearchFilter{},
-			filtersIdx: make(map[string]int),
+			filtersIdx: make(map[filterKey]int),
 		},
+		&SearchFilterGroup{
+			Key:        SearchKeyNextHopSelf,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyRejectStatus,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyPrefixLength,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyRpkiStatus,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyNextHop,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyOriginASN,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyASPathLength,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyPeerAddress,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyBlackhole,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyMed,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyLocalPref,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyOTC,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyCommunityLabel,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+		&SearchFilterGroup{
+			Key:        SearchKeyPrefix,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+		},
+	}
+	for _, group := range *groups {
+		group.Op = defaultSearchFilterOp(group.Key)
 	}
 
 	return groups
 }
 
-// GetGroupByKey retrieves a search filter group
-// by a string.
-func (s *SearchFilters) GetGroupByKey(key string) *SearchFilterGroup {
+// defaultSearchFilterOp is the operator for matching
+// the filters of a group: Routes must have all of the
+// requested communities, while for all other groups
+// any of the filters must match.
+func defaultSearchFilterOp(key string) string {
+	switch key {
+	case SearchKeyCommunities,
+		SearchKeyExtCommunities,
+		SearchKeyLargeCommunities:
+		return SearchFilterOpAll
+	}
+	return SearchFilterOpAny
+}
+
+// searchGroupIndex is the position of the group
+// in the search filters created by NewSearchFilters.
+func searchGroupIndex(key string) int {
 	// This is an optimization (this is basically a fixed hash map,
 	// with hash(key) = position(key)
 	switch key {
 	case SearchKeySources:
-		return (*s)[0]
+		return 0
 	case SearchKeyASNS:
-		return (*s)[1]
+		return 1
 	case SearchKeyCommunities:
-		return (*s)[2]
+		return 2
 	case SearchKeyExtCommunities:
-		return (*s)[3]
+		return 3
 	case SearchKeyLargeCommunities:
-		return (*s)[4]
+		return 4
 	case SearchKeyAddrFamily:
-		return (*s)[5]
+		return 5
+	case SearchKeyNextHopSelf:
+		return 6
+	case SearchKeyRejectStatus:
+		return 7
+	case SearchKeyPrefixLength:
+		return 8
+	case SearchKeyRpkiStatus:
+		return 9
+	case SearchKeyNextHop:
+		return 10
+	case SearchKeyOriginASN:
+		return 11
+	case SearchKeyASPathLength:
+		return 12
+	case SearchKeyPeerAddress:
+		return 13
+	case SearchKeyBlackhole:
+		return 14
+	case SearchKeyMed:
+		return 15
+	case SearchKeyLocalPref:
+		return 16
+	case SearchKeyOTC:
+		return 17
+	case SearchKeyCommunityLabel:
+		return 18
+	case SearchKeyPrefix:
+		return 19
+	}
+	return -1
+}
+
+// GetGroupByKey retrieves a search filter group
+// by a string.
+func (s *SearchFilters) GetGroupByKey(key string) *SearchFilterGroup {
+	idx := searchGroupIndex(key)
+	if idx >= 0 && idx < len(*s) && (*s)[idx].Key == key {
+		return (*s)[idx]
+	}
+	// The groups are not in the expected order,
+	// so we have to look for the group.
+	for _, group := range *s {
+		if group.Key == key {
+			return group
+		}
 	}
 	return nil
 }
 
+// groupOrEmpty retrieves a search filter group by
+// key, or an empty group if the key is not present.
+func (s *SearchFilters) groupOrEmpty(key string) *SearchFilterGroup {
+	if group := s.GetGroupByKey(key); group != nil {
+		return group
+	}
+	return &SearchFilterGroup{
+		Key:        key,
+		Filters:    []*SearchFilter{},
+		filtersIdx: make(map[filterKey]int),
+	}
+}
+
 // UpdateSourcesFromLookupRoute updates the source filter
 func (s *SearchFilters) UpdateSourcesFromLookupRoute(r *LookupRoute) {
 	// Add source
@@ -431,25 +1435,52 @@ func (s *SearchFilters) UpdateASNSFromLookupRoute(r *LookupRoute) {
 	})
 }
 
+// An ASNNamer resolves an ASN to a human readable name,
+// e.g. from a cached PeeringDB dump. An empty string is
+// returned if the ASN is not known.
+type ASNNamer interface {
+	Name(asn int) string
+}
+
+// ResolveASNNames labels ASN filters without a name
+// using the namer. As the filters are deduplicated,
+// this should be called once after all routes are
+// added, instead of for each route.
+func (s *SearchFilters) ResolveASNNames(namer ASNNamer) {
+	if namer == nil {
+		return
+	}
+	for _, filter := range s.GetGroupByKey(SearchKeyASNS).Filters {
+		if filter.Name != "" {
+			continue
+		}
+		asn, ok := filter.Value.(int)
+		if !ok {
+			continue
+		}
+		filter.Name = namer.Name(asn)
+	}
+}
+
 // UpdateCommunitiesFromLookupRoute updates the communities filter
 func (s *SearchFilters) UpdateCommunitiesFromLookupRoute(r *LookupRoute) {
 	// Add communities
 	communities := s.GetGroupByKey(SearchKeyCommunities)
-	for _, c := range r.Route.BGP.Communities {
+	for _, c := range r.Route.BGP.Communities.Unique() {
 		communities.AddFilter(&SearchFilter{
 			Name:  c.String(),
 			Value: c,
 		})
 	}
 	extCommunities := s.GetGroupByKey(SearchKeyExtCommunities)
-	for _, c := range r.Route.BGP.ExtCommunities {
+	for _, c := range r.Route.BGP.ExtCommunities.Unique() {
 		extCommunities.AddFilter(&SearchFilter{
 			Name:  c.String(),
 			Value: c,
 		})
 	}
 	largeCommunities := s.GetGroupByKey(SearchKeyLargeCommunities)
-	for _, c := range r.Route.BGP.LargeCommunities {
+	for _, c := range r.Route.BGP.LargeCommunities.Unique() {
 		largeCommunities.AddFilter(&SearchFilter{
 			Name:  c.String(),
 			Value: c,
@@ -469,6 +1500,32 @@ func (s *SearchFilters) UpdateFromLookupRoute(r *LookupRoute) {
 	s.UpdateCommunitiesFromLookupRoute(r)
 }
 
+// RemoveFromLookupRoute is the inverse of UpdateFromLookupRoute:
+// The cardinalities of the filters matching the route are
+// decremented and filters no longer covering any route
+// are removed.
+func (s *SearchFilters) RemoveFromLookupRoute(r *LookupRoute) {
+	s.GetGroupByKey(SearchKeySources).RemoveFilter(&SearchFilter{
+		Value: r.RouteServer.ID,
+	})
+	s.GetGroupByKey(SearchKeyASNS).RemoveFilter(&SearchFilter{
+		Value: r.Neighbor.ASN,
+	})
+
+	communities := s.GetGroupByKey(SearchKeyCommunities)
+	for _, c := range r.Route.BGP.Communities.Unique() {
+		communities.RemoveFilter(&SearchFilter{Value: c})
+	}
+	extCommunities := s.GetGroupByKey(SearchKeyExtCommunities)
+	for _, c := range r.Route.BGP.ExtCommunities.Unique() {
+		extCommunities.RemoveFilter(&SearchFilter{Value: c})
+	}
+	largeCommunities := s.GetGroupByKey(SearchKeyLargeCommunities)
+	for _, c := range r.Route.BGP.LargeCommunities.Unique() {
+		largeCommunities.RemoveFilter(&SearchFilter{Value: c})
+	}
+}
+
 // UpdateFromRoute updates a search filter, however as
 // information of the route server or neighbor is not
 // present, as this is not a lookup route, only
@@ -477,21 +1534,21 @@ func (s *SearchFilters) UpdateFromRoute(r *Route) {
 
 	// Add communities
 	communities := s.GetGroupByKey(SearchKeyCommunities)
-	for _, c := range r.BGP.Communities {
+	for _, c := range r.BGP.Communities.Unique() {
 		communities.AddFilter(&SearchFilter{
 			Name:  c.String(),
 			Value: c,
 		})
 	}
 	extCommunities := s.GetGroupByKey(SearchKeyExtCommunities)
-	for _, c := range r.BGP.ExtCommunities {
+	for _, c := range r.BGP.ExtCommunities.Unique() {
 		extCommunities.AddFilter(&SearchFilter{
 			Name:  c.String(),
 			Value: c,
 		})
 	}
 	largeCommunities := s.GetGroupByKey(SearchKeyLargeCommunities)
-	for _, c := range r.BGP.LargeCommunities {
+	for _, c := range r.BGP.LargeCommunities.Unique() {
 		largeCommunities.AddFilter(&SearchFilter{
 			Name:  c.String(),
 			Value: c,
@@ -499,6 +1556,98 @@ func (s *SearchFilters) UpdateFromRoute(r *Route) {
 	}
 }
 
+// UpdateFromRoutes updates the filters with all routes
+// of a result set, including the address families.
+//
+// The cardinalities are counted over the entire set,
+// so this should be called with all matching routes
+// before a page is selected. The cardinalities do not
+// depend on the order of the routes, however the filters
+// within a group are ordered by the first route
+// providing the value.
+//
+// The MED filters are ordered by descending cardinality
+// and ascending value instead, so the order is stable
+// independent of the routes.
+func (s *SearchFilters) UpdateFromRoutes(routes []*Route) {
+	for _, r := range routes {
+		s.UpdateFromRoute(r)
+		s.UpdateAddrFamilyFromRoute(r)
+		s.UpdateMedFromRoute(r)
+	}
+	s.GetGroupByKey(SearchKeyMed).sortByIntComparison()
+}
+
+// UpdateMedFromRoute counts the MED of the route
+// in the MED filter as an equality comparison.
+func (s *SearchFilters) UpdateMedFromRoute(r *Route) {
+	if r.BGP == nil {
+		return
+	}
+	med := IntComparison{Op: CmpOpEq, Value: r.BGP.Med}
+	s.GetGroupByKey(SearchKeyMed).AddFilter(&SearchFilter{
+		Name:  med.String(),
+		Value: med,
+	})
+}
+
+// sortByIntComparison orders filters with IntComparison
+// values by descending cardinality and ascending value.
+func (g *SearchFilterGroup) sortByIntComparison() {
+	if g.mu != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+	}
+	slices.SortStableFunc(g.Filters, func(a, b *SearchFilter) int {
+		if c := b.Cardinality - a.Cardinality; c != 0 {
+			return c
+		}
+		va, _ := a.Value.(IntComparison)
+		vb, _ := b.Value.(IntComparison)
+		if c := va.Value - vb.Value; c != 0 {
+			return c
+		}
+		return strings.Compare(va.Op, vb.Op)
+	})
+	g.rebuildIndex()
+}
+
+// UpdateAddrFamilyFromRoute counts the route in the
+// address family filter. If the address family of the
+// route is not set, it is derived from the network or
+// the next hop.
+func (s *SearchFilters) UpdateAddrFamilyFromRoute(r *Route) {
+	if af := routeAddrFamily(r); af != 0 {
+		s.addFilterAddrFamily(af)
+	}
+}
+
+// routeAddrFamily gets the address family of a route,
+// or 0 if it can not be determined.
+func routeAddrFamily(r *Route) uint8 {
+	if r.AddrFamily != 0 {
+		return r.AddrFamily
+	}
+	if _, network, err := net.ParseCIDR(r.Network); err == nil {
+		return ipAddrFamily(network.IP)
+	}
+	if r.Gateway != nil {
+		return ipAddrFamily(net.ParseIP(*r.Gateway))
+	}
+	return 0
+}
+
+// ipAddrFamily gets the address family of an IP
+func ipAddrFamily(ip net.IP) uint8 {
+	if ip == nil {
+		return 0
+	}
+	if ip.To4() != nil {
+		return AddrFamilyIPv4
+	}
+	return AddrFamilyIPv6
+}
+
 // SetFilterAddrFamilies adds an ipv4 / ipv6 filter
 // to the addr family filter group if enabled.
 func (s *SearchFilters) SetFilterAddrFamilies(ip4, ip6 bool) {
@@ -513,8 +1662,11 @@ func (s *SearchFilters) SetFilterAddrFamilies(ip4, ip6 bool) {
 // Internal: set the actual addr family filter
 func (s *SearchFilters) addFilterAddrFamily(af uint8) {
 	name := "IPv4"
-	if af == AddrFamilyIPv6 {
+	switch af {
+	case AddrFamilyIPv6:
 		name = "IPv6"
+	case AddrFamilyBoth:
+		name = "IPv4/IPv6"
 	}
 	grp := s.GetGroupByKey(SearchKeyAddrFamily)
 	grp.AddFilter(&SearchFilter{
@@ -539,61 +1691,403 @@ func (s *SearchFilters) addFilterAddrFamily(af uint8) {
 //	                   Filter{Value: 23123}]},
 //	    Group{"communities", ...
 //	}
+//
+// Communities passed as a single parameter must all
+// be present on a route:
+//
+//	communities=65000:100,65000:200
+//
+// matches 65000:100 AND 65000:200. When the parameter is
+// repeated, each parameter is a set of communities of
+// which any must be present, and all sets must match:
+//
+//	communities=65000:100,65000:200&communities=65001:1
+//
+// matches (65000:100 OR 65000:200) AND 65001:1. Repeated
+// parameters with a single community each are still
+// combined with AND.
+//
+// Values prefixed with a '!' are negated and exclude
+// matching routes:
+//
+//	asns=!2342,!23042,64500
+//
+// matches routes from AS64500 but never from AS2342
+// or AS23042: negated filters are combined with AND
+// while the other filters are combined with OR.
+//
+// With communities_op=any, a route matches if any of
+// the community filters match. The default is all.
+//
+// Unknown parameters are ignored, see FiltersFromQueryStrict.
 func FiltersFromQuery(query url.Values) (*SearchFilters, error) {
-	queryFilters := NewSearchFilters()
-	for key := range query {
-		value := query.Get(key)
-		switch key {
-		case SearchKeySources:
-			filters, err := parseQueryValueList(parseStringValue, value)
-			if err != nil {
-				return nil, err
+	return (&FilterContext{}).FiltersFromQuery(query)
+}
+
+// FiltersFromQuery builds search filters from query
+// parameters, see the package level FiltersFromQuery.
+// Aliases of the context are expanded in standard
+// community filters.
+func (c *FilterContext) FiltersFromQuery(query url.Values) (*SearchFilters, error) {
+	queryFilters := c.newSearchFilters()
+	for key, values := range query {
+		if err := c.parseQueryFilter(queryFilters, key, values); err != nil {
+			return nil, &FilterParseError{
+				Key:   key,
+				Value: strings.Join(values, ","),
+				Err:   err,
 			}
-			queryFilters.GetGroupByKey(SearchKeySources).AddFilters(filters)
+		}
+	}
+	return queryFilters, nil
+}
 
-		case SearchKeyASNS:
-			filters, err := parseQueryValueList(parseIntValue, value)
-			if err != nil {
-				return nil, err
+// parseQueryFilter parses the values of a query
+// parameter into the filter group for the key.
+func (c *FilterContext) parseQueryFilter(
+	queryFilters *SearchFilters,
+	key string,
+	values []string,
+) error {
+	value := ""
+	if len(values) > 0 {
+		value = values[0]
+	}
+	switch key {
+	case SearchKeySources:
+		filters, err := parseQueryValueList(parseStringValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeySources).AddFilters(filters)
+
+	case SearchKeyASNS:
+		filters, err := parseQueryValueList(parseIntValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyASNS).AddFilters(filters)
+
+	case SearchKeyCommunities:
+		err := parseCommunitiesQuery(
+			queryFilters.GetGroupByKey(SearchKeyCommunities),
+			c.parseCommunityAliasValue,
+			values)
+		if err != nil {
+			return err
+		}
+
+	case SearchKeyExtCommunities:
+		err := parseCommunitiesQuery(
+			queryFilters.GetGroupByKey(SearchKeyExtCommunities),
+			parseExtCommunityValue,
+			values)
+		if err != nil {
+			return err
+		}
+
+	case SearchKeyLargeCommunities:
+		err := parseCommunitiesQuery(
+			queryFilters.GetGroupByKey(SearchKeyLargeCommunities),
+			parseCommunityValue,
+			values)
+		if err != nil {
+			return err
+		}
+
+	case SearchKeyAddrFamily:
+		// Parse as int values for address family
+		filters, err := parseQueryValueList(parseIntValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyAddrFamily).AddFilters(filters)
+
+	case SearchKeyNextHopSelf:
+		filters, err := parseQueryValueList(parseBoolValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyNextHopSelf).AddFilters(filters)
+
+	case SearchKeyRejectStatus:
+		filters, err := parseQueryValueList(parseRejectStatusValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyRejectStatus).AddFilters(filters)
+
+	case SearchKeyPrefixLength:
+		filters, err := parseQueryValueList(parsePrefixLengthValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyPrefixLength).AddFilters(filters)
+
+	case SearchKeyRpkiStatus:
+		filters, err := parseQueryValueList(parseRpkiStatusValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyRpkiStatus).AddFilters(filters)
+
+	case SearchKeyNextHop:
+		filters, err := parseQueryValueList(parseIPAddrValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyNextHop).AddFilters(filters)
+
+	case SearchKeyOriginASN:
+		filters, err := parseQueryValueList(parseIntValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyOriginASN).AddFilters(filters)
+
+	case SearchKeyASPathLength:
+		filters, err := parseQueryValueList(parseASPathLengthValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyASPathLength).AddFilters(filters)
+
+	case SearchKeyPeerAddress:
+		filters, err := parseQueryValueList(parseIPAddrValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyPeerAddress).AddFilters(filters)
+
+	case SearchKeyBlackhole:
+		filters, err := parseQueryValueList(parseBoolValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyBlackhole).AddFilters(filters)
+
+	case SearchKeyMed:
+		filters, err := parseQueryValueList(parseComparableIntValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyMed).AddFilters(filters)
+
+	case SearchKeyLocalPref:
+		filters, err := parseQueryValueList(parseComparableIntValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyLocalPref).AddFilters(filters)
+
+	case SearchKeyOTC:
+		filters, err := parseQueryValueList(parseOTCValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyOTC).AddFilters(filters)
+
+	case SearchKeyCommunityLabel:
+		filters, err := parseQueryValueList(parseCommunityLabelValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyCommunityLabel).AddFilters(filters)
+
+	case SearchKeyPrefix:
+		filters, err := parseQueryValueList(parsePrefixValue, value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyPrefix).AddFilters(filters)
+
+	case SearchKeyCommunitiesOp:
+		op, err := parseFilterOp(value)
+		if err != nil {
+			return err
+		}
+		queryFilters.GetGroupByKey(SearchKeyCommunities).Op = op
+		queryFilters.GetGroupByKey(SearchKeyExtCommunities).Op = op
+		queryFilters.GetGroupByKey(SearchKeyLargeCommunities).Op = op
+	}
+	return nil
+}
+
+// A FilterParseError is returned when the value of
+// a filter in a query can not be parsed.
+type FilterParseError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+// Error implements the error interface
+func (err *FilterParseError) Error() string {
+	return fmt.Sprintf("invalid %s filter %q: %s", err.Key, err.Value, err.Err)
+}
+
+// Unwrap returns the underlying parse error
+func (err *FilterParseError) Unwrap() error {
+	return err.Err
+}
+
+// ErrUnknownFilterKey is returned when a query
+// parameter does not refer to a filter.
+type ErrUnknownFilterKey struct {
+	Key string
+}
+
+// Error implements the error interface
+func (err *ErrUnknownFilterKey) Error() string {
+	return fmt.Sprintf("unknown filter: %s", err.Key)
+}
+
+// isFilterKey checks if the query parameter
+// refers to a filter.
+func isFilterKey(key string) bool {
+	if key == SearchKeyCommunitiesOp {
+		return true
+	}
+	_, ok := searchKeyLabels[key]
+	return ok
+}
+
+// FiltersFromQueryStrict builds search filters like
+// FiltersFromQuery, but fails with an ErrUnknownFilterKey
+// for parameters not referring to a filter, e.g. a typo
+// like comunities=23:42.
+//
+// Query and pagination parameters like q and page are
+// permitted.
+func FiltersFromQueryStrict(query url.Values) (*SearchFilters, error) {
+	return (&FilterContext{}).FiltersFromQueryStrict(query)
+}
+
+// FiltersFromQueryStrict builds search filters from query
+// parameters, see the package level FiltersFromQueryStrict.
+func (c *FilterContext) FiltersFromQueryStrict(query url.Values) (*SearchFilters, error) {
+	for key := range query {
+		if isFilterKey(key) || slices.Contains(queryParamsIgnored, key) {
+			continue
+		}
+		return nil, &ErrUnknownFilterKey{Key: key}
+	}
+	return c.FiltersFromQuery(query)
+}
+
+// ToQuery encodes the search filters as query parameters.
+// This is the inverse of FiltersFromQuery: Filters of a group
+// are joined as a comma separated list. Filter sets are
+// encoded as repeated parameters.
+func (s *SearchFilters) ToQuery() url.Values {
+	query := url.Values{}
+	for _, group := range *s {
+		if len(group.Filters) == 0 {
+			continue
+		}
+		if len(group.filterSets) > 0 {
+			for _, filters := range group.filterSets {
+				query.Add(group.Key, joinFilterRefs(filters))
 			}
-			queryFilters.GetGroupByKey(SearchKeyASNS).AddFilters(filters)
+			continue
+		}
+		query.Set(group.Key, joinFilterRefs(group.Filters))
+	}
+	if s.GetGroupByKey(SearchKeyCommunities).Op == SearchFilterOpAny {
+		query.Set(SearchKeyCommunitiesOp, SearchFilterOpAny)
+	}
+	return query
+}
 
-		case SearchKeyCommunities:
-			filters, err := parseQueryValueList(parseCommunityValue, value)
-			if err != nil {
-				return nil, err
-			}
-			queryFilters.GetGroupByKey(SearchKeyCommunities).AddFilters(filters)
+// joinFilterRefs joins the filter values as a
+// comma separated list.
+func joinFilterRefs(filters []*SearchFilter) string {
+	refs := make([]string, 0, len(filters))
+	for _, f := range filters {
+		refs = append(refs, filterRef(f))
+	}
+	return strings.Join(refs, ",")
+}
 
-		case SearchKeyExtCommunities:
-			filters, err := parseQueryValueList(parseExtCommunityValue, value)
-			if err != nil {
-				return nil, err
-			}
-			queryFilters.GetGroupByKey(SearchKeyExtCommunities).AddFilters(filters)
+// communitySeparatorTypos replaces commonly mistyped
+// separators of BGP community components.
+var communitySeparatorTypos = strings.NewReplacer(
+	";", ":",
+	".", ":",
+	",", ":",
+	"/", ":",
+	" ", ":",
+)
 
-		case SearchKeyLargeCommunities:
-			filters, err := parseQueryValueList(parseCommunityValue, value)
-			if err != nil {
-				return nil, err
-			}
-			queryFilters.GetGroupByKey(SearchKeyLargeCommunities).AddFilters(filters)
+// suggestCommunityFilterText tries to correct a malformed
+// community filter text. An empty string is returned if
+// no valid correction was found.
+func suggestCommunityFilterText(text string) string {
+	suggestion := communitySeparatorTypos.Replace(strings.TrimSpace(text))
+	if suggestion == text {
+		return "" // Nothing to correct
+	}
+	if _, _, err := parseCommunityFilterTokens(suggestion); err != nil {
+		return ""
+	}
+	return suggestion
+}
 
-		case SearchKeyAddrFamily:
-			// Parse as int values for address family
-			filters, err := parseQueryValueList(parseIntValue, value)
-			if err != nil {
-				return nil, err
-			}
-			queryFilters.GetGroupByKey(SearchKeyAddrFamily).AddFilters(filters)
+// parseCommunitiesQuery adds the community filters from
+// the query values to the group. See FiltersFromQuery
+// for the semantics of repeated parameters.
+func parseCommunitiesQuery(
+	group *SearchFilterGroup,
+	parser FilterQueryParser,
+	values []string,
+) error {
+	if len(values) == 0 {
+		return nil
+	}
+	// A single parameter is not a set: all filters must
+	// match, which is the default of the group.
+	if len(values) == 1 {
+		filters, err := parseQueryValueList(parser, values[0])
+		if err != nil {
+			return err
 		}
+		group.AddFilters(filters)
+		return nil
 	}
-	return queryFilters, nil
+	for _, value := range values {
+		filters, err := parseQueryValueList(parser, value)
+		if err != nil {
+			return err
+		}
+		group.AddFilterSet(filters)
+	}
+	return nil
 }
 
 // parseCommunityFilterText creates FilterValue from the
 // text input which may be a api.Community or api.ExtCommunity.
-func parseCommunityFilterText(text string) (string, *SearchFilter, error) {
+//
+// Community aliases of the context are expanded
+// before parsing.
+//
+// If the text can not be parsed, the error will include
+// a suggestion in case the input looks like a community
+// with mistyped separators. (e.g. 65000;100)
+func (c *FilterContext) parseCommunityFilterText(text string) (string, *SearchFilter, error) {
+	text = c.CommunityAliases.Expand(text)
+	key, filter, err := parseCommunityFilterTokens(text)
+	if err == nil {
+		return key, filter, nil
+	}
+	if suggestion := suggestCommunityFilterText(text); suggestion != "" {
+		return "", nil, fmt.Errorf(
+			"%w, did you mean %s?", err, suggestion)
+	}
+	return "", nil, err
+}
+
+// parseCommunityFilterTokens parses the community filter text
+func parseCommunityFilterTokens(text string) (string, *SearchFilter, error) {
 	tokens := strings.Split(text, ":")
 	if len(tokens) < 2 {
 		return "", nil, fmt.Errorf("BGP community incomplete")
@@ -602,7 +2096,7 @@ func parseCommunityFilterText(text string) (string, *SearchFilter, error) {
 	// Check if we are dealing with an ext. community
 	maybeExt := false
 	_, err := strconv.Atoi(tokens[0])
-	if err != nil {
+	if err != nil && tokens[0] != "*" {
 		maybeExt = true
 	}
 
@@ -627,25 +2121,139 @@ func parseCommunityFilterText(text string) (string, *SearchFilter, error) {
 	return SearchKeyLargeCommunities, filter, nil
 }
 
-// FiltersFromTokens parses the passed list of filters
-// extracted from the query string and creates the filter.
-func FiltersFromTokens(tokens []string) (*SearchFilters, error) {
-	queryFilters := NewSearchFilters()
+// TokenFilters are the filters parsed from the
+// tokens of a free text search.
+type TokenFilters struct {
+	Filters *SearchFilters
+
+	// NeighborName is the query for the neighbor
+	// name, built from the plain words.
+	NeighborName string
+
+	// Ignored tokens could not be assigned to a filter.
+	Ignored []string
+}
+
+// neighborNameToken matches plain words
+var neighborNameToken = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}._-]*$`)
+
+// ParseFilterTokens parses the tokens of a free text
+// search. The grammar of a token is:
+//
+//	#<community>     a standard, large or extended community
+//	@<ip address>    a peer address
+//	@<source id>     a route server, if not an address
+//	<asn>, AS<asn>   an ASN, e.g. 2342 or AS2342
+//	<word>           a word of the neighbor name
+//
+// Tokens not matching the grammar are ignored and
+// collected. Malformed communities and empty prefixed
+// tokens are an error.
+func ParseFilterTokens(tokens []string) (*TokenFilters, error) {
+	return (&FilterContext{}).ParseFilterTokens(tokens)
+}
+
+// ParseFilterTokens parses the tokens of a free text
+// search, see the package level ParseFilterTokens.
+// Aliases of the context are expanded in community tokens.
+func (c *FilterContext) ParseFilterTokens(tokens []string) (*TokenFilters, error) {
+	result := &TokenFilters{
+		Filters: c.newSearchFilters(),
+		Ignored: []string{},
+	}
+	words := []string{}
 	for _, value := range tokens {
-		if strings.HasPrefix(value, "#") { // Community query
-			key, filter, err := parseCommunityFilterText(value[1:])
+		if value == "" {
+			continue
+		}
+		if text, ok := strings.CutPrefix(value, "#"); ok { // Community query
+			key, filter, err := c.parseCommunityFilterText(text)
+			if err != nil {
+				return nil, err
+			}
+			result.Filters.GetGroupByKey(key).AddFilter(filter)
+			continue
+		}
+		if text, ok := strings.CutPrefix(value, "@"); ok { // Peer or source
+			if text == "" {
+				return nil, ErrInvalidIPAddr
+			}
+			if !looksLikeIPAddr(text) {
+				filter, _ := parseStringValue(text)
+				result.Filters.GetGroupByKey(SearchKeySources).AddFilter(filter)
+				continue
+			}
+			filter, err := parseIPAddrValue(text)
 			if err != nil {
 				return nil, err
 			}
-			queryFilters.GetGroupByKey(key).AddFilter(filter)
+			result.Filters.GetGroupByKey(SearchKeyPeerAddress).AddFilter(filter)
+			continue
+		}
+		if asn, ok := parseASNToken(value); ok {
+			result.Filters.GetGroupByKey(SearchKeyASNS).AddFilter(&SearchFilter{
+				Value: asn,
+			})
+			continue
+		}
+		if neighborNameToken.MatchString(value) {
+			words = append(words, value)
+			continue
 		}
+		result.Ignored = append(result.Ignored, value)
+	}
+	result.NeighborName = strings.Join(words, " ")
+	return result, nil
+}
 
+// looksLikeIPAddr checks if the value is meant to be
+// an IP address, consisting of digits and dots or
+// containing a colon.
+func looksLikeIPAddr(value string) bool {
+	if strings.Contains(value, ":") {
+		return true
 	}
-	return queryFilters, nil
+	return strings.Trim(value, "0123456789.") == ""
+}
+
+// parseASNToken parses an ASN with an optional
+// AS prefix, e.g. AS2342.
+func parseASNToken(value string) (int, bool) {
+	if len(value) > 2 && strings.EqualFold(value[:2], "as") {
+		value = value[2:]
+	}
+	asn, err := strconv.Atoi(value)
+	if err != nil || asn <= 0 || strings.HasPrefix(value, "+") {
+		return 0, false
+	}
+	return asn, true
+}
+
+// FiltersFromTokens parses the passed list of filters
+// extracted from the query string and creates the filter.
+//
+// See ParseFilterTokens for the grammar of the tokens.
+// Neighbor names and ignored tokens are dropped.
+func FiltersFromTokens(tokens []string) (*SearchFilters, error) {
+	return (&FilterContext{}).FiltersFromTokens(tokens)
+}
+
+// FiltersFromTokens parses the tokens like the package
+// level FiltersFromTokens, expanding community aliases.
+func (c *FilterContext) FiltersFromTokens(tokens []string) (*SearchFilters, error) {
+	result, err := c.ParseFilterTokens(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return result.Filters, nil
 }
 
 // MatchRoute checks if a route matches all filters.
 // Unless all filters are blank.
+//
+// For multi path routes the community filters must
+// be satisfied by at least one of the paths.
+// See MatchCommunitiesAnyPath.
 func (s *SearchFilters) MatchRoute(r Filterable) bool {
 	sources := s.GetGroupByKey(SearchKeySources)
 	if !sources.MatchAny(r) {
@@ -657,45 +2265,337 @@ func (s *SearchFilters) MatchRoute(r Filterable) bool {
 		return false
 	}
 
+	if !s.MatchCommunitiesAnyPath(r) {
+		return false
+	}
+
+	addrFamily := s.GetGroupByKey(SearchKeyAddrFamily)
+	if !addrFamily.MatchAny(r) {
+		return false
+	}
+
+	nextHopSelf := s.GetGroupByKey(SearchKeyNextHopSelf)
+	if !nextHopSelf.MatchAny(r) {
+		return false
+	}
+
+	rejectStatus := s.GetGroupByKey(SearchKeyRejectStatus)
+	if !rejectStatus.MatchAny(r) {
+		return false
+	}
+
+	prefixLength := s.GetGroupByKey(SearchKeyPrefixLength)
+	if !prefixLength.MatchAny(r) {
+		return false
+	}
+
+	rpkiStatus := s.GetGroupByKey(SearchKeyRpkiStatus)
+	if !rpkiStatus.MatchAny(r) {
+		return false
+	}
+
+	nextHop := s.GetGroupByKey(SearchKeyNextHop)
+	if !nextHop.MatchAny(r) {
+		return false
+	}
+
+	originASN := s.GetGroupByKey(SearchKeyOriginASN)
+	if !originASN.MatchAny(r) {
+		return false
+	}
+
+	asPathLength := s.GetGroupByKey(SearchKeyASPathLength)
+	if !asPathLength.MatchAny(r) {
+		return false
+	}
+
+	peerAddress := s.GetGroupByKey(SearchKeyPeerAddress)
+	if !peerAddress.MatchAny(r) {
+		return false
+	}
+
+	blackhole := s.GetGroupByKey(SearchKeyBlackhole)
+	if !blackhole.MatchAny(r) {
+		return false
+	}
+
+	med := s.GetGroupByKey(SearchKeyMed)
+	if !med.MatchAny(r) {
+		return false
+	}
+
+	localPref := s.GetGroupByKey(SearchKeyLocalPref)
+	if !localPref.MatchAny(r) {
+		return false
+	}
+
+	otc := s.GetGroupByKey(SearchKeyOTC)
+	if !otc.MatchAny(r) {
+		return false
+	}
+
+	communityLabel := s.GetGroupByKey(SearchKeyCommunityLabel)
+	if !communityLabel.MatchAny(r) {
+		return false
+	}
+
+	prefix := s.GetGroupByKey(SearchKeyPrefix)
+	if !prefix.MatchAny(r) {
+		return false
+	}
+
+	return true
+}
+
+// MatchRoutes returns the routes matching all filters,
+// preserving the order of the input.
+//
+// The routes are split into chunks, which are matched
+// concurrently by the workers. For workers <= 0,
+// GOMAXPROCS workers are used.
+// The filters must not be modified while matching.
+func MatchRoutes[S ~[]E, E Filterable](
+	filters *SearchFilters,
+	routes S,
+	workers int,
+) S {
+	if filters.isBlank() {
+		return slices.Clone(routes)
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(routes))
+	if workers <= 1 {
+		return matchRoutes(filters, routes)
+	}
+
+	chunkSize := (len(routes) + workers - 1) / workers
+	results := make([]S, workers)
+	wg := sync.WaitGroup{}
+	for i := range workers {
+		start := min(i*chunkSize, len(routes))
+		end := min(start+chunkSize, len(routes))
+		wg.Add(1)
+		go func(chunk S) {
+			defer wg.Done()
+			results[i] = matchRoutes(filters, chunk)
+		}(routes[start:end])
+	}
+	wg.Wait()
+
+	return slices.Concat(results...)
+}
+
+// matchRoutesCheckInterval is the number of routes matched
+// by MatchRoutesCtx before checking if the context is done.
+const matchRoutesCheckInterval = 4096
+
+// MatchRoutesCtx returns the routes matching all filters,
+// preserving the order of the input. Matching is stopped
+// if the context is done, e.g. when the client disconnected.
+// In this case, the error of the context is returned.
+func MatchRoutesCtx[S ~[]E, E Filterable](
+	ctx context.Context,
+	filters *SearchFilters,
+	routes S,
+) (S, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	matched := make(S, 0, len(routes))
+	for i, r := range routes {
+		if i > 0 && i%matchRoutesCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if filters.MatchRoute(r) {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
+
+// matchRoutes returns the matching routes of the chunk
+func matchRoutes[S ~[]E, E Filterable](filters *SearchFilters, routes S) S {
+	matched := make(S, 0, len(routes))
+	for _, r := range routes {
+		if filters.MatchRoute(r) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// isBlank checks if none of the groups has a filter
+func (s *SearchFilters) isBlank() bool {
+	for _, group := range *s {
+		if len(group.Filters) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCommunities checks if all community, ext. community
+// and large community filters match.
+func (s *SearchFilters) matchCommunities(r Filterable) bool {
 	communities := s.GetGroupByKey(SearchKeyCommunities)
-	if !communities.MatchAll(r) {
+	if !communities.matchOp(r) {
 		return false
 	}
 
 	extCommunities := s.GetGroupByKey(SearchKeyExtCommunities)
-	if !extCommunities.MatchAll(r) {
+	if !extCommunities.matchOp(r) {
 		return false
 	}
 
 	largeCommunities := s.GetGroupByKey(SearchKeyLargeCommunities)
-	if !largeCommunities.MatchAll(r) {
+	if !largeCommunities.matchOp(r) {
 		return false
 	}
 
-	addrFamily := s.GetGroupByKey(SearchKeyAddrFamily)
-	if !addrFamily.MatchAny(r) {
-		return false
+	return true
+}
+
+// MatchCommunitiesAnyPath checks if any path of a
+// MultiPathFilterable satisfies all community filters.
+// The communities of different paths are not mixed:
+// a single path must carry all required communities.
+//
+// Routes with a single path (or without any paths
+// reported) fall back to matching the route itself.
+func (s *SearchFilters) MatchCommunitiesAnyPath(r Filterable) bool {
+	mp, ok := r.(MultiPathFilterable)
+	if !ok {
+		return s.matchCommunities(r)
+	}
+	paths := mp.Paths()
+	if len(paths) == 0 {
+		return s.matchCommunities(r)
 	}
+	for _, p := range paths {
+		if s.matchCommunities(p) {
+			return true
+		}
+	}
+	return false
+}
 
-	return true
+// MatchedFilters returns the filters of each group
+// satisfied by the route, keyed by the group key.
+// Groups without any matching filter are omitted.
+//
+// This is intended to be used after MatchRoute to
+// explain why a route matched. Paths of multi path
+// routes are not considered.
+func (s *SearchFilters) MatchedFilters(r Filterable) map[string][]*SearchFilter {
+	matched := make(map[string][]*SearchFilter)
+	for _, group := range *s {
+		cmp := selectCmpFuncByKey(group.Key, group.fctx)
+		if cmp == nil {
+			continue
+		}
+		for _, filter := range group.Filters {
+			if filter.Negate {
+				continue
+			}
+			if cmp(r, filter.Value) {
+				matched[group.Key] = append(matched[group.Key], filter)
+			}
+		}
+	}
+	return matched
+}
+
+// Clone makes a deep copy of the search filters.
+// The groups and filters of the copy can be modified
+// independently, e.g. by MergeProperties, and are safe
+// to use concurrently with the original.
+// Filter values are considered immutable and are shared.
+func (s *SearchFilters) Clone() *SearchFilters {
+	result := make(SearchFilters, len(*s))
+	for i, group := range *s {
+		result[i] = group.clone()
+	}
+	return &result
+}
+
+// clone makes a deep copy of the group and its filters
+func (g *SearchFilterGroup) clone() *SearchFilterGroup {
+	copies := make(map[*SearchFilter]*SearchFilter, len(g.Filters))
+	copyFilter := func(f *SearchFilter) *SearchFilter {
+		if c, ok := copies[f]; ok {
+			return c
+		}
+		c := *f
+		copies[f] = &c
+		return &c
+	}
+
+	filters := make([]*SearchFilter, 0, len(g.Filters))
+	for _, f := range g.Filters {
+		filters = append(filters, copyFilter(f))
+	}
+	var filterSets [][]*SearchFilter
+	for _, set := range g.filterSets {
+		c := make([]*SearchFilter, 0, len(set))
+		for _, f := range set {
+			c = append(c, copyFilter(f))
+		}
+		filterSets = append(filterSets, c)
+	}
+
+	clone := &SearchFilterGroup{
+		Key:        g.Key,
+		Filters:    filters,
+		Op:         g.Op,
+		filterSets: filterSets,
+	}
+	if g.mu != nil {
+		clone.mu = &sync.Mutex{}
+	}
+	clone.fctx = g.fctx
+	clone.rebuildIndex()
+	return clone
 }
 
-// Combine two search filters
+// Combine two search filters. The cardinalities
+// of filters present on both sides are summed up.
+//
+// Groups are matched by key, so the order of the groups
+// may differ. Groups only present in other are ignored.
+// Filter sets are combined, so a route must match
+// the sets of both sides.
 func (s *SearchFilters) Combine(other *SearchFilters) *SearchFilters {
 	result := make(SearchFilters, len(*s))
 	for id, group := range *s {
-		otherGroup := (*other)[id]
+		otherGroup := other.groupOrEmpty(group.Key)
 		combined := &SearchFilterGroup{
-			Key:     group.Key,
-			Filters: []*SearchFilter{},
+			Key:        group.Key,
+			Filters:    []*SearchFilter{},
+			filtersIdx: make(map[filterKey]int),
+			Op:         group.Op,
+			fctx:       group.fctx,
 		}
-		combined.Filters = append(combined.Filters, group.Filters...)
-		for _, f := range otherGroup.Filters {
-			if combined.Contains(f) {
-				continue
+		if combined.fctx == nil {
+			combined.fctx = otherGroup.fctx
+		}
+		for _, filters := range [][]*SearchFilter{
+			group.Filters, otherGroup.Filters,
+		} {
+			for _, f := range filters {
+				if present := combined.getIndexedFilter(f); present != nil {
+					present.Cardinality += f.Cardinality
+					continue
+				}
+				filter := *f // copy, the cardinality is modified
+				combined.Filters = append(combined.Filters, &filter)
+				combined.filtersIdx[filterIndexKey(f)] = len(combined.Filters) - 1
 			}
-			combined.Filters = append(combined.Filters, f)
 		}
+		combined.filterSets = combined.combineFilterSets(group, otherGroup)
 		combined.rebuildIndex()
 		result[id] = combined
 	}
@@ -703,15 +2603,48 @@ func (s *SearchFilters) Combine(other *SearchFilters) *SearchFilters {
 	return &result
 }
 
+// combineFilterSets makes the filter sets of the
+// combination of a and b: the sets of both groups, where
+// each filter of a group without filter sets is a set of
+// its own. The filters of the sets are looked up in the
+// combined group.
+func (g *SearchFilterGroup) combineFilterSets(
+	a, b *SearchFilterGroup,
+) [][]*SearchFilter {
+	if len(a.filterSets) == 0 && len(b.filterSets) == 0 {
+		return nil
+	}
+	setsOf := func(group *SearchFilterGroup) [][]*SearchFilter {
+		if len(group.filterSets) > 0 {
+			return group.filterSets
+		}
+		sets := make([][]*SearchFilter, 0, len(group.Filters))
+		for _, f := range group.Filters {
+			sets = append(sets, []*SearchFilter{f})
+		}
+		return sets
+	}
+	var sets [][]*SearchFilter
+	for _, set := range slices.Concat(setsOf(a), setsOf(b)) {
+		filters := make([]*SearchFilter, 0, len(set))
+		for _, f := range set {
+			filters = append(filters, g.getIndexedFilter(f))
+		}
+		sets = append(sets, filters)
+	}
+	return sets
+}
+
 // Sub makes a diff of two search filters
 func (s *SearchFilters) Sub(other *SearchFilters) *SearchFilters {
 	result := make(SearchFilters, len(*s))
 
 	for id, group := range *s {
-		otherGroup := (*other)[id]
+		otherGroup := other.groupOrEmpty(group.Key)
 		diff := &SearchFilterGroup{
 			Key:     group.Key,
 			Filters: []*SearchFilter{},
+			Op:      group.Op,
 		}
 
 		// Combine filters
@@ -729,10 +2662,44 @@ func (s *SearchFilters) Sub(other *SearchFilters) *SearchFilters {
 	return &result
 }
 
+// SymmetricDiff makes a diff of two search filters
+// containing the filters present on exactly one side.
+// The cardinalities of the filters are preserved.
+func (s *SearchFilters) SymmetricDiff(other *SearchFilters) *SearchFilters {
+	result := make(SearchFilters, len(*s))
+
+	for id, group := range *s {
+		otherGroup := other.groupOrEmpty(group.Key)
+		diff := &SearchFilterGroup{
+			Key:     group.Key,
+			Filters: []*SearchFilter{},
+			Op:      group.Op,
+		}
+
+		for _, f := range group.Filters {
+			if otherGroup.getIndexedFilter(f) != nil {
+				continue
+			}
+			diff.Filters = append(diff.Filters, f)
+		}
+		for _, f := range otherGroup.Filters {
+			if group.getIndexedFilter(f) != nil {
+				continue
+			}
+			diff.Filters = append(diff.Filters, f)
+		}
+
+		diff.rebuildIndex()
+		result[id] = diff
+	}
+
+	return &result
+}
+
 // MergeProperties merges two search filters
 func (s *SearchFilters) MergeProperties(other *SearchFilters) {
-	for id, group := range *s {
-		otherGroup := (*other)[id]
+	for _, group := range *s {
+		otherGroup := other.groupOrEmpty(group.Key)
 		for _, filter := range group.Filters {
 			otherFilter := otherGroup.FindFilter(filter)
 			if otherFilter == nil {
@@ -752,32 +2719,62 @@ func (s *SearchFilters) HasGroup(key string) bool {
 	return len(group.Filters) > 0
 }
 
-// A NeighborFilter includes only a name and ASN.
+// A NeighborFilter includes only a name and ASNs.
 // We are using a slightly simpler solution for
 // neighbor queries.
 type NeighborFilter struct {
 	name string
-	asn  int
+	asns []int
+	op   string
+
+	// namePattern is used for matching the name,
+	// if the name is a regular expression.
+	namePattern *regexp.Regexp
+
+	// invalid filters do not match any neighbor
+	invalid bool
 }
 
 // NeighborFilterFromQuery constructs a NeighborFilter
 // from query parameters.
 //
 // Right now we support filtering by name (partial match)
-// and ASN.
+// and ASN. A name wrapped in slashes, e.g. /cust-\d+-lon/,
+// is matched as regular expression.
 //
 // The latter is used to find related peers on all route servers.
+// Multiple ASNs can be passed as a list, e.g. asn=2914,3356.
+//
+// A neighbor matches if the name or any ASN matches. With
+// match=all, both must match.
 func NeighborFilterFromQuery(q url.Values) *NeighborFilter {
-	asn := 0
-	name := q.Get("name")
-	asnVal := q.Get("asn")
-	if asnVal != "" {
-		asn, _ = strconv.Atoi(asnVal)
+	name := strings.TrimSpace(q.Get("name"))
+	asns := []int{}
+	for _, v := range strings.Split(q.Get("asn"), ",") {
+		asn, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || asn <= 0 {
+			continue
+		}
+		asns = append(asns, asn)
+	}
+
+	op, err := parseFilterOp(q.Get("match"))
+	if err != nil {
+		op = SearchFilterOpAny
 	}
 
 	filter := &NeighborFilter{
 		name: name,
-		asn:  asn,
+		asns: asns,
+		op:   op,
+	}
+	if len(name) > 2 && strings.HasPrefix(name, "/") && strings.HasSuffix(name, "/") {
+		pattern, err := regexp.Compile(name[1 : len(name)-1])
+		if err != nil {
+			log.Println("Invalid neighbor name pattern:", name, err)
+			filter.invalid = true
+		}
+		filter.namePattern = pattern
 	}
 	return filter
 }
@@ -794,11 +2791,31 @@ func NeighborFilterFromQueryString(q string) *NeighborFilter {
 // Match neighbor with filter: Check if the neighbor
 // in question has the required parameters.
 func (s *NeighborFilter) Match(neighbor *Neighbor) bool {
-	if s.name != "" && neighbor.MatchName(s.name) {
+	if s.invalid {
+		return false
+	}
+	if s.op == SearchFilterOpAll && s.name != "" && len(s.asns) > 0 {
+		return s.matchName(neighbor) && s.matchASN(neighbor)
+	}
+	if s.name != "" && s.matchName(neighbor) {
 		return true
 	}
-	if s.asn > 0 && neighbor.MatchASN(s.asn) {
+	if s.matchASN(neighbor) {
 		return true
 	}
 	return false
 }
+
+// matchASN checks if the neighbor ASN is in the set
+func (s *NeighborFilter) matchASN(neighbor *Neighbor) bool {
+	return slices.ContainsFunc(s.asns, neighbor.MatchASN)
+}
+
+// matchName matches the neighbor name with the pattern
+// or tokens of the name.
+func (s *NeighborFilter) matchName(neighbor *Neighbor) bool {
+	if s.namePattern != nil {
+		return s.namePattern.MatchString(neighbor.Description)
+	}
+	return neighbor.MatchName(s.name)
+}
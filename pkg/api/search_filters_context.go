@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/url"
+)
+
+// A FilterContext provides the configuration used
+// when parsing search filters and matching routes.
+// Filters parsed by a context are matched using
+// its configuration.
+//
+// The package level parsers, e.g. FiltersFromQuery,
+// use an empty context.
+type FilterContext struct {
+	// CommunityAliases are expanded when parsing standard
+	// community filters, e.g. blackhole = 65535:666.
+	CommunityAliases CommunityAliases
+
+	// QueryDefaults are applied by NormalizeQuery for
+	// filters missing in the query, e.g. addr_family=1.
+	QueryDefaults url.Values
+
+	// CommunityLabels are searched by community_label.
+	CommunityLabels BGPCommunityMap
+
+	// BlackholeCommunities mark a route as blackhole.
+	BlackholeCommunities BGPCommunitiesSet
+
+	// RpkiCommunities indicate the RPKI status of a route.
+	RpkiCommunities RpkiCommunities
+
+	// ASPathLengthIgnorePrepends counts consecutive
+	// duplicate ASNs as one hop in as_path_length.
+	ASPathLengthIgnorePrepends bool
+
+	// ASNNamer labels ASN filters without a name,
+	// see SearchFilters.ResolveASNNames. It is optional
+	// and not part of the config file.
+	ASNNamer ASNNamer
+}
+
+// emptyFilterContext is used for matching filters
+// not created by a context, e.g. by NewSearchFilters.
+var emptyFilterContext FilterContext
+
+// newSearchFilters creates empty search filters
+// bound to the context.
+func (c *FilterContext) newSearchFilters() *SearchFilters {
+	filters := NewSearchFilters()
+	for _, group := range *filters {
+		group.fctx = c
+	}
+	return filters
+}
+
+// parseCommunityAliasValue parses a standard community
+// filter value, which may be an alias.
+func (c *FilterContext) parseCommunityAliasValue(value string) (*SearchFilter, error) {
+	return parseCommunityValue(c.CommunityAliases.Expand(value))
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Filter value types are recorded when encoding a
+// search filter, so the value can be reconstructed.
+const (
+	FilterValueTypeInt            = "int"
+	FilterValueTypeBool           = "bool"
+	FilterValueTypeString         = "string"
+	FilterValueTypeCommunity      = "community"
+	FilterValueTypeExtCommunity   = "ext_community"
+	FilterValueTypeCommunityRange = "community_range"
+	FilterValueTypeIntRange       = "int_range"
+	FilterValueTypeIntComparison  = "int_comparison"
+	FilterValueTypePrefix         = "prefix"
+)
+
+// searchFilterJSON is the encoded representation
+// of a search filter.
+type searchFilterJSON struct {
+	Cardinality int             `json:"cardinality"`
+	Name        string          `json:"name"`
+	Value       json.RawMessage `json:"value"`
+	Type        string          `json:"type,omitempty"`
+	Negate      bool            `json:"negate,omitempty"`
+}
+
+// filterValueType gets the type of the filter value
+func filterValueType(value any) string {
+	switch value.(type) {
+	case int:
+		return FilterValueTypeInt
+	case bool:
+		return FilterValueTypeBool
+	case string, *string:
+		return FilterValueTypeString
+	case Community:
+		return FilterValueTypeCommunity
+	case ExtCommunity:
+		return FilterValueTypeExtCommunity
+	case BGPCommunityRange:
+		return FilterValueTypeCommunityRange
+	case IntRange:
+		return FilterValueTypeIntRange
+	case IntComparison:
+		return FilterValueTypeIntComparison
+	case PrefixMatch:
+		return FilterValueTypePrefix
+	}
+	return ""
+}
+
+// MarshalJSON encodes the search filter including
+// the type of the value.
+func (f SearchFilter) MarshalJSON() ([]byte, error) {
+	value, err := json.Marshal(f.Value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(searchFilterJSON{
+		Cardinality: f.Cardinality,
+		Name:        f.Name,
+		Value:       value,
+		Type:        filterValueType(f.Value),
+		Negate:      f.Negate,
+	})
+}
+
+// UnmarshalJSON decodes the search filter and
+// reconstructs the value from the recorded type.
+// Values without a type are decoded as is.
+func (f *SearchFilter) UnmarshalJSON(data []byte) error {
+	encoded := searchFilterJSON{}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	value, err := decodeFilterValue(encoded.Type, encoded.Value)
+	if err != nil {
+		return err
+	}
+	*f = SearchFilter{
+		Cardinality: encoded.Cardinality,
+		Name:        encoded.Name,
+		Value:       value,
+		Negate:      encoded.Negate,
+	}
+	return nil
+}
+
+// decodeFilterValue decodes the filter value by type
+func decodeFilterValue(kind string, data json.RawMessage) (FilterValue, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	switch kind {
+	case FilterValueTypeInt:
+		return decodeJSONValue[int](data)
+	case FilterValueTypeBool:
+		return decodeJSONValue[bool](data)
+	case FilterValueTypeString:
+		return decodeJSONValue[string](data)
+	case FilterValueTypeCommunity:
+		return decodeJSONValue[Community](data)
+	case FilterValueTypeExtCommunity:
+		return decodeExtCommunityValue(data)
+	case FilterValueTypeCommunityRange:
+		return decodeCommunityRangeValue(data)
+	case FilterValueTypeIntRange:
+		return decodeIntRangeValue(data)
+	case FilterValueTypeIntComparison:
+		return decodeIntComparisonValue(data)
+	case FilterValueTypePrefix:
+		return decodePrefixValue(data)
+	case "":
+		return decodeJSONValue[any](data)
+	}
+	return nil, fmt.Errorf("unknown filter value type: %s", kind)
+}
+
+func decodeJSONValue[T any](data json.RawMessage) (FilterValue, error) {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeExtCommunityValue decodes an ext community
+// with a string type and integer components.
+func decodeExtCommunityValue(data json.RawMessage) (FilterValue, error) {
+	components := []json.RawMessage{}
+	if err := json.Unmarshal(data, &components); err != nil {
+		return nil, err
+	}
+	community := make(ExtCommunity, 0, len(components))
+	for i, c := range components {
+		var v any
+		var err error
+		if i == 0 {
+			v, err = decodeJSONValue[string](c)
+		} else {
+			v, err = decodeJSONValue[int](c)
+		}
+		if err != nil {
+			return nil, err
+		}
+		community = append(community, v)
+	}
+	return community, nil
+}
+
+// decodeCommunityRangeValue decodes a community range,
+// where the bounds are either a pair of strings or
+// a pair of integers.
+func decodeCommunityRangeValue(data json.RawMessage) (FilterValue, error) {
+	components := []json.RawMessage{}
+	if err := json.Unmarshal(data, &components); err != nil {
+		return nil, err
+	}
+	community := make(BGPCommunityRange, 0, len(components))
+	for _, c := range components {
+		bounds, err := decodeJSONValue[[]int](c)
+		if err != nil {
+			bounds, err = decodeJSONValue[[]string](c)
+		}
+		if err != nil {
+			return nil, err
+		}
+		community = append(community, bounds)
+	}
+	return community, nil
+}
+
+// decodeIntRangeValue decodes a range encoded
+// as text, e.g. 20-24.
+func decodeIntRangeValue(data json.RawMessage) (FilterValue, error) {
+	text := ""
+	if err := json.Unmarshal(data, &text); err != nil {
+		return nil, err
+	}
+	return parseIntRangeValue(text)
+}
+
+// decodeIntComparisonValue decodes a comparison
+// encoded as text, e.g. >=100.
+func decodeIntComparisonValue(data json.RawMessage) (FilterValue, error) {
+	text := ""
+	if err := json.Unmarshal(data, &text); err != nil {
+		return nil, err
+	}
+	filter, err := parseComparableIntValue(text)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Value, nil
+}
+
+// decodePrefixValue decodes a prefix match
+// encoded as text, e.g. exact:10.0.0.0/8.
+func decodePrefixValue(data json.RawMessage) (FilterValue, error) {
+	text := ""
+	if err := json.Unmarshal(data, &text); err != nil {
+		return nil, err
+	}
+	filter, err := parsePrefixValue(text)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Value, nil
+}
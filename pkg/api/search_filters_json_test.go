@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+)
+
+func TestSearchFilterJSONRoundTrip(t *testing.T) {
+	rsID := "rs1"
+	filters := []*SearchFilter{
+		{Name: "AS2342", Value: 2342, Cardinality: 23},
+		{Name: "rs1", Value: &rsID},
+		{Name: "rejected", Value: RejectStatusRejected},
+		{Value: true, Negate: true},
+		{Name: "65000:100", Value: Community{65000, 100}},
+		{Name: "65000:*:1", Value: Community{65000, CommunityWildcard, 1}},
+		{Name: "rt:65000:100", Value: ExtCommunity{"rt", 65000, 100}},
+		{Value: BGPCommunityRange{[]int{65000, 65000}, []int{100, 200}}},
+		{Value: BGPCommunityRange{
+			[]string{"rt", "rt"}, []int{1, 1}, []int{100, 200}}},
+		{Value: IntRange{Min: 20, Max: 24}},
+		{Value: IntComparison{Op: CmpOpGe, Value: 100}},
+	}
+	prefix, err := parsePrefixValue("covering:2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filters = append(filters, prefix)
+
+	for _, filter := range filters {
+		data, err := json.Marshal(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := &SearchFilter{}
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Equal(filter) || !filter.Equal(decoded) {
+			t.Error("expected", filter.Value, "to round trip, got:",
+				decoded.Value, string(data))
+		}
+		if decoded.Name != filter.Name ||
+			decoded.Cardinality != filter.Cardinality ||
+			decoded.Negate != filter.Negate {
+			t.Error("unexpected filter properties:", decoded)
+		}
+	}
+}
+
+func TestSearchFilterJSONGroup(t *testing.T) {
+	filters := NewSearchFilters()
+	filters.UpdateFromLookupRoute(makeTestLookupRoute())
+
+	data, err := json.Marshal(filters.GetGroupByKey(SearchKeyExtCommunities))
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := &SearchFilterGroup{}
+	if err := json.Unmarshal(data, group); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := group.Filters[0].Value.(ExtCommunity); !ok {
+		t.Errorf("expected ext community, got: %T", group.Filters[0].Value)
+	}
+}
+
+func TestSearchFilterJSONUntyped(t *testing.T) {
+	filter := &SearchFilter{}
+	err := json.Unmarshal([]byte(`{"name": "foo", "value": "bar"}`), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Value != "bar" {
+		t.Error("unexpected value:", filter.Value)
+	}
+
+	err = json.Unmarshal([]byte(`{"value": 1, "type": "foo"}`), filter)
+	if err == nil {
+		t.Error("expected error for unknown type")
+	}
+}
+
+func TestSearchFilterGroupOpJSON(t *testing.T) {
+	filters := NewSearchFilters()
+	data, err := json.Marshal(filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := []map[string]any{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	ops := map[string]any{}
+	for _, group := range decoded {
+		ops[group["key"].(string)] = group["op"]
+	}
+	if ops[SearchKeyCommunities] != SearchFilterOpAll {
+		t.Error("expected communities to match all, got:", ops[SearchKeyCommunities])
+	}
+	if ops[SearchKeySources] != SearchFilterOpAny {
+		t.Error("expected sources to match any, got:", ops[SearchKeySources])
+	}
+
+	values, _ := url.ParseQuery("communities=1:2&communities_op=any")
+	filters, err = FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op := filters.GetGroupByKey(SearchKeyLargeCommunities).Op; op != SearchFilterOpAny {
+		t.Error("expected configured operator, got:", op)
+	}
+}
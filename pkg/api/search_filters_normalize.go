@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Validation errors
+var (
+	ErrASNOutOfRange        = errors.New("asn out of range")
+	ErrCommunityOutOfRange  = errors.New("community out of range")
+	ErrInvalidAddrFamily    = errors.New("invalid address family")
+	ErrUnexpectedFilterType = errors.New("unexpected filter value")
+)
+
+const (
+	maxASN                 = 4294967295
+	maxCommunityValue      = 65535
+	maxLargeCommunityValue = 4294967295
+)
+
+// queryParamsIgnored are query parameters which are
+// not filters, but are expected in a query.
+var queryParamsIgnored = []string{
+	"q", "page", "page_imported", "page_filtered",
+}
+
+// A searchFilterValidator checks if a filter value is
+// within the valid range.
+type searchFilterValidator func(value any) error
+
+// searchFilterValidators by search key
+var searchFilterValidators = map[string]searchFilterValidator{
+	SearchKeyASNS:             validateASNValue,
+	SearchKeyCommunities:      validateCommunityValue,
+	SearchKeyLargeCommunities: validateLargeCommunityValue,
+	SearchKeyAddrFamily:       validateAddrFamilyValue,
+}
+
+func validateASNValue(value any) error {
+	asn, ok := value.(int)
+	if !ok {
+		return ErrUnexpectedFilterType
+	}
+	if asn <= 0 || asn > maxASN {
+		return ErrASNOutOfRange
+	}
+	return nil
+}
+
+func validateCommunityParts(value any, parts, max int) error {
+	if r, ok := value.(BGPCommunityRange); ok {
+		return validateCommunityRangeParts(r, parts, max)
+	}
+	community, ok := value.(Community)
+	if !ok {
+		return ErrUnexpectedFilterType
+	}
+	if len(community) != parts {
+		return ErrCommunityOutOfRange
+	}
+	for _, v := range community {
+		if v == CommunityWildcard {
+			continue
+		}
+		if v < 0 || v > max {
+			return ErrCommunityOutOfRange
+		}
+	}
+	return nil
+}
+
+func validateCommunityRangeParts(r BGPCommunityRange, parts, max int) error {
+	if len(r) != parts {
+		return ErrCommunityOutOfRange
+	}
+	for _, bounds := range r {
+		b, ok := bounds.([]int)
+		if !ok || len(b) != 2 {
+			return ErrUnexpectedFilterType
+		}
+		if b[0] < 0 || b[1] > max {
+			return ErrCommunityOutOfRange
+		}
+	}
+	return nil
+}
+
+func validateCommunityValue(value any) error {
+	return validateCommunityParts(value, 2, maxCommunityValue)
+}
+
+func validateLargeCommunityValue(value any) error {
+	return validateCommunityParts(value, 3, maxLargeCommunityValue)
+}
+
+func validateAddrFamilyValue(value any) error {
+	family, ok := value.(int)
+	if !ok {
+		return ErrUnexpectedFilterType
+	}
+	if family != AddrFamilyIPv4 && family != AddrFamilyIPv6 &&
+		family != AddrFamilyBoth {
+		return ErrInvalidAddrFamily
+	}
+	return nil
+}
+
+// NormalizeQuery parses the query into canonical search
+// filters, see FilterContext.NormalizeQuery. No defaults
+// are applied.
+func NormalizeQuery(query url.Values) (*SearchFilters, []string, error) {
+	return (&FilterContext{}).NormalizeQuery(query)
+}
+
+// NormalizeQuery parses the query into canonical search
+// filters. The QueryDefaults of the context are applied
+// for missing filters.
+//
+// Unknown parameters fail with an ErrUnknownFilterKey,
+// filter values which can not be parsed or are out of
+// range fail with a FilterParseError.
+// Empty parameters are dropped and reported as warnings.
+func (c *FilterContext) NormalizeQuery(
+	query url.Values,
+) (*SearchFilters, []string, error) {
+	warnings := []string{}
+	normalized := make(url.Values, len(query))
+	for key, values := range query {
+		if !isFilterKey(key) {
+			if !slices.Contains(queryParamsIgnored, key) {
+				return nil, warnings, &ErrUnknownFilterKey{Key: key}
+			}
+			continue
+		}
+		for _, value := range values {
+			if strings.TrimSpace(value) == "" {
+				warnings = append(warnings,
+					fmt.Sprintf("ignoring empty filter: %s", key))
+				continue
+			}
+			normalized[key] = append(normalized[key], value)
+		}
+	}
+
+	for key, values := range c.QueryDefaults {
+		if _, ok := normalized[key]; !ok {
+			normalized[key] = values
+		}
+	}
+
+	filters, err := c.FiltersFromQuery(normalized)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	for _, group := range *filters {
+		validate, ok := searchFilterValidators[group.Key]
+		if !ok {
+			continue
+		}
+		for _, filter := range group.Filters {
+			if err := validate(filter.Value); err != nil {
+				return nil, warnings, &FilterParseError{
+					Key:   group.Key,
+					Value: filterValueAsString(filter.Value),
+					Err:   err,
+				}
+			}
+		}
+	}
+
+	return filters, warnings, nil
+}
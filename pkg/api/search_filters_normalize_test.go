@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	fc := &FilterContext{
+		QueryDefaults: url.Values{"addr_family": {"1"}},
+	}
+
+	query, _ := url.ParseQuery(
+		"asns=2342&communities=23:42&sources=&q=foo&page=2")
+	filters, warnings, err := fc.NormalizeQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asns := filters.GetGroupByKey(SearchKeyASNS)
+	if len(asns.Filters) != 1 || asns.Filters[0].Value != 2342 {
+		t.Error("unexpected asns:", asns.Filters)
+	}
+
+	communities := filters.GetGroupByKey(SearchKeyCommunities)
+	if len(communities.Filters) != 1 || communities.Filters[0].Name != "23:42" {
+		t.Error("unexpected communities:", communities.Filters)
+	}
+
+	family := filters.GetGroupByKey(SearchKeyAddrFamily)
+	if len(family.Filters) != 1 || family.Filters[0].Value != AddrFamilyIPv4 {
+		t.Error("expected default address family, got:", family.Filters)
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "sources") {
+		t.Error("unexpected warnings:", warnings)
+	}
+
+	// Defaults are not applied if the filter is present
+	query, _ = url.ParseQuery("addr_family=2")
+	filters, _, err = fc.NormalizeQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	family = filters.GetGroupByKey(SearchKeyAddrFamily)
+	if len(family.Filters) != 1 || family.Filters[0].Value != AddrFamilyIPv6 {
+		t.Error("unexpected address family:", family.Filters)
+	}
+}
+
+func TestNormalizeQueryInvalid(t *testing.T) {
+	tests := []struct {
+		query string
+		key   string
+	}{
+		{"asns=2342,0", SearchKeyASNS},
+		{"communities=23:42,65000:70000", SearchKeyCommunities},
+		{"communities=23:42&communities=1:2:3", SearchKeyCommunities},
+		{"large_communities=1:2", SearchKeyLargeCommunities},
+		{"addr_family=5", SearchKeyAddrFamily},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		_, _, err := NormalizeQuery(query)
+		errParse, ok := err.(*FilterParseError)
+		if !ok {
+			t.Error(tt.query, ": expected parse error, got:", err)
+			continue
+		}
+		if errParse.Key != tt.key {
+			t.Error(tt.query, ": unexpected key:", errParse.Key)
+		}
+	}
+}
+
+func TestNormalizeQueryWildcards(t *testing.T) {
+	query, _ := url.ParseQuery(
+		"communities=65000:*,*:666&large_communities=65000:*:1")
+	filters, warnings, err := NormalizeQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Error("unexpected warnings:", warnings)
+	}
+
+	communities := filters.GetGroupByKey(SearchKeyCommunities)
+	if communities.GetFilterByValue(Community{65000, CommunityWildcard}) == nil {
+		t.Error("expected filter for 65000:*, got:", communities.Filters)
+	}
+	if communities.GetFilterByValue(Community{CommunityWildcard, 666}) == nil {
+		t.Error("expected filter for *:666, got:", communities.Filters)
+	}
+	large := filters.GetGroupByKey(SearchKeyLargeCommunities)
+	if len(large.Filters) != 1 {
+		t.Error("expected filter for 65000:*:1, got:", large.Filters)
+	}
+}
+
+func TestNormalizeQueryError(t *testing.T) {
+	query, _ := url.ParseQuery("asns=foo")
+	if _, _, err := NormalizeQuery(query); err == nil {
+		t.Error("expected error for unparseable asn")
+	}
+
+	query, _ = url.ParseQuery("comunities=23:42")
+	_, _, err := NormalizeQuery(query)
+	if _, ok := err.(*ErrUnknownFilterKey); !ok {
+		t.Error("expected unknown filter error, got:", err)
+	}
+}
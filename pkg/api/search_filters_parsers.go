@@ -2,6 +2,7 @@ package api
 
 import (
 	"errors"
+	"net"
 	"strconv"
 	"strings"
 )
@@ -9,20 +10,40 @@ import (
 // Errors
 var (
 	ErrExtCommunityIncomplete = errors.New("incomplete extended community")
+	ErrInvalidRejectStatus    = errors.New(
+		"invalid status, expected 'rejected' or 'accepted'")
+	ErrInvalidPrefixLength = errors.New(
+		"invalid prefix length, expected 0-128")
+	ErrInvalidRpkiStatus = errors.New(
+		"invalid rpki status, expected valid, invalid, unknown or not_checked")
+	ErrInvalidIPAddr       = errors.New("invalid ip address")
+	ErrInvalidASPathLength = errors.New(
+		"invalid as path length, expected a positive number")
+	ErrInvalidFilterOp = errors.New(
+		"invalid operator, expected 'any' or 'all'")
+	ErrEmptyCommunityLabel = errors.New("empty community label")
+	ErrInvalidPrefix       = errors.New(
+		"invalid prefix, expected CIDR notation, e.g. 10.0.0.0/8")
+	ErrInvalidPrefixMatchMode = errors.New(
+		"invalid prefix match, expected exact, within or covering")
 )
 
 // FilterQueryParser parses a filter value into a search filter
 type FilterQueryParser func(value string) (*SearchFilter, error)
 
+// parseQueryValueList parses a comma separated list
+// of values. Values with a leading '!' are negated.
 func parseQueryValueList(parser FilterQueryParser, value string) ([]*SearchFilter, error) {
 	components := strings.Split(value, ",")
 	result := make([]*SearchFilter, 0, len(components))
 
 	for _, component := range components {
-		filter, err := parser(strings.TrimSpace(component))
+		component, negate := strings.CutPrefix(strings.TrimSpace(component), "!")
+		filter, err := parser(component)
 		if err != nil {
 			return result, err
 		}
+		filter.Negate = negate
 		result = append(result, filter)
 	}
 
@@ -40,6 +61,188 @@ func parseIntValue(value string) (*SearchFilter, error) {
 	}, nil
 }
 
+func parseBoolValue(value string) (*SearchFilter, error) {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchFilter{
+		Value: v,
+	}, nil
+}
+
+func parseRejectStatusValue(value string) (*SearchFilter, error) {
+	value = strings.ToLower(value)
+	if value != RejectStatusRejected && value != RejectStatusAccepted {
+		return nil, ErrInvalidRejectStatus
+	}
+	return &SearchFilter{
+		Name:  value,
+		Value: value,
+	}, nil
+}
+
+// parseIntRangeValue parses a single integer
+// or a range like 20-24.
+func parseIntRangeValue(value string) (IntRange, error) {
+	minValue, maxValue, isRange := strings.Cut(value, "-")
+	min, err := strconv.Atoi(strings.TrimSpace(minValue))
+	if err != nil {
+		return IntRange{}, err
+	}
+	if !isRange {
+		return IntRange{Min: min, Max: min}, nil
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(maxValue))
+	if err != nil {
+		return IntRange{}, err
+	}
+	if max < min {
+		min, max = max, min
+	}
+	return IntRange{Min: min, Max: max}, nil
+}
+
+// parseComparableIntValue parses an integer with an
+// optional comparison operator, e.g. >=100.
+func parseComparableIntValue(value string) (*SearchFilter, error) {
+	op := CmpOpEq
+	for _, prefix := range []string{CmpOpGe, CmpOpLe, CmpOpGt, CmpOpLt, CmpOpEq} {
+		if rest, ok := strings.CutPrefix(value, prefix); ok {
+			op = prefix
+			value = rest
+			break
+		}
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return nil, err
+	}
+	cmp := IntComparison{Op: op, Value: v}
+	return &SearchFilter{
+		Name:  cmp.String(),
+		Value: cmp,
+	}, nil
+}
+
+// parseOTCValue parses an ASN or 'set' for
+// matching any route with an OTC attribute.
+func parseOTCValue(value string) (*SearchFilter, error) {
+	if strings.ToLower(value) == OTCValueSet {
+		return &SearchFilter{
+			Name:  OTCValueSet,
+			Value: OTCSet,
+		}, nil
+	}
+	return parseIntValue(value)
+}
+
+func parsePrefixLengthValue(value string) (*SearchFilter, error) {
+	length, err := parseIntRangeValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if length.Min < 0 || length.Max > 128 {
+		return nil, ErrInvalidPrefixLength
+	}
+	return &SearchFilter{
+		Name:  length.String(),
+		Value: length,
+	}, nil
+}
+
+func parseASPathLengthValue(value string) (*SearchFilter, error) {
+	length, err := parseIntRangeValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if length.Min < 0 {
+		return nil, ErrInvalidASPathLength
+	}
+	return &SearchFilter{
+		Name:  length.String(),
+		Value: length,
+	}, nil
+}
+
+func parseRpkiStatusValue(value string) (*SearchFilter, error) {
+	value = strings.ToLower(value)
+	switch value {
+	case RpkiStatusValid,
+		RpkiStatusInvalid,
+		RpkiStatusUnknown,
+		RpkiStatusNotChecked:
+		return &SearchFilter{
+			Name:  value,
+			Value: value,
+		}, nil
+	}
+	return nil, ErrInvalidRpkiStatus
+}
+
+// parseIPAddrValue parses an IP address into
+// its canonical string representation.
+func parseIPAddrValue(value string) (*SearchFilter, error) {
+	addr := net.ParseIP(value)
+	if addr == nil {
+		return nil, ErrInvalidIPAddr
+	}
+	return &SearchFilter{
+		Name:  addr.String(),
+		Value: addr.String(),
+	}, nil
+}
+
+func parseFilterOp(value string) (string, error) {
+	op := strings.ToLower(strings.TrimSpace(value))
+	if op != SearchFilterOpAll && op != SearchFilterOpAny {
+		return "", ErrInvalidFilterOp
+	}
+	return op, nil
+}
+
+// parseCommunityLabelValue parses a label for a case
+// insensitive match with the community labels.
+func parseCommunityLabelValue(value string) (*SearchFilter, error) {
+	label := strings.ToLower(value)
+	if label == "" {
+		return nil, ErrEmptyCommunityLabel
+	}
+	return &SearchFilter{
+		Name:  label,
+		Value: label,
+	}, nil
+}
+
+// parsePrefixValue parses a prefix in CIDR notation
+// with an optional match mode, e.g. covering:10.0.0.0/24.
+// Without a mode, more specifics of the prefix are matched.
+func parsePrefixValue(value string) (*SearchFilter, error) {
+	mode := PrefixMatchWithin
+	if m, cidr, ok := strings.Cut(value, ":"); ok {
+		m = strings.ToLower(strings.TrimSpace(m))
+		switch m {
+		case PrefixMatchExact, PrefixMatchWithin, PrefixMatchCovering:
+			mode, value = m, cidr
+		default:
+			// IPv6 prefixes contain at least two colons
+			if strings.Count(value, ":") == 1 {
+				return nil, ErrInvalidPrefixMatchMode
+			}
+		}
+	}
+	_, network, err := net.ParseCIDR(strings.TrimSpace(value))
+	if err != nil {
+		return nil, ErrInvalidPrefix
+	}
+	prefix := PrefixMatch{Network: network, Mode: mode}
+	return &SearchFilter{
+		Name:  prefix.String(),
+		Value: prefix,
+	}, nil
+}
+
 func parseStringValue(value string) (*SearchFilter, error) {
 	return &SearchFilter{
 		Value: value,
@@ -47,15 +250,36 @@ func parseStringValue(value string) (*SearchFilter, error) {
 }
 
 func parseCommunityValue(value string) (*SearchFilter, error) {
+	if strings.Contains(value, "-") {
+		return parseCommunityRangeValue(value)
+	}
+	community, err := parseCommunityTokens(value, true)
+	if err != nil {
+		return nil, err
+	}
+	return &SearchFilter{
+		Name:  community.String(),
+		Value: community,
+	}, nil
+}
+
+// parseCommunityRangeValue parses a community with
+// ranges as components, e.g. 65000:100-200.
+// A wildcard matches the entire range of values.
+func parseCommunityRangeValue(value string) (*SearchFilter, error) {
 	components := strings.Split(value, ":")
-	community := make(Community, len(components))
+	community := make(BGPCommunityRange, len(components))
 
 	for i, c := range components {
-		v, err := strconv.Atoi(c)
+		if c == "*" {
+			community[i] = []int{0, maxLargeCommunityValue}
+			continue
+		}
+		bounds, err := parseIntRangeValue(c)
 		if err != nil {
 			return nil, err
 		}
-		community[i] = v
+		community[i] = []int{bounds.Min, bounds.Max}
 	}
 
 	return &SearchFilter{
@@ -65,22 +289,10 @@ func parseCommunityValue(value string) (*SearchFilter, error) {
 }
 
 func parseExtCommunityValue(value string) (*SearchFilter, error) {
-	components := strings.Split(value, ":")
-	community := make(ExtCommunity, len(components))
-
-	if len(community) != 3 {
-		return nil, ErrExtCommunityIncomplete
-	}
-
-	// Check if the community is incomplete
-	if components[0] == "" || components[1] == "" || components[2] == "" {
-		return nil, ErrExtCommunityIncomplete
+	community, err := parseExtCommunityTokens(value, true)
+	if err != nil {
+		return nil, err
 	}
-	// TODO: Mixing strings and integers is not a good idea
-	community[0] = components[0]
-	community[1], _ = strconv.Atoi(components[1])
-	community[2], _ = strconv.Atoi(components[2])
-
 	return &SearchFilter{
 		Name:  community.String(),
 		Value: community,
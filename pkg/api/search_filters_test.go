@@ -1,7 +1,15 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/url"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -28,6 +36,21 @@ func makeTestRoute() *Route {
 	return route
 }
 
+// roundTripLookupRoute encodes and decodes the route
+// like a store backend persisting routes as JSON.
+func roundTripLookupRoute(t *testing.T, route *LookupRoute) *LookupRoute {
+	t.Helper()
+	data, err := json.Marshal(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &LookupRoute{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
 func makeTestLookupRoute() *LookupRoute {
 	route := &LookupRoute{
 		Route: &Route{
@@ -87,6 +110,36 @@ func TestSearchFilterCmpCommunity(t *testing.T) {
 	if searchFilterCmpCommunity(Community{1000, 23, 42}, Community{42, 23}) == true {
 		t.Error("1000:23:42 == 42:23 should be false")
 	}
+
+	// Wildcards
+	if !searchFilterCmpCommunity(Community{23, CommunityWildcard}, Community{23, 42}) {
+		t.Error("23:* == 23:42 should be true")
+	}
+	if searchFilterCmpCommunity(Community{23, CommunityWildcard}, Community{42, 23}) {
+		t.Error("23:* == 42:23 should be false")
+	}
+	if searchFilterCmpCommunity(Community{23, 42}, Community{23, CommunityWildcard}) {
+		t.Error("23:42 == 23:* should be false")
+	}
+}
+
+func TestSearchFilterCmpString(t *testing.T) {
+	a := "rs1"
+	b := "rs1"
+	c := "rs2"
+
+	if !searchFilterCmpString(&a, &a) {
+		t.Error("identical pointers should be equal")
+	}
+	if !searchFilterCmpString(&a, &b) {
+		t.Error("distinct pointers to equal strings should be equal")
+	}
+	if searchFilterCmpString(&a, &c) {
+		t.Error("rs1 == rs2 should be false")
+	}
+	if !searchFilterCmpString(&a, "rs1") || !searchFilterCmpString("rs1", &b) {
+		t.Error("pointer and value should be equal")
+	}
 }
 
 func TestSearchFilterEqual(t *testing.T) {
@@ -223,6 +276,90 @@ func TestSearchFilterManagement(t *testing.T) {
 	}
 }
 
+func TestSearchFilterGroupSortByCardinality(t *testing.T) {
+	filtering := NewSearchFilters()
+	group := filtering.GetGroupByKey(SearchKeyASNS)
+
+	add := func(name string, asn, n int) {
+		for range n {
+			group.AddFilter(&SearchFilter{Name: name, Value: asn})
+		}
+	}
+	add("Foocom", 424242, 1)
+	add("Tech Inc.", 23042, 3)
+	add("Barnet", 65001, 1)
+	add("Bazcom", 65002, 2)
+
+	order := func() []int {
+		asns := []int{}
+		for _, f := range group.Filters {
+			asns = append(asns, f.Value.(int))
+		}
+		return asns
+	}
+
+	group.SortByCardinality(true)
+	if asns := order(); !slices.Equal(asns, []int{23042, 65002, 65001, 424242}) {
+		t.Error("unexpected descending order:", asns)
+	}
+
+	group.SortByCardinality(false)
+	if asns := order(); !slices.Equal(asns, []int{65001, 424242, 65002, 23042}) {
+		t.Error("unexpected ascending order:", asns)
+	}
+
+	// The index resolves the filters after sorting
+	for _, asn := range []int{424242, 23042, 65001, 65002} {
+		filter := group.GetFilterByValue(asn)
+		if filter == nil || filter.Value != asn {
+			t.Error("expected filter for", asn, "got:", filter)
+		}
+	}
+	if f := group.GetFilterByValue(23042); f == nil || f.Cardinality != 3 {
+		t.Error("unexpected filter:", f)
+	}
+
+	// Matching is not affected
+	if !group.MatchAny(makeTestLookupRoute()) {
+		t.Error("expected route to match")
+	}
+}
+
+func TestSearchFilterGroupTopN(t *testing.T) {
+	filtering := NewSearchFilters()
+	group := filtering.GetGroupByKey(SearchKeyCommunities)
+	for i := range 10 {
+		for range i + 1 {
+			group.AddFilter(&SearchFilter{Value: Community{65000, i}})
+		}
+	}
+
+	dropped := group.TopN(3)
+	if dropped != 7 {
+		t.Error("expected 7 dropped filters, got:", dropped)
+	}
+	if len(group.Filters) != 3 {
+		t.Fatal("expected 3 filters, got:", len(group.Filters))
+	}
+	for i, v := range []int{9, 8, 7} {
+		filter := group.GetFilterByValue(Community{65000, v})
+		if filter == nil || group.Filters[i] != filter {
+			t.Error("unexpected filter at", i, group.Filters[i])
+		}
+	}
+	if group.GetFilterByValue(Community{65000, 1}) != nil {
+		t.Error("expected filter 65000:1 to be dropped")
+	}
+
+	// Keeping more filters than present drops nothing
+	if dropped := group.TopN(5); dropped != 0 || len(group.Filters) != 3 {
+		t.Error("unexpected truncation:", dropped, len(group.Filters))
+	}
+	if dropped := group.TopN(-1); dropped != 0 || len(group.Filters) != 3 {
+		t.Error("unexpected truncation:", dropped, len(group.Filters))
+	}
+}
+
 func TestSearchFiltersFromQuery(t *testing.T) {
 	query := "asns=2342,23123&large_communities=23:42:42&sources=1,2,3&q=foo"
 	values, err := url.ParseQuery(query)
@@ -562,6 +699,84 @@ func TestSearchFiltersSub(t *testing.T) {
 
 }
 
+func TestSearchFiltersCombine(t *testing.T) {
+	a := NewSearchFilters()
+	a.GetGroupByKey(SearchKeyASNS).AddFilters([]*SearchFilter{
+		{Value: 2342}, {Value: 23042},
+	})
+	a.GetGroupByKey(SearchKeyASNS).Filters[0].Cardinality = 3
+
+	b := NewSearchFilters()
+	b.GetGroupByKey(SearchKeyASNS).AddFilters([]*SearchFilter{
+		{Value: 2342}, {Value: 10},
+	})
+	b.GetGroupByKey(SearchKeyASNS).Filters[0].Cardinality = 5
+
+	c := a.Combine(b)
+	g := c.GetGroupByKey(SearchKeyASNS)
+	if len(g.Filters) != 3 {
+		t.Fatal("unexpected filters:", g.Filters)
+	}
+	if f := g.GetFilterByValue(2342); f == nil || f.Cardinality != 8 {
+		t.Error("expected cardinalities to be summed, got:", f)
+	}
+	if f := g.GetFilterByValue(23042); f == nil || f.Cardinality != 1 {
+		t.Error("expected cardinality to be preserved, got:", f)
+	}
+	if f := g.GetFilterByValue(10); f == nil || f.Cardinality != 1 {
+		t.Error("expected cardinality to be preserved, got:", f)
+	}
+
+	// The original filters are not modified
+	if a.GetGroupByKey(SearchKeyASNS).Filters[0].Cardinality != 3 {
+		t.Error("expected original filter to be unchanged")
+	}
+}
+
+func TestSearchFiltersSymmetricDiff(t *testing.T) {
+	values, _ := url.ParseQuery(
+		"asns=2342,23042&communities=23:42&large_communities=42:23:42")
+	a, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, _ = url.ParseQuery(
+		"asns=2342,10&large_communities=42:23:42&sources=rs1")
+	b, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.GetGroupByKey(SearchKeyASNS).Filters[1].Cardinality = 9001
+	b.GetGroupByKey(SearchKeyASNS).Filters[1].Cardinality = 42
+
+	c := a.SymmetricDiff(b)
+
+	g := c.GetGroupByKey(SearchKeyASNS)
+	if len(g.Filters) != 2 {
+		t.Fatal("expected two asn filters, got:", g.Filters)
+	}
+	if g.Filters[0].Value != 23042 || g.Filters[0].Cardinality != 9001 {
+		t.Error("unexpected filter:", g.Filters[0])
+	}
+	if g.Filters[1].Value != 10 || g.Filters[1].Cardinality != 42 {
+		t.Error("unexpected filter:", g.Filters[1])
+	}
+	if g.GetFilterByValue(10) == nil {
+		t.Error("expected index to be rebuilt")
+	}
+
+	if len(c.GetGroupByKey(SearchKeyCommunities).Filters) != 1 {
+		t.Error("expected community filter only present in a")
+	}
+	if len(c.GetGroupByKey(SearchKeySources).Filters) != 1 {
+		t.Error("expected source filter only present in b")
+	}
+	if len(c.GetGroupByKey(SearchKeyLargeCommunities).Filters) != 0 {
+		t.Error("expected shared large community filter to be removed")
+	}
+}
+
 func TestSearchFiltersMergeProperties(t *testing.T) {
 	filtering := NewSearchFilters()
 	group := filtering.GetGroupByKey(SearchKeyASNS)
@@ -612,7 +827,7 @@ func TestNeighborFilterMatch(t *testing.T) {
 	}
 
 	filter := &NeighborFilter{
-		asn: 42,
+		asns: []int{42},
 	}
 	if filter.Match(n1) != false {
 		t.Error("Expected n1 not to match filter")
@@ -632,35 +847,139 @@ func TestNeighborFilterMatch(t *testing.T) {
 	}
 
 	filter = &NeighborFilter{
-		asn:  42,
+		asns: []int{42},
 		name: "network",
 	}
 
 	if filter.Match(n1) == false || filter.Match(n2) == false {
 		t.Error("Expected filter to match both neighbors.")
 	}
+
+	filter = NeighborFilterFromQueryString("asn=42&name=network&match=all")
+	if filter.Match(n1) != false || filter.Match(n2) != false {
+		t.Error("Expected filter to match no neighbor.")
+	}
+
+	filter = NeighborFilterFromQueryString("asn=2342&name=network&match=all")
+	if filter.Match(n1) == false {
+		t.Error("Expected n1 to match filter")
+	}
+
+	// With a single predicate, both modes are the same
+	filter = NeighborFilterFromQueryString("asn=42&match=all")
+	if filter.Match(n1) != false || filter.Match(n2) == false {
+		t.Error("Expected only n2 to match filter")
+	}
+}
+
+func TestNeighborFilterMatchName(t *testing.T) {
+	n := &Neighbor{
+		ASN:         1299,
+		Description: "TELIA Carrier (Arelion) AB",
+	}
+
+	tests := []struct {
+		name  string
+		match bool
+	}{
+		{"telia", true},
+		{"Telia", true},
+		{"tel carr", true},
+		{"carrier telia", true},
+		{"arelion ab", true},
+		{"telia cogent", false},
+		{"cogent", false},
+	}
+	for _, tt := range tests {
+		filter := &NeighborFilter{name: tt.name}
+		if filter.Match(n) != tt.match {
+			t.Error("unexpected match for name:", tt.name)
+		}
+	}
+}
+
+func TestNeighborFilterMatchNamePattern(t *testing.T) {
+	n := &Neighbor{
+		ASN:         2342,
+		Description: "Example Networks cust-12345-lon",
+	}
+
+	tests := []struct {
+		name  string
+		asn   string
+		match bool
+	}{
+		{`/cust-\d+-lon/`, "", true},
+		{`/cust-\d+-fra/`, "", false},
+		{`/^example/`, "", false},
+		{`/(?i)^example/`, "", true},
+		{`/cust-(/`, "", false},     // invalid
+		{`/cust-(/`, "2342", false}, // invalid
+		{`cust-12345`, "", true},
+		{`/`, "", false},
+	}
+	for _, tt := range tests {
+		filter := NeighborFilterFromQuery(url.Values{
+			"name": []string{tt.name},
+			"asn":  []string{tt.asn},
+		})
+		if filter.Match(n) != tt.match {
+			t.Error("unexpected match for name:", tt.name, tt.asn)
+		}
+	}
 }
 
 func TestNeighborFilterFromQuery(t *testing.T) {
 	query := "asn=2342&name=foo"
 	filter := NeighborFilterFromQueryString(query)
 
-	if filter.asn != 2342 {
-		t.Error("Unexpected asn filter:", filter.asn)
+	if !slices.Equal(filter.asns, []int{2342}) {
+		t.Error("Unexpected asn filter:", filter.asns)
 	}
 	if filter.name != "foo" {
 		t.Error("Unexpected name filter:", filter.name)
 	}
 
 	filter = NeighborFilterFromQueryString("")
-	if filter.asn != 0 {
-		t.Error("Unexpected asn:", filter.asn)
+	if len(filter.asns) != 0 {
+		t.Error("Unexpected asn:", filter.asns)
 	}
 	if filter.name != "" {
 		t.Error("Unexpected name:", filter.name)
 	}
 }
 
+func TestNeighborFilterMatchASNs(t *testing.T) {
+	neighbors := []*Neighbor{
+		{ASN: 2914},
+		{ASN: 3356},
+		{ASN: 1299},
+	}
+
+	tests := []struct {
+		query   string
+		matches []bool
+	}{
+		{"asn=2914", []bool{true, false, false}},
+		{"asn=2914,3356", []bool{true, true, false}},
+		{"asn=2914, 3356,foo", []bool{true, true, false}},
+		{"asn=", []bool{false, false, false}},
+	}
+	for _, tt := range tests {
+		filter := NeighborFilterFromQueryString(tt.query)
+		for i, n := range neighbors {
+			if filter.Match(n) != tt.matches[i] {
+				t.Error("unexpected match for", tt.query, "and AS", n.ASN)
+			}
+		}
+	}
+
+	filter := NeighborFilterFromQueryString("asn=")
+	if len(filter.asns) != 0 {
+		t.Error("expected empty asn to disable asn matching")
+	}
+}
+
 func TestSearchFiltersHasKey(t *testing.T) {
 	// Sources filter present
 	query := "asn=2342&sources=foo"
@@ -682,13 +1001,13 @@ func TestSearchFiltersHasKey(t *testing.T) {
 }
 
 func TestParseInvalidCommunityFilterText(t *testing.T) {
-	_, _, err := parseCommunityFilterText("")
+	_, _, err := (&FilterContext{}).parseCommunityFilterText("")
 	if err == nil {
 		t.Error("Expected error for empty filter")
 	}
 	t.Log(err)
 
-	_, _, err = parseCommunityFilterText("23452")
+	_, _, err = (&FilterContext{}).parseCommunityFilterText("23452")
 	if err == nil {
 		t.Error("Expected error for empty filter")
 	}
@@ -696,7 +1015,7 @@ func TestParseInvalidCommunityFilterText(t *testing.T) {
 
 func TestParseCommunityFilterText(t *testing.T) {
 	text := "12345:23"
-	key, filter, err := parseCommunityFilterText(text)
+	key, filter, err := (&FilterContext{}).parseCommunityFilterText(text)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -711,7 +1030,7 @@ func TestParseCommunityFilterText(t *testing.T) {
 
 func TestParseLargeCommunityFilterText(t *testing.T) {
 	text := "12345:23:42"
-	key, filter, err := parseCommunityFilterText(text)
+	key, filter, err := (&FilterContext{}).parseCommunityFilterText(text)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -726,7 +1045,7 @@ func TestParseLargeCommunityFilterText(t *testing.T) {
 
 func TestParseExtCommunityFilterText(t *testing.T) {
 	text := "ro:12345:23"
-	key, filter, err := parseCommunityFilterText(text)
+	key, filter, err := (&FilterContext{}).parseCommunityFilterText(text)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -789,3 +1108,1865 @@ func TestFiltersFromTokensInvalid(t *testing.T) {
 	}
 	t.Log(err)
 }
+
+func TestSearchFiltersRemoveFromLookupRoute(t *testing.T) {
+	r1 := makeTestLookupRoute()
+	r2 := makeTestLookupRoute()
+	r2.Route = &Route{
+		BGP: &BGPInfo{
+			Communities: []Community{
+				{23, 42},
+			},
+		},
+	}
+
+	filters := NewSearchFilters()
+	filters.UpdateFromLookupRoute(r1)
+	filters.UpdateFromLookupRoute(r2)
+
+	communities := filters.GetGroupByKey(SearchKeyCommunities)
+	if communities.GetFilterByValue(Community{23, 42}).Cardinality != 2 {
+		t.Error("expected cardinality of 2 for 23:42")
+	}
+
+	filters.RemoveFromLookupRoute(r1)
+
+	// 23:42 is still present on r2
+	filter := communities.GetFilterByValue(Community{23, 42})
+	if filter == nil || filter.Cardinality != 1 {
+		t.Error("expected 23:42 with cardinality 1, got:", filter)
+	}
+
+	// 111:11 is only present on r1
+	if communities.GetFilterByValue(Community{111, 11}) != nil {
+		t.Error("expected 111:11 to be removed")
+	}
+	if len(communities.Filters) != 1 {
+		t.Error("expected 1 community filter, got:", len(communities.Filters))
+	}
+	if filters.GetGroupByKey(SearchKeyLargeCommunities).GetFilterByValue(
+		Community{1000, 23, 42}) != nil {
+		t.Error("expected large community to be removed")
+	}
+
+	// Index must be consistent with the filters
+	for _, group := range *filters {
+		for i, f := range group.Filters {
+			if group.filtersIdx[filterIndexKey(f)] != i {
+				t.Error("index inconsistent for", group.Key, f.Value)
+			}
+		}
+		if len(group.filtersIdx) != len(group.Filters) {
+			t.Error("stale index entries in group", group.Key)
+		}
+	}
+
+	filters.RemoveFromLookupRoute(r2)
+	for _, group := range *filters {
+		if len(group.Filters) != 0 {
+			t.Error("expected group to be empty:", group.Key)
+		}
+	}
+}
+
+func TestSearchFiltersUpdateDuplicateCommunities(t *testing.T) {
+	r := makeTestLookupRoute()
+	r.Route = &Route{
+		BGP: &BGPInfo{
+			Communities: Communities{
+				{23, 42}, {23, 42},
+			},
+			ExtCommunities: ExtCommunities{
+				{"rt", 23, 42}, {"rt", 23, 42},
+			},
+			LargeCommunities: Communities{
+				{1000, 23, 42}, {1000, 23, 42},
+			},
+		},
+	}
+
+	filters := NewSearchFilters()
+	filters.UpdateFromLookupRoute(r)
+	filters.UpdateFromRoute(r.Route)
+
+	tests := []struct {
+		key   string
+		value any
+	}{
+		{SearchKeyCommunities, Community{23, 42}},
+		{SearchKeyExtCommunities, ExtCommunity{"rt", 23, 42}},
+		{SearchKeyLargeCommunities, Community{1000, 23, 42}},
+	}
+	for _, test := range tests {
+		filter := filters.GetGroupByKey(test.key).GetFilterByValue(test.value)
+		if filter == nil || filter.Cardinality != 2 {
+			t.Error("expected cardinality of 2 for", test.value, "got:", filter)
+		}
+	}
+
+	filters.RemoveFromLookupRoute(r)
+	for _, test := range tests {
+		filter := filters.GetGroupByKey(test.key).GetFilterByValue(test.value)
+		if filter == nil || filter.Cardinality != 1 {
+			t.Error("expected cardinality of 1 for", test.value, "got:", filter)
+		}
+	}
+}
+
+type testMultiPathRoute struct {
+	*LookupRoute
+	paths []Filterable
+}
+
+func (r *testMultiPathRoute) Paths() []Filterable {
+	return r.paths
+}
+
+func TestSearchFilterMatchRouteAnyPath(t *testing.T) {
+	route := &testMultiPathRoute{
+		LookupRoute: makeTestLookupRoute(),
+		paths: []Filterable{
+			&Route{
+				BGP: &BGPInfo{
+					Communities: []Community{{23, 42}},
+				},
+			},
+			&Route{
+				BGP: &BGPInfo{
+					Communities:      []Community{{111, 11}, {65000, 100}},
+					LargeCommunities: []Community{{1000, 23, 42}},
+				},
+			},
+		},
+	}
+
+	// Only the second path has both communities
+	values, _ := url.ParseQuery(
+		"communities=111:11,65000:100&large_communities=1000:23:42")
+	filters, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route to match on second path")
+	}
+
+	// The communities are spread across paths
+	values, _ = url.ParseQuery("communities=23:42,65000:100")
+	filters, err = FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected communities from different paths not to match")
+	}
+
+	// Fallback to the route itself without paths
+	route.paths = nil
+	values, _ = url.ParseQuery("communities=23:42,111:11")
+	filters, err = FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected single path fallback to match")
+	}
+}
+
+func TestParseCommunityFilterTextSuggestion(t *testing.T) {
+	suggestions := []struct {
+		text       string
+		suggestion string
+	}{
+		{"65000;100", "65000:100"},
+		{"65000.100", "65000:100"},
+		{"65000,100,23", "65000:100:23"},
+		{"65000 100", "65000:100"},
+		{"ro;65000;100", "ro:65000:100"},
+	}
+	for _, s := range suggestions {
+		_, _, err := (&FilterContext{}).parseCommunityFilterText(s.text)
+		if err == nil {
+			t.Error("expected error for:", s.text)
+			continue
+		}
+		if !strings.Contains(err.Error(), "did you mean "+s.suggestion+"?") {
+			t.Error("expected suggestion", s.suggestion, "got:", err)
+		}
+	}
+
+	// No suggestion possible
+	_, _, err := (&FilterContext{}).parseCommunityFilterText("foo;bar")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), "did you mean") {
+		t.Error("unexpected suggestion:", err)
+	}
+}
+
+func TestSearchFilterCommunitiesFilterSets(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.Route.BGP.Communities = []Community{
+		{65000, 200},
+		{65001, 1},
+	}
+
+	tests := []struct {
+		query   string
+		matched bool
+	}{
+		// A single parameter requires all communities
+		{"communities=65000:200,65001:1", true},
+		{"communities=65000:100,65000:200", false},
+		// (65000:100 OR 65000:200) AND 65001:1
+		{"communities=65000:100,65000:200&communities=65001:1", true},
+		{"communities=65000:100,65000:200&communities=65001:2", false},
+		{"communities=65000:100,65000:300&communities=65001:1", false},
+		// Repeated parameters are combined with AND
+		{"communities=65000:200&communities=65001:1", true},
+		{"communities=65000:100&communities=65000:200", false},
+	}
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		filters, err := FiltersFromQuery(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filters.MatchRoute(route) != test.matched {
+			t.Error("expected", test.query, "match:", test.matched)
+		}
+	}
+
+	values, _ := url.ParseQuery(
+		"communities=65000:100,65000:200&communities=65001:1")
+	filters, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filters.GetGroupByKey(SearchKeyCommunities).Filters) != 3 {
+		t.Error("expected all communities in the filter group")
+	}
+
+	// Combined filters must match the sets of both sides
+	values, _ = url.ParseQuery("communities=65000:100,65000:200&communities=65001:1")
+	filters, err = FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := FiltersFromTokens([]string{"#65000:200"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.Combine(tokens).MatchRoute(route) {
+		t.Error("expected combined filters to match")
+	}
+	if !tokens.Combine(filters).MatchRoute(route) {
+		t.Error("expected combined filters to match in any order")
+	}
+	tokens, err = FiltersFromTokens([]string{"#65001:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.Combine(tokens).MatchRoute(route) {
+		t.Error("expected combined filters not to match")
+	}
+}
+
+func TestSearchFilterDescribe(t *testing.T) {
+	rsID := "rs1"
+	descriptions := []struct {
+		key      string
+		filter   *SearchFilter
+		expected string
+	}{
+		{SearchKeySources, &SearchFilter{Name: "RS 1", Value: &rsID},
+			"Source rs1 (RS 1)"},
+		{SearchKeyASNS, &SearchFilter{Name: "Example Net", Value: 64500},
+			"ASN 64500 (Example Net)"},
+		{SearchKeyASNS, &SearchFilter{Value: 64500},
+			"ASN 64500"},
+		{SearchKeyCommunities, &SearchFilter{
+			Name: "65000:100", Value: Community{65000, 100}},
+			"Community 65000:100"},
+		{SearchKeyExtCommunities, &SearchFilter{
+			Value: ExtCommunity{"rt", 65000, 100}},
+			"Ext. Community rt:65000:100"},
+		{SearchKeyLargeCommunities, &SearchFilter{
+			Name: "peer tag", Value: Community{65000, 1, 2}},
+			"Large Community 65000:1:2 (peer tag)"},
+		{SearchKeyAddrFamily, &SearchFilter{
+			Name: "IPv6", Value: AddrFamilyIPv6},
+			"Address Family IPv6"},
+		{"foo", &SearchFilter{Value: "bar"}, "foo bar"},
+	}
+	for _, d := range descriptions {
+		desc := d.filter.Describe(d.key)
+		if desc != d.expected {
+			t.Errorf("expected %q, got: %q", d.expected, desc)
+		}
+	}
+}
+
+func TestSearchFilterNextHopSelf(t *testing.T) {
+	self := "2001:db8::1"
+	other := "2001:db8::2"
+
+	route := makeTestLookupRoute()
+	route.RouteServer.SelfAddresses = []net.IP{
+		net.ParseIP("10.0.0.1"), net.ParseIP("2001:DB8::1")}
+	route.Route.BGP.NextHop = &self
+
+	values, _ := url.ParseQuery("next_hop_self=true")
+	filters, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with next hop self to match")
+	}
+	if !filters.MatchRoute(roundTripLookupRoute(t, route)) {
+		t.Error("expected stored route with next hop self to match")
+	}
+
+	route.Route.BGP.NextHop = &other
+	if filters.MatchRoute(route) {
+		t.Error("expected route with other next hop not to match")
+	}
+
+	values, _ = url.ParseQuery("next_hop_self=false")
+	filters, err = FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with other next hop to match")
+	}
+
+	// Routes without a next hop are not self
+	route.Route.BGP.NextHop = nil
+	if !filters.MatchRoute(route) {
+		t.Error("expected route without next hop to match")
+	}
+
+	values, _ = url.ParseQuery("next_hop_self=maybe")
+	if _, err := FiltersFromQuery(values); err == nil {
+		t.Error("expected error for invalid boolean")
+	}
+}
+
+func TestSearchFilterRejectStatus(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.State = RouteStateFiltered
+
+	rejected, err := FiltersFromQuery(url.Values{"status": {"rejected"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted, err := FiltersFromQuery(url.Values{"status": {"Accepted"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rejected.MatchRoute(route) {
+		t.Error("expected filtered route to match status=rejected")
+	}
+	if accepted.MatchRoute(route) {
+		t.Error("expected filtered route not to match status=accepted")
+	}
+
+	route.State = RouteStateImported
+	if rejected.MatchRoute(route) {
+		t.Error("expected imported route not to match status=rejected")
+	}
+	if !accepted.MatchRoute(route) {
+		t.Error("expected imported route to match status=accepted")
+	}
+
+	// Routes without reject information match nothing
+	route.State = ""
+	if rejected.MatchRoute(route) || accepted.MatchRoute(route) {
+		t.Error("expected route without state not to match")
+	}
+	if accepted.MatchRoute(makeTestRoute()) {
+		t.Error("expected route without state not to match")
+	}
+
+	if _, err := FiltersFromQuery(url.Values{"status": {"pending"}}); err == nil {
+		t.Error("expected error for invalid status")
+	}
+}
+
+func TestSearchFilterPrefixLength(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.Network = "10.0.0.0/24"
+
+	filters, err := FiltersFromQuery(url.Values{"prefix_length": {"20-24"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected /24 to match 20-24")
+	}
+
+	filters, err = FiltersFromQuery(url.Values{"prefix_length": {"16,20-23"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected /24 not to match 16,20-23")
+	}
+
+	route.Network = "2001:db8::1/128"
+	filters, err = FiltersFromQuery(url.Values{"prefix_length": {"16,128"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected /128 to match")
+	}
+	if filters.GetGroupByKey(SearchKeyPrefixLength).Filters[0].Name != "16" {
+		t.Error("unexpected filter name")
+	}
+
+	route.Network = "invalid"
+	if filters.MatchRoute(route) {
+		t.Error("expected network without length not to match")
+	}
+
+	for _, value := range []string{"129", "-1", "0-200", "a-b"} {
+		_, err := FiltersFromQuery(url.Values{"prefix_length": {value}})
+		if err == nil {
+			t.Error("expected error for prefix length:", value)
+		}
+	}
+}
+
+func TestSearchFilterNextHop(t *testing.T) {
+	nextHop := "::ffff:185.1.2.3"
+	route := makeTestLookupRoute()
+	route.BGP.NextHop = &nextHop
+
+	filters, err := FiltersFromQuery(url.Values{"next_hop": {"10.0.0.1, 185.1.2.3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected mapped ipv4 next hop to match")
+	}
+
+	nextHop = "2001:DB8:0::1"
+	filters, err = FiltersFromQuery(url.Values{"next_hop": {"2001:db8::1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected ipv6 next hop to match")
+	}
+
+	route.BGP.NextHop = nil
+	if filters.MatchRoute(route) {
+		t.Error("expected route without next hop not to match")
+	}
+
+	if _, err := FiltersFromQuery(url.Values{"next_hop": {"1.2.3"}}); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
+
+func TestSearchFilterOriginASN(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.BGP.AsPath = []int{23042, 3320, 64500}
+
+	filters, err := FiltersFromQuery(url.Values{"origin_asn": {"1,64500"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route originated by 64500 to match")
+	}
+
+	filters, err = FiltersFromQuery(url.Values{"origin_asn": {"23042"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected peer asn not to match origin")
+	}
+
+	route.BGP.AsPath = []int{}
+	if filters.MatchRoute(route) {
+		t.Error("expected route with empty as path not to match")
+	}
+}
+
+func TestSearchFilterASPathLength(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.BGP.AsPath = []int{23042, 23042, 23042, 3320, 64500}
+
+	filters, err := FiltersFromQuery(url.Values{"as_path_length": {"5-10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected as path of length 5 to match")
+	}
+
+	fc := &FilterContext{ASPathLengthIgnorePrepends: true}
+	filters, err = fc.FiltersFromQuery(url.Values{"as_path_length": {"5-10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected prepends to be counted once")
+	}
+
+	filters, err = fc.FiltersFromQuery(url.Values{"as_path_length": {"3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected as path without prepends to match 3")
+	}
+
+	route.BGP.AsPath = nil
+	if filters.MatchRoute(route) {
+		t.Error("expected empty as path not to match")
+	}
+
+	if _, err := FiltersFromQuery(url.Values{"as_path_length": {"x"}}); err == nil {
+		t.Error("expected error for invalid length")
+	}
+}
+
+func TestSearchFilterNegate(t *testing.T) {
+	route := makeTestLookupRoute() // AS 23042, community 23:42
+
+	tests := []struct {
+		query   string
+		matched bool
+	}{
+		{"asns=!2342", true},
+		{"asns=!23042", false},
+		{"asns=!2342,23042", true},
+		{"asns=!2342,64500", false},
+		{"asns=!23042,23042", false},
+		{"asns=!1,!2", true},
+		{"communities=!1:2", true},
+		{"communities=!23:42", false},
+		{"communities=23:42,!1:2", true},
+		{"communities=1:2&communities=!111:11,23:42", false},
+		{"communities=!1:2,23:42&communities=!23:42,111:11", false},
+		{"communities=!1:2,23:42&communities=!65000:1,111:11", true},
+		{"communities=!1:2&communities=65000:1", false},
+		{"communities=!1:2&communities=65000:1,111:11", true},
+		{"communities=!23:42&communities=111:11", false},
+	}
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		filters, err := FiltersFromQuery(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filters.MatchRoute(route) != test.matched {
+			t.Error("expected", test.query, "match:", test.matched)
+		}
+	}
+
+	values, _ := url.ParseQuery("asns=!2342,2342")
+	filters, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := filters.GetGroupByKey(SearchKeyASNS)
+	if len(group.Filters) != 2 {
+		t.Fatal("expected negated and positive filter, got:", group.Filters)
+	}
+	if group.Filters[0].Describe(group.Key) != "ASN !2342" {
+		t.Error("unexpected description:", group.Filters[0].Describe(group.Key))
+	}
+	if group.Filters[0].Equal(group.Filters[1]) {
+		t.Error("expected negated filter not to equal positive filter")
+	}
+}
+
+func TestSearchFiltersToQuery(t *testing.T) {
+	query := "sources=rs1,rs2&asns=2342,!23042" +
+		"&communities=23:42&communities=65000:1,!1:2" +
+		"&ext_communities=rt:23:42,ro:1:2" +
+		"&large_communities=1000:23:42" +
+		"&addr_family=2&next_hop_self=true&prefix_length=20-24"
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filters, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := filters.ToQuery()
+	if encoded.Get("ext_communities") != "rt:23:42,ro:1:2" {
+		t.Error("unexpected ext communities:", encoded.Get("ext_communities"))
+	}
+	if len(encoded["communities"]) != 2 {
+		t.Error("expected filter sets to be repeated:", encoded["communities"])
+	}
+	if encoded.Get("asns") != "2342,!23042" {
+		t.Error("unexpected asns:", encoded.Get("asns"))
+	}
+	if _, ok := encoded["rpki_status"]; ok {
+		t.Error("expected empty groups to be omitted")
+	}
+
+	decoded, err := FiltersFromQuery(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, group := range *filters {
+		other := (*decoded)[i]
+		if len(group.Filters) != len(other.Filters) {
+			t.Error("unexpected filters in group", group.Key, other.Filters)
+			continue
+		}
+		for j, f := range group.Filters {
+			if !f.Equal(other.Filters[j]) {
+				t.Error("expected", f, "to equal", other.Filters[j])
+			}
+		}
+		if len(group.filterSets) != len(other.filterSets) {
+			t.Error("unexpected filter sets in", group.Key)
+		}
+	}
+
+	route := makeTestLookupRoute()
+	if filters.MatchRoute(route) != decoded.MatchRoute(route) {
+		t.Error("expected decoded filters to match equally")
+	}
+}
+
+func TestSearchFiltersCommunitiesOp(t *testing.T) {
+	route := makeTestLookupRoute() // 23:42, 111:11
+
+	tests := []struct {
+		query   string
+		matched bool
+	}{
+		{"communities=23:42,1:2", false},
+		{"communities=23:42,1:2&communities_op=all", false},
+		{"communities=23:42,1:2&communities_op=any", true},
+		{"communities=3:4,1:2&communities_op=any", false},
+		{"large_communities=1000:23:42,1:2:3&communities_op=ANY", true},
+	}
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		filters, err := FiltersFromQuery(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filters.MatchRoute(route) != test.matched {
+			t.Error("expected", test.query, "match:", test.matched)
+		}
+	}
+
+	values, _ := url.ParseQuery("communities=1:2&communities_op=any")
+	filters, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.ToQuery().Get(SearchKeyCommunitiesOp) != "any" {
+		t.Error("expected operator to be encoded")
+	}
+
+	values, _ = url.ParseQuery("communities_op=some")
+	if _, err := FiltersFromQuery(values); err == nil {
+		t.Error("expected error for invalid operator")
+	}
+}
+
+func TestSearchFilterWildcardCommunities(t *testing.T) {
+	route := makeTestLookupRoute() // 23:42, 1000:23:42, ro:23:123
+
+	tests := []struct {
+		query   string
+		matched bool
+	}{
+		{"communities=23:*", true},
+		{"communities=*:42", true},
+		{"communities=24:*", false},
+		{"large_communities=1000:*:*", true},
+		{"large_communities=1000:*:43", false},
+		{"ext_communities=ro:*:*", true},
+		{"ext_communities=xx:*:*", false},
+		{"communities=23:42", true},
+	}
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		filters, err := FiltersFromQuery(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filters.MatchRoute(route) != test.matched {
+			t.Error("expected", test.query, "match:", test.matched)
+		}
+	}
+
+	filters, err := FiltersFromTokens([]string{"#65000:*:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := filters.GetGroupByKey(SearchKeyLargeCommunities)
+	if len(group.Filters) != 1 || group.Filters[0].Name != "65000:*:*" {
+		t.Error("unexpected filters:", group.Filters)
+	}
+
+	// Wildcards are also used with the community index
+	bgp := makeTestBGPInfoCommunities(200)
+	bgp.IndexCommunities()
+	if !bgp.HasCommunity(Community{65000, CommunityWildcard}) {
+		t.Error("expected wildcard to match indexed communities")
+	}
+	if bgp.HasLargeCommunity(Community{65001, CommunityWildcard, 1}) {
+		t.Error("expected wildcard not to match")
+	}
+}
+
+func TestSearchFilterPeerAddress(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.Neighbor.Address = "2001:DB8:0::23"
+
+	filters, err := FiltersFromQuery(url.Values{"peer_address": {"10.0.0.1,2001:db8::23"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with peer address to match")
+	}
+
+	filters, err = FiltersFromTokens([]string{"@2001:db8::42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected route with other peer address not to match")
+	}
+
+	route.Neighbor.Address = ""
+	if filters.MatchRoute(route) {
+		t.Error("expected route without peer address not to match")
+	}
+
+	if _, err := FiltersFromTokens([]string{"@192.0.2.300"}); err == nil {
+		t.Error("expected error for invalid peer address")
+	}
+}
+
+func TestSearchFilterBlackhole(t *testing.T) {
+	fc := &FilterContext{
+		BlackholeCommunities: BGPCommunitiesSet{
+			Standard: []BGPCommunityRange{
+				{[]any{65535, 65535}, []any{666, 666}},
+			},
+			Extended: []BGPCommunityRange{
+				{[]string{"ro", "ro"}, []int{20, 30}, []int{100, 200}},
+			},
+		},
+	}
+
+	blackhole := "10.23.6.66"
+	route := makeTestLookupRoute()
+	route.RouteServer.Blackholes = []net.IP{net.ParseIP(blackhole)}
+
+	filters, err := fc.FiltersFromQuery(url.Values{"blackhole": {"true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with blackhole ext. community to match")
+	}
+	if !filters.Clone().MatchRoute(route) {
+		t.Error("expected clone to match with the filter context")
+	}
+	if !NewSearchFilters().Combine(filters).MatchRoute(route) {
+		t.Error("expected combined filters to match with the filter context")
+	}
+
+	route.Route.BGP.ExtCommunities = []ExtCommunity{{"rt", 23, 123}}
+	if filters.MatchRoute(route) {
+		t.Error("expected route without blackhole community not to match")
+	}
+
+	route.Route.BGP.Communities = append(
+		route.Route.BGP.Communities, Community{65535, 666})
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with blackhole community to match")
+	}
+
+	route = makeTestLookupRoute()
+	route.Route.BGP.ExtCommunities = nil
+	route.RouteServer.Blackholes = []net.IP{net.ParseIP(blackhole)}
+	route.Route.BGP.NextHop = &blackhole
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with blackhole next hop to match")
+	}
+	if !filters.MatchRoute(roundTripLookupRoute(t, route)) {
+		t.Error("expected stored route with blackhole next hop to match")
+	}
+
+	filters, err = fc.FiltersFromQuery(url.Values{"blackhole": {"false"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected blackhole route not to match")
+	}
+	route.Route.BGP.NextHop = nil
+	if !filters.MatchRoute(route) {
+		t.Error("expected route without blackhole to match")
+	}
+
+	if _, err := FiltersFromQuery(url.Values{"blackhole": {"maybe"}}); err == nil {
+		t.Error("expected error for invalid boolean")
+	}
+}
+
+func TestFiltersFromQueryStrict(t *testing.T) {
+	query := url.Values{
+		"asns":          {"2342"},
+		"q":             {"foo"},
+		"page":          {"2"},
+		"page_imported": {"1"},
+	}
+	if _, err := FiltersFromQueryStrict(query); err != nil {
+		t.Error(err)
+	}
+
+	query.Set("comunities", "23:42")
+	_, err := FiltersFromQueryStrict(query)
+	errUnknown, ok := err.(*ErrUnknownFilterKey)
+	if !ok {
+		t.Fatal("expected unknown filter key error, got:", err)
+	}
+	if errUnknown.Key != "comunities" {
+		t.Error("unexpected key:", errUnknown.Key)
+	}
+
+	// The lenient variant ignores unknown keys
+	if _, err := FiltersFromQuery(query); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSearchFilterMed(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.Route.BGP.Med = 150
+
+	tests := []struct {
+		query string
+		match bool
+	}{
+		{"150", true},
+		{"=150", true},
+		{"100", false},
+		{">100", true},
+		{">150", false},
+		{">=150", true},
+		{"<150", false},
+		{"<=150", true},
+		{"<100,>=150", true},
+		{"!>100", false},
+	}
+	for _, tt := range tests {
+		filters, err := FiltersFromQuery(url.Values{"med": {tt.query}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filters.MatchRoute(route) != tt.match {
+			t.Error("unexpected match for med", tt.query)
+		}
+	}
+
+	filters, _ := FiltersFromQuery(url.Values{"med": {">=100"}})
+	if q := filters.ToQuery().Get("med"); q != ">=100" {
+		t.Error("unexpected query:", q)
+	}
+
+	if _, err := FiltersFromQuery(url.Values{"med": {">>100"}}); err == nil {
+		t.Error("expected error for invalid comparison")
+	}
+}
+
+func TestSearchFilterLocalPref(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.Route.BGP.LocalPref = 200
+
+	filters, err := FiltersFromQuery(url.Values{"local_pref": {"200"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with local pref 200 to match")
+	}
+
+	filters, err = FiltersFromQuery(url.Values{"local_pref": {">150"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with local pref 200 to match >150")
+	}
+
+	// A route without local pref only matches
+	// when explicitly queried.
+	route.Route.BGP.LocalPref = 0
+	if filters.MatchRoute(route) {
+		t.Error("expected route without local pref not to match >150")
+	}
+	if !NewSearchFilters().MatchRoute(route) {
+		t.Error("expected route without local pref to match without filter")
+	}
+	filters, err = FiltersFromQuery(url.Values{"local_pref": {"0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route without local pref to match 0")
+	}
+}
+
+func TestSearchFilterOTC(t *testing.T) {
+	otc := 64500
+	route := makeTestLookupRoute()
+	route.Route.BGP.OTC = &otc
+
+	filters, err := FiltersFromQuery(url.Values{"otc": {"64500"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with otc 64500 to match")
+	}
+
+	filters, err = FiltersFromQuery(url.Values{"otc": {"set"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with otc to match otc=set")
+	}
+
+	route.Route.BGP.OTC = nil
+	if filters.MatchRoute(route) {
+		t.Error("expected route without otc not to match otc=set")
+	}
+	filters, _ = FiltersFromQuery(url.Values{"otc": {"64500"}})
+	if filters.MatchRoute(route) {
+		t.Error("expected route without otc not to match otc=64500")
+	}
+	filters, _ = FiltersFromQuery(url.Values{"otc": {"!set"}})
+	if !filters.MatchRoute(route) {
+		t.Error("expected route without otc to match otc=!set")
+	}
+
+	if _, err := FiltersFromQuery(url.Values{"otc": {"unset"}}); err == nil {
+		t.Error("expected error for invalid otc")
+	}
+}
+
+func TestSearchFilterCommunityLabel(t *testing.T) {
+	labels := MakeWellKnownBGPCommunities()
+	labels.Set("1000:23:42", "Redistribute to Switch01")
+	fc := &FilterContext{CommunityLabels: labels}
+
+	route := makeTestLookupRoute()
+
+	filters, err := fc.FiltersFromQuery(url.Values{"community_label": {"SWITCH"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with labeled large community to match")
+	}
+
+	filters, err = fc.FiltersFromQuery(url.Values{"community_label": {"blackhole"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected route without blackhole community not to match")
+	}
+
+	route.Route.BGP.Communities = append(
+		route.Route.BGP.Communities, Community{65535, 666})
+	if !filters.MatchRoute(route) {
+		t.Error("expected route with blackhole community to match")
+	}
+
+	// Communities without a label never match
+	filters, err = FiltersFromQuery(url.Values{"community_label": {"blackhole"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.MatchRoute(route) {
+		t.Error("expected route without labels not to match")
+	}
+}
+
+func TestSearchFilterCommunityRange(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.Route.BGP.Communities = Communities{
+		{65000, 150},
+		{65001, 1},
+	}
+	route.Route.BGP.LargeCommunities = Communities{
+		{1000, 23, 42},
+	}
+
+	tests := []struct {
+		query url.Values
+		match bool
+	}{
+		{url.Values{"communities": {"65000:100-200"}}, true},
+		{url.Values{"communities": {"65000:150-200"}}, true},
+		{url.Values{"communities": {"65000:100-150"}}, true},
+		{url.Values{"communities": {"65000:151-200"}}, false},
+		{url.Values{"communities": {"65000-65001:1"}}, true},
+		{url.Values{"communities": {"65000:100-200,65001:1"}}, true},
+		{url.Values{"communities": {"65000:100-200,65001:2"}}, false},
+		{url.Values{"communities": {"!65000:100-200"}}, false},
+		{url.Values{"large_communities": {"1000:*:40-50"}}, true},
+		{url.Values{"large_communities": {"1000:*:43-50"}}, false},
+		{url.Values{
+			"communities": {"65000:100-200"},
+			"asns":        {"23042"},
+		}, true},
+		{url.Values{
+			"communities": {"65000:100-200"},
+			"asns":        {"2342"},
+		}, false},
+	}
+	for _, tt := range tests {
+		filters, err := FiltersFromQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filters.MatchRoute(route) != tt.match {
+			t.Error("unexpected match for", tt.query)
+		}
+	}
+
+	filters, err := FiltersFromTokens([]string{"#65000:100-200"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route to match community range token")
+	}
+	if q := filters.ToQuery().Get("communities"); q != "65000:100-200" {
+		t.Error("unexpected query:", q)
+	}
+
+	if _, err := FiltersFromQuery(url.Values{"communities": {"65000:-200"}}); err == nil {
+		t.Error("expected error for invalid range")
+	}
+}
+
+func makeTestCommunityFilters(n int, key string) *SearchFilters {
+	filters := NewSearchFilters()
+	group := filters.GetGroupByKey(key)
+	for i := range n {
+		var value FilterValue = Community{65000, i}
+		if key == SearchKeyLargeCommunities {
+			value = Community{65000, 1, i}
+		}
+		group.AddFilter(&SearchFilter{Value: value})
+	}
+	return filters
+}
+
+func TestSearchFilterMatchCommunitiesCompiled(t *testing.T) {
+	route := makeTestLookupRoute()
+	route.Route.BGP.Communities = Communities{{65000, 499}, {23, 42}}
+	route.Route.BGP.LargeCommunities = Communities{{65000, 1, 3}}
+
+	filters := makeTestCommunityFilters(500, SearchKeyCommunities)
+	communities := filters.GetGroupByKey(SearchKeyCommunities)
+	if !communities.MatchAny(route) {
+		t.Error("expected route to match any of the communities")
+	}
+	if communities.MatchAll(route) {
+		t.Error("expected route not to match all communities")
+	}
+
+	large := makeTestCommunityFilters(500, SearchKeyLargeCommunities).
+		GetGroupByKey(SearchKeyLargeCommunities)
+	if !large.MatchAny(route) {
+		t.Error("expected route to match any of the large communities")
+	}
+
+	// A route carrying all communities (and some duplicates)
+	filters = makeTestCommunityFilters(compiledMatchMin, SearchKeyCommunities)
+	communities = filters.GetGroupByKey(SearchKeyCommunities)
+	route.Route.BGP.Communities = Communities{{23, 42}, {65000, 0}}
+	for i := range compiledMatchMin {
+		route.Route.BGP.Communities = append(
+			route.Route.BGP.Communities, Community{65000, i})
+	}
+	if !communities.MatchAll(route) {
+		t.Error("expected route to match all communities")
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route to match filters")
+	}
+
+	// Duplicates must not be counted twice
+	route.Route.BGP.Communities = Communities{}
+	for range compiledMatchMin {
+		route.Route.BGP.Communities = append(
+			route.Route.BGP.Communities, Community{65000, 0})
+	}
+	if communities.MatchAll(route) {
+		t.Error("expected route with duplicates not to match all")
+	}
+}
+
+func BenchmarkMatchCommunitiesCompiled(b *testing.B) {
+	route := makeTestLookupRoute()
+	route.Route.BGP.Communities = Communities{
+		{23, 42}, {111, 11}, {65000, 1234}, {65001, 1}, {65002, 2},
+		{65003, 3}, {65004, 4}, {65000, 499},
+	}
+	for _, n := range []int{4, 8, 16, 32, 500} {
+		group := makeTestCommunityFilters(n, SearchKeyCommunities).
+			GetGroupByKey(SearchKeyCommunities)
+		linear := &SearchFilterGroup{
+			Key:     SearchKeyCommunities,
+			Filters: group.Filters,
+		}
+		b.Run(fmt.Sprintf("linear/%d", n), func(b *testing.B) {
+			for b.Loop() {
+				linear.MatchAny(route)
+			}
+		})
+		b.Run(fmt.Sprintf("compiled/%d", n), func(b *testing.B) {
+			for b.Loop() {
+				group.matchCompiled(route, false)
+			}
+		})
+	}
+}
+
+func BenchmarkAddCommunityFilters(b *testing.B) {
+	communities := make([]Community, 0, 10000)
+	for i := range cap(communities) {
+		communities = append(communities, Community{65000 + i%100, i % 100})
+	}
+	filters := make([]*SearchFilter, len(communities))
+	b.ReportAllocs()
+	for b.Loop() {
+		group := &SearchFilterGroup{
+			Key:        SearchKeyCommunities,
+			filtersIdx: make(map[filterKey]int),
+		}
+		for i, c := range communities {
+			filters[i] = &SearchFilter{Value: c}
+		}
+		group.AddFilters(filters)
+	}
+}
+
+func TestSearchFiltersMatchedFilters(t *testing.T) {
+	route := makeTestLookupRoute()
+
+	values, _ := url.ParseQuery(
+		"communities=23:42,65000:1&communities=111:11&asns=23042,1")
+	filters, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.MatchRoute(route) {
+		t.Fatal("expected route to match")
+	}
+
+	matched := filters.MatchedFilters(route)
+	communities := matched[SearchKeyCommunities]
+	if len(communities) != 2 {
+		t.Fatal("expected two matched communities, got:", communities)
+	}
+	if communities[0].Name != "23:42" || communities[1].Name != "111:11" {
+		t.Error("unexpected matched communities:",
+			communities[0].Name, communities[1].Name)
+	}
+
+	asns := matched[SearchKeyASNS]
+	if len(asns) != 1 || asns[0].Value != 23042 {
+		t.Error("unexpected matched asns:", asns)
+	}
+
+	if _, ok := matched[SearchKeySources]; ok {
+		t.Error("expected no matched sources")
+	}
+}
+
+func TestSearchFiltersCommunityAliases(t *testing.T) {
+	fc := &FilterContext{
+		CommunityAliases: CommunityAliases{"blackhole": "65535:666"},
+	}
+
+	filters, err := fc.FiltersFromTokens([]string{"#blackhole"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	communities := filters.GetGroupByKey(SearchKeyCommunities)
+	if len(communities.Filters) != 1 ||
+		communities.Filters[0].Name != "65535:666" {
+		t.Error("unexpected communities:", communities.Filters)
+	}
+
+	values, _ := url.ParseQuery("communities=blackhole,23:42")
+	filters, err = fc.FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	communities = filters.GetGroupByKey(SearchKeyCommunities)
+	if len(communities.Filters) != 2 {
+		t.Error("unexpected communities:", communities.Filters)
+	}
+
+	// Aliases are only expanded for standard communities
+	values, _ = url.ParseQuery("large_communities=blackhole")
+	if _, err := fc.FiltersFromQuery(values); err == nil {
+		t.Error("expected error for alias in large communities")
+	}
+	values, _ = url.ParseQuery("ext_communities=blackhole")
+	if _, err := fc.FiltersFromQuery(values); err == nil {
+		t.Error("expected error for alias in ext communities")
+	}
+
+	// Without a context, aliases are not expanded
+	if _, err := FiltersFromTokens([]string{"#blackhole"}); err == nil {
+		t.Error("expected error without aliases")
+	}
+
+	// Unknown aliases are not expanded
+	if _, err := fc.FiltersFromTokens([]string{"#unknown"}); err == nil {
+		t.Error("expected error for unknown alias")
+	}
+}
+
+type testASNNamer map[int]string
+
+func (n testASNNamer) Name(asn int) string {
+	return n[asn]
+}
+
+func TestSearchFiltersResolveASNNames(t *testing.T) {
+	r1 := makeTestLookupRoute()
+	r2 := makeTestLookupRoute()
+	r2.Neighbor = &Neighbor{ASN: 2342}
+	r3 := makeTestLookupRoute()
+	r3.Neighbor = &Neighbor{ASN: 65000}
+
+	filters := NewSearchFilters()
+	for _, r := range []*LookupRoute{r1, r2, r3} {
+		filters.UpdateFromLookupRoute(r)
+	}
+
+	// Without a namer the names are left as is
+	filters.ResolveASNNames(nil)
+	asns := filters.GetGroupByKey(SearchKeyASNS)
+	if asns.GetFilterByValue(2342).Name != "" {
+		t.Error("expected empty name without namer")
+	}
+
+	filters.ResolveASNNames(testASNNamer{
+		23042: "Other Name",
+		2342:  "Example Networks",
+	})
+	if name := asns.GetFilterByValue(23042).Name; name != "Security Solutions Ltd." {
+		t.Error("expected neighbor description to be kept, got:", name)
+	}
+	if name := asns.GetFilterByValue(2342).Name; name != "Example Networks" {
+		t.Error("unexpected name:", name)
+	}
+	if name := asns.GetFilterByValue(65000).Name; name != "" {
+		t.Error("expected empty name for unknown ASN, got:", name)
+	}
+}
+
+func TestSearchFiltersUpdateFromRoutes(t *testing.T) {
+	routes := []*Route{
+		{
+			AddrFamily: AddrFamilyIPv4,
+			BGP:        &BGPInfo{Communities: Communities{{23, 42}}},
+		},
+		{
+			AddrFamily: AddrFamilyIPv6,
+			BGP:        &BGPInfo{Communities: Communities{{23, 42}, {65000, 1}}},
+		},
+		{
+			AddrFamily: AddrFamilyIPv4,
+			BGP:        &BGPInfo{Communities: Communities{{65000, 1}}},
+		},
+	}
+
+	filters := NewSearchFilters()
+	filters.UpdateFromRoutes(routes)
+
+	reversed := slices.Clone(routes)
+	slices.Reverse(reversed)
+	other := NewSearchFilters()
+	other.UpdateFromRoutes(reversed)
+
+	communities := filters.GetGroupByKey(SearchKeyCommunities)
+	for _, f := range communities.Filters {
+		if f.Cardinality != 2 {
+			t.Error("expected cardinality 2 for", f.Value, "got:", f.Cardinality)
+		}
+		o := other.GetGroupByKey(SearchKeyCommunities).GetFilterByValue(f.Value)
+		if o == nil || o.Cardinality != f.Cardinality {
+			t.Error("expected cardinality independent of order, got:", o)
+		}
+	}
+	if communities.Filters[0].Name != "23:42" {
+		t.Error("expected filters ordered by first occurrence")
+	}
+
+	if len(filters.GetGroupByKey(SearchKeyAddrFamily).Filters) != 2 {
+		t.Error("expected both address families")
+	}
+}
+
+func TestSearchFiltersUpdateMedFromRoutes(t *testing.T) {
+	routes := []*Route{}
+	for _, med := range []int{200, 50, 100, 200, 50, 0} {
+		routes = append(routes, &Route{BGP: &BGPInfo{Med: med}})
+	}
+
+	filters := NewSearchFilters()
+	filters.UpdateFromRoutes(routes)
+
+	reversed := slices.Clone(routes)
+	slices.Reverse(reversed)
+	other := NewSearchFilters()
+	other.UpdateFromRoutes(reversed)
+
+	expected := []string{"50", "200", "0", "100"}
+	for _, f := range []*SearchFilters{filters, other} {
+		group := f.GetGroupByKey(SearchKeyMed)
+		names := []string{}
+		for _, filter := range group.Filters {
+			names = append(names, filter.Name)
+		}
+		if !slices.Equal(names, expected) {
+			t.Error("unexpected med filters:", names)
+		}
+		if group.GetFilterByValue(IntComparison{Op: CmpOpEq, Value: 100}) == nil {
+			t.Error("expected index to be updated")
+		}
+	}
+
+	// Applied med filters are removed from the available
+	applied, err := FiltersFromQuery(url.Values{"med": {"200"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	available := filters.Sub(applied)
+	if len(available.GetGroupByKey(SearchKeyMed).Filters) != 3 {
+		t.Error("expected applied med to be removed:",
+			available.GetGroupByKey(SearchKeyMed).Filters)
+	}
+}
+
+func TestSearchFiltersClone(t *testing.T) {
+	filters := NewSearchFilters()
+	filters.UpdateFromLookupRoute(makeTestLookupRoute())
+	query, _ := url.ParseQuery("communities=23:42&communities=111:11,1:1")
+	applied, err := FiltersFromQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clone := applied.Clone()
+			clone.MergeProperties(filters)
+			clone.GetGroupByKey(SearchKeyCommunities).AddFilter(&SearchFilter{
+				Value: Community{65000, i},
+			})
+			if !clone.MatchRoute(makeTestLookupRoute()) {
+				t.Error("expected clone to match route")
+			}
+		}()
+	}
+	wg.Wait()
+
+	communities := applied.GetGroupByKey(SearchKeyCommunities)
+	if len(communities.Filters) != 3 {
+		t.Error("expected original to be unchanged, got:", communities.Filters)
+	}
+	for _, f := range communities.Filters {
+		if f.Cardinality != 1 {
+			t.Error("expected original cardinality, got:", f.Cardinality)
+		}
+	}
+
+	clone := applied.Clone()
+	if clone.GetGroupByKey(SearchKeyCommunities).Op != communities.Op {
+		t.Error("expected op to be cloned")
+	}
+	if clone.GetGroupByKey(SearchKeyCommunities).Filters[0] == communities.Filters[0] {
+		t.Error("expected filters to be copied")
+	}
+}
+
+func TestConcurrentSearchFiltersAddFilter(t *testing.T) {
+	filters := NewConcurrentSearchFilters()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				r := makeTestLookupRoute()
+				r.Neighbor = &Neighbor{ASN: i % 10}
+				filters.UpdateFromLookupRoute(r)
+			}
+		}()
+	}
+	wg.Wait()
+
+	asns := filters.GetGroupByKey(SearchKeyASNS)
+	if len(asns.Filters) != 10 {
+		t.Error("expected 10 asn filters, got:", len(asns.Filters))
+	}
+	for _, f := range asns.Filters {
+		if f.Cardinality != 80 {
+			t.Error("expected cardinality 80, got:", f.Cardinality)
+		}
+	}
+	communities := filters.GetGroupByKey(SearchKeyCommunities)
+	if f := communities.GetFilterByValue(Community{23, 42}); f.Cardinality != 800 {
+		t.Error("expected cardinality 800, got:", f.Cardinality)
+	}
+}
+
+func TestSearchFiltersCombineGroupOrder(t *testing.T) {
+	filters := NewSearchFilters()
+	filters.UpdateFromLookupRoute(makeTestLookupRoute())
+
+	// Build filters with a different group order,
+	// missing the sources group.
+	other := NewSearchFilters()
+	other.UpdateFromLookupRoute(makeTestLookupRoute())
+	reordered := slices.Clone((*other)[1:])
+	slices.Reverse(reordered)
+
+	combined := filters.Combine(&reordered)
+	for i, group := range *combined {
+		if group.Key != (*filters)[i].Key {
+			t.Error("expected group order of receiver, got:", group.Key)
+		}
+	}
+	if f := combined.GetGroupByKey(SearchKeyASNS).GetFilterByValue(23042); f.Cardinality != 2 {
+		t.Error("expected cardinality 2, got:", f.Cardinality)
+	}
+	if f := combined.GetGroupByKey(SearchKeySources).GetFilterByValue(testRsID); f.Cardinality != 1 {
+		t.Error("expected cardinality 1, got:", f.Cardinality)
+	}
+
+	diff := filters.Sub(&reordered)
+	if len(diff.GetGroupByKey(SearchKeyCommunities).Filters) != 0 {
+		t.Error("expected communities to be removed")
+	}
+	if len(diff.GetGroupByKey(SearchKeySources).Filters) != 1 {
+		t.Error("expected sources to be kept")
+	}
+
+	if reordered.GetGroupByKey(SearchKeySources) != nil {
+		t.Error("expected missing group to be nil")
+	}
+	if g := reordered.GetGroupByKey(SearchKeyCommunities); g.Key != SearchKeyCommunities {
+		t.Error("unexpected group:", g.Key)
+	}
+
+	// Must not panic with mismatched groups
+	filters.MergeProperties(&reordered)
+	reordered.SymmetricDiff(filters)
+}
+
+func TestSearchFiltersUpdateAddrFamilyFromRoute(t *testing.T) {
+	gw4 := "192.0.2.1"
+	gw6 := "2001:db8::1"
+	routes := []*Route{
+		{AddrFamily: AddrFamilyIPv4, Network: "10.0.0.0/8"},
+		{Network: "198.51.100.0/24"},
+		{Network: "2001:db8::/32"},
+		{Gateway: &gw4},
+		{Gateway: &gw6},
+		{}, // unknown
+	}
+
+	filters := NewSearchFilters()
+	for _, r := range routes {
+		filters.UpdateAddrFamilyFromRoute(r)
+	}
+
+	families := filters.GetGroupByKey(SearchKeyAddrFamily)
+	if len(families.Filters) != 2 {
+		t.Fatal("expected both address families, got:", families.Filters)
+	}
+	ip4 := families.GetFilterByValue(int(AddrFamilyIPv4))
+	if ip4 == nil || ip4.Cardinality != 3 || ip4.Name != "IPv4" {
+		t.Error("unexpected IPv4 filter:", ip4)
+	}
+	ip6 := families.GetFilterByValue(int(AddrFamilyIPv6))
+	if ip6 == nil || ip6.Cardinality != 2 || ip6.Name != "IPv6" {
+		t.Error("unexpected IPv6 filter:", ip6)
+	}
+}
+
+func TestSearchFilterAddrFamily(t *testing.T) {
+	ip4 := &Route{AddrFamily: AddrFamilyIPv4, Network: "10.0.0.0/8"}
+	ip6 := &Route{AddrFamily: AddrFamilyIPv6, Network: "2001:db8::/32"}
+
+	tests := []struct {
+		query string
+		ip4   bool
+		ip6   bool
+	}{
+		{"addr_family=1", true, false},
+		{"addr_family=2", false, true},
+		{"addr_family=0", true, true},
+	}
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		filters, err := FiltersFromQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The filter is an active facet
+		if len(filters.GetGroupByKey(SearchKeyAddrFamily).Filters) != 1 {
+			t.Error(test.query, "expected an address family filter")
+		}
+		if filters.MatchRoute(ip4) != test.ip4 {
+			t.Error(test.query, "unexpected match for IPv4 route")
+		}
+		if filters.MatchRoute(ip6) != test.ip6 {
+			t.Error(test.query, "unexpected match for IPv6 route")
+		}
+	}
+
+	filters := NewSearchFilters()
+	filters.addFilterAddrFamily(AddrFamilyBoth)
+	both := filters.GetGroupByKey(SearchKeyAddrFamily).
+		GetFilterByValue(AddrFamilyBoth)
+	if both == nil || both.Name != "IPv4/IPv6" {
+		t.Error("unexpected filter:", both)
+	}
+}
+
+func TestSearchFiltersMatchPlainRoute(t *testing.T) {
+	query, _ := url.ParseQuery(
+		"sources=rs1&asns=2342&peer_address=192.0.2.1&communities=23:42")
+	filters, err := FiltersFromQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Source, ASN and peer address filters are ignored
+	route := &Route{
+		BGP: &BGPInfo{Communities: Communities{{23, 42}}},
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route to match")
+	}
+
+	// Routes without BGP information do not match communities
+	if filters.MatchRoute(&Route{}) {
+		t.Error("expected route without BGP info not to match")
+	}
+}
+
+func TestSearchFilter4ByteASNCommunities(t *testing.T) {
+	route := &Route{
+		BGP: &BGPInfo{
+			LargeCommunities: Communities{{4200000000, 100, 4294967295}},
+		},
+	}
+
+	// A 4 byte ASN as large community
+	key, filter, err := (&FilterContext{}).parseCommunityFilterText("4200000000:100:4294967295")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != SearchKeyLargeCommunities {
+		t.Error("unexpected key:", key)
+	}
+	if filterValueAsString(filter.Value) != "4200000000:100:4294967295" {
+		t.Error("unexpected value:", filterValueAsString(filter.Value))
+	}
+
+	query, _ := url.ParseQuery("large_communities=4200000000:100:4294967295")
+	filters, warnings, err := NormalizeQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Error("unexpected warnings:", warnings)
+	}
+	if !filters.MatchRoute(route) {
+		t.Error("expected route to match large community")
+	}
+
+	// A 4 byte ASN is not a valid standard community
+	if _, err := ParseCommunity("4200000000:100"); err == nil {
+		t.Error("expected error for 4 byte ASN in standard community")
+	}
+	if _, err := ParseCommunity("65000:4200000000"); err == nil {
+		t.Error("expected error for 4 byte value in standard community")
+	}
+	query, _ = url.ParseQuery("communities=4200000000:100")
+	if _, _, err := NormalizeQuery(query); err == nil {
+		t.Error("expected error for 4 byte ASN in community filter")
+	}
+
+	// Values beyond 32 bit are rejected
+	if _, err := ParseLargeCommunity("4294967296:1:1"); err == nil {
+		t.Error("expected error for value exceeding 32 bit")
+	}
+}
+
+func TestFiltersFromQueryParseError(t *testing.T) {
+	tests := []struct {
+		query string
+		key   string
+		value string
+	}{
+		{"asns=2342,foo", SearchKeyASNS, "2342,foo"},
+		{"communities=23:42&communities=23:x", SearchKeyCommunities, "23:42,23:x"},
+		{"prefix_length=200", SearchKeyPrefixLength, "200"},
+		{"communities_op=some", SearchKeyCommunitiesOp, "some"},
+	}
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		_, err := FiltersFromQuery(query)
+		var parseErr *FilterParseError
+		if !errors.As(err, &parseErr) {
+			t.Error("expected parse error for", test.query, "got:", err)
+			continue
+		}
+		if parseErr.Key != test.key || parseErr.Value != test.value {
+			t.Error("unexpected parse error:", parseErr.Key, parseErr.Value)
+		}
+	}
+
+	query, _ := url.ParseQuery("prefix_length=200")
+	_, err := FiltersFromQuery(query)
+	if !errors.Is(err, ErrInvalidPrefixLength) {
+		t.Error("expected underlying error, got:", err)
+	}
+}
+
+func TestParseFilterTokens(t *testing.T) {
+	tokens := []string{
+		"#23:42", "2342", "AS3356", "@192.0.2.1", "@rs1",
+		"example", "Networks", "$$$", "foo=bar", "",
+	}
+	result, err := ParseFilterTokens(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filters := result.Filters
+
+	if filters.GetGroupByKey(SearchKeyCommunities).GetFilterByValue(Community{23, 42}) == nil {
+		t.Error("expected community filter")
+	}
+	asns := filters.GetGroupByKey(SearchKeyASNS)
+	if len(asns.Filters) != 2 ||
+		asns.GetFilterByValue(2342) == nil ||
+		asns.GetFilterByValue(3356) == nil {
+		t.Error("unexpected asn filters:", asns.Filters)
+	}
+	if filters.GetGroupByKey(SearchKeyPeerAddress).GetFilterByValue("192.0.2.1") == nil {
+		t.Error("expected peer address filter")
+	}
+	if filters.GetGroupByKey(SearchKeySources).GetFilterByValue("rs1") == nil {
+		t.Error("expected source filter")
+	}
+	if result.NeighborName != "example Networks" {
+		t.Error("unexpected neighbor name:", result.NeighborName)
+	}
+	if !slices.Equal(result.Ignored, []string{"$$$", "foo=bar"}) {
+		t.Error("unexpected ignored tokens:", result.Ignored)
+	}
+
+	if _, err := ParseFilterTokens([]string{"@"}); err == nil {
+		t.Error("expected error for empty peer token")
+	}
+}
+
+func TestSearchFilterPrefix(t *testing.T) {
+	route := makeTestLookupRoute()
+
+	tests := []struct {
+		network string
+		query   string
+		matched bool
+	}{
+		{"185.1.2.0/24", "185.1.0.0/16", true},
+		{"185.1.0.0/16", "185.1.0.0/16", true},
+		{"185.2.0.0/24", "185.1.0.0/16", false},
+		{"185.0.0.0/8", "185.1.0.0/16", false},
+		{"185.1.0.0/16", "exact:185.1.0.0/16", true},
+		{"185.1.2.0/24", "exact:185.1.0.0/16", false},
+		{"185.0.0.0/8", "covering:185.1.0.0/16", true},
+		{"185.1.2.0/24", "covering:185.1.0.0/16", false},
+		{"2001:db8:1::/48", "2001:db8::/32", true},
+		{"2001:db8::/32", "covering:2001:db8:1::/48", true},
+		{"2001:db8::/32", "EXACT:2001:db8::/32", true},
+		{"2001:db9::/32", "2001:db8::/32", false},
+		{"::ffff:185.1.2.0/120", "185.1.0.0/16", false},
+		{"185.1.2.0/24", "::/0", false},
+		{"185.1.2.0/24", "0.0.0.0/0", true},
+		{"invalid", "0.0.0.0/0", false},
+	}
+	for _, test := range tests {
+		route.Route.Network = test.network
+		filters, err := FiltersFromQuery(url.Values{"prefix": {test.query}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filters.MatchRoute(route) != test.matched {
+			t.Error("expected", test.network, "match", test.query, ":", test.matched)
+		}
+	}
+
+	filters, err := FiltersFromQuery(url.Values{"prefix": {"185.1.2.3/16"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query := filters.ToQuery().Get(SearchKeyPrefix); query != "185.1.0.0/16" {
+		t.Error("unexpected query:", query)
+	}
+
+	for _, value := range []string{
+		"185.1.0.0", "185.1.0.0/33", "foo", "nearby:185.1.0.0/16", "",
+	} {
+		_, err := FiltersFromQuery(url.Values{"prefix": {value}})
+		if err == nil {
+			t.Error("expected error for prefix:", value)
+		}
+	}
+}
+
+func makeTestMatchRoutes(n int) []Filterable {
+	routes := make([]Filterable, 0, n)
+	for i := range n {
+		route := makeTestLookupRoute()
+		route.Route.Network = fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)
+		route.Route.BGP.Communities = Communities{{65000, i % 3}}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func TestSearchFiltersMatchRoutes(t *testing.T) {
+	routes := makeTestMatchRoutes(1000)
+	filters, err := FiltersFromQuery(url.Values{"communities": {"65000:1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Filterable{}
+	for _, r := range routes {
+		if filters.MatchRoute(r) {
+			expected = append(expected, r)
+		}
+	}
+	if len(expected) != 333 {
+		t.Fatal("unexpected number of matching routes:", len(expected))
+	}
+
+	for _, workers := range []int{-1, 0, 1, 3, 8, 2000} {
+		matched := MatchRoutes(filters, routes, workers)
+		if !slices.Equal(matched, expected) {
+			t.Error("unexpected routes matched with", workers, "workers")
+		}
+	}
+
+	if matched := MatchRoutes(NewSearchFilters(), routes, 4); len(matched) != len(routes) {
+		t.Error("expected blank filters to match all routes")
+	}
+	if matched := MatchRoutes(filters, []Filterable(nil), 4); len(matched) != 0 {
+		t.Error("expected no routes to match")
+	}
+}
+
+// cancelingRoute cancels the context when
+// it is matched by its source.
+type cancelingRoute struct {
+	*LookupRoute
+	cancel context.CancelFunc
+}
+
+func (r *cancelingRoute) MatchSourceID(id string) bool {
+	r.cancel()
+	return r.LookupRoute.MatchSourceID(id)
+}
+
+func TestSearchFiltersMatchRoutesCtx(t *testing.T) {
+	routes := makeTestMatchRoutes(3 * matchRoutesCheckInterval)
+	filters, err := FiltersFromQuery(url.Values{"sources": {testRsID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matched, err := MatchRoutesCtx(context.Background(), filters, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != len(routes) {
+		t.Error("expected all routes to match, got:", len(matched))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	routes[matchRoutesCheckInterval+1] = &cancelingRoute{
+		LookupRoute: makeTestLookupRoute(),
+		cancel:      cancel,
+	}
+	matched, err = MatchRoutesCtx(ctx, filters, routes)
+	if !errors.Is(err, context.Canceled) {
+		t.Error("expected canceled error, got:", err)
+	}
+	if matched != nil {
+		t.Error("expected no routes after cancellation")
+	}
+
+	if _, err := MatchRoutesCtx(ctx, filters, routes[:1]); err == nil {
+		t.Error("expected error for done context")
+	}
+}
+
+func BenchmarkMatchRoutes(b *testing.B) {
+	routes := makeTestMatchRoutes(100000)
+	filters, err := FiltersFromQuery(url.Values{
+		"communities": {"65000:1"},
+		"prefix":      {"10.0.0.0/9"},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, workers := range []int{1, 2, 4, 0} {
+		b.Run(fmt.Sprintf("workers/%d", workers), func(b *testing.B) {
+			for b.Loop() {
+				MatchRoutes(filters, routes, workers)
+			}
+		})
+	}
+}
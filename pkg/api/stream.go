@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"iter"
+	"slices"
+)
+
+// WriteMatchingRoutesJSON writes all routes matching the
+// filters as a JSON array to the writer. The routes are
+// encoded one by one, so the result set is never buffered
+// as a whole. An empty array is written if no routes match.
+//
+// The number of written routes is returned.
+func WriteMatchingRoutesJSON(
+	w io.Writer,
+	routes iter.Seq[Filterable],
+	filters *SearchFilters,
+) (int, error) {
+	matching := func(yield func(Filterable) bool) {
+		for route := range routes {
+			if !filters.MatchRoute(route) {
+				continue
+			}
+			if !yield(route) {
+				return
+			}
+		}
+	}
+	return writeJSONArray(w, matching)
+}
+
+// WriteJSON writes the response as JSON to the writer.
+// The result is equivalent to json.Marshal, however the
+// imported and filtered routes are encoded one by one,
+// so the encoded routes are never buffered as a whole.
+func (res *PaginatedRoutesLookupResponse) WriteJSON(w io.Writer) error {
+	head := struct {
+		Response
+		TimedResponse
+		FilteredResponse
+	}{
+		res.Response,
+		res.TimedResponse,
+		res.FilteredResponse,
+	}
+	tail := struct {
+		Status        *StoreStatusMeta `json:"status"`
+		IgnoredTokens []string         `json:"ignored_tokens"`
+	}{
+		res.Status,
+		res.IgnoredTokens,
+	}
+
+	if err := writeJSONFields(w, "{", head); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"imported":`); err != nil {
+		return err
+	}
+	if err := writeRoutesLookupJSON(w, res.Imported); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"filtered":`); err != nil {
+		return err
+	}
+	if err := writeRoutesLookupJSON(w, res.Filtered); err != nil {
+		return err
+	}
+	if err := writeJSONFields(w, ",", tail); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+// writeRoutesLookupJSON streams the routes of the lookup
+// as JSON, see PaginatedRoutesLookupResponse.WriteJSON.
+func writeRoutesLookupJSON(w io.Writer, lookup *RoutesLookup) error {
+	if lookup == nil {
+		_, err := io.WriteString(w, "null")
+		return err
+	}
+	if _, err := io.WriteString(w, `{"routes":`); err != nil {
+		return err
+	}
+	if lookup.Routes == nil {
+		if _, err := io.WriteString(w, "null"); err != nil {
+			return err
+		}
+	} else if _, err := writeJSONArray(w, slices.Values(lookup.Routes)); err != nil {
+		return err
+	}
+	tail := struct {
+		Pagination Pagination `json:"pagination"`
+	}{
+		lookup.Pagination,
+	}
+	if err := writeJSONFields(w, ",", tail); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+// writeJSONFields encodes the struct v and writes its
+// fields without the enclosing braces, after the prefix.
+func writeJSONFields(w io.Writer, prefix string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, prefix); err != nil {
+		return err
+	}
+	_, err = w.Write(payload[1 : len(payload)-1])
+	return err
+}
+
+// writeJSONArray encodes the values one by one as
+// a JSON array. The number of values is returned.
+func writeJSONArray[T any](w io.Writer, values iter.Seq[T]) (int, error) {
+	enc := json.NewEncoder(w)
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for v := range values {
+		if n > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return n, err
+			}
+		}
+		if err := enc.Encode(v); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	if _, err := io.WriteString(w, "]"); err != nil {
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestWriteMatchingRoutesJSON(t *testing.T) {
+	r1 := makeTestLookupRoute()
+	r1.Route.Network = "10.0.0.0/24"
+	r2 := makeTestLookupRoute()
+	r2.Route.Network = "10.0.1.0/24"
+	r2.Neighbor = &Neighbor{ASN: 2342}
+	r3 := makeTestLookupRoute()
+	r3.Route.Network = "10.0.2.0/24"
+	routes := []Filterable{r1, r2, r3}
+
+	values, _ := url.ParseQuery("asns=23042")
+	filters, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	n, err := WriteMatchingRoutesJSON(buf, slices.Values(routes), filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Error("expected 2 routes, got:", n)
+	}
+
+	result := []*LookupRoute{}
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatal(err, buf.String())
+	}
+	if len(result) != 2 {
+		t.Fatal("expected 2 decoded routes, got:", len(result))
+	}
+	if result[0].Network != "10.0.0.0/24" || result[1].Network != "10.0.2.0/24" {
+		t.Error("unexpected routes:", buf.String())
+	}
+}
+
+func TestWriteMatchingRoutesJSONEmpty(t *testing.T) {
+	values, _ := url.ParseQuery("asns=1")
+	filters, err := FiltersFromQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	routes := []Filterable{makeTestLookupRoute()}
+	n, err := WriteMatchingRoutesJSON(buf, slices.Values(routes), filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("expected no routes, got:", n)
+	}
+	if buf.String() != "[]" {
+		t.Error("expected empty array, got:", buf.String())
+	}
+}
+
+func TestPaginatedRoutesLookupResponseWriteJSON(t *testing.T) {
+	filters := NewSearchFilters()
+	r1 := makeTestLookupRoute()
+	r1.Route.Network = "10.0.0.0/24"
+	r2 := makeTestLookupRoute()
+	r2.Route.Network = "10.0.1.0/24"
+	filters.UpdateFromLookupRoute(r1)
+	filters.UpdateFromLookupRoute(r2)
+
+	res := &PaginatedRoutesLookupResponse{
+		Response: Response{
+			Meta: &Meta{Version: "2.0.0", ResultFromCache: true},
+		},
+		TimedResponse: TimedResponse{RequestDuration: 23.42},
+		FilteredResponse: FilteredResponse{
+			FiltersAvailable: filters,
+		},
+		Imported: &RoutesLookup{
+			Routes:     LookupRoutes{r1, r2},
+			Pagination: Pagination{Page: 1, PageSize: 2, TotalResults: 2},
+		},
+		Status:        &StoreStatusMeta{},
+		IgnoredTokens: []string{"foo"},
+	}
+
+	for _, routes := range []LookupRoutes{res.Imported.Routes, {}, nil} {
+		res.Filtered = &RoutesLookup{Routes: routes}
+		expected, err := json.Marshal(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := &bytes.Buffer{}
+		if err := res.WriteJSON(buf); err != nil {
+			t.Fatal(err)
+		}
+		result := &bytes.Buffer{}
+		if err := json.Compact(result, buf.Bytes()); err != nil {
+			t.Fatal(err, buf.String())
+		}
+		if result.String() != string(expected) {
+			t.Error("unexpected encoding:", result.String(),
+				"expected:", string(expected))
+		}
+	}
+	res.Imported = nil
+	expected, _ := json.Marshal(res)
+	buf := &bytes.Buffer{}
+	if err := res.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := json.Compact(result, buf.Bytes()); err != nil {
+		t.Fatal(err, buf.String())
+	}
+	if result.String() != string(expected) {
+		t.Error("unexpected encoding without imported routes:", result.String())
+	}
+}
@@ -1,11 +1,17 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/alice-lg/alice-lg/pkg/api"
 	"github.com/alice-lg/alice-lg/pkg/decoders"
 )
@@ -15,34 +21,118 @@ func ErrInvalidCommunity(s string) error {
 	return fmt.Errorf("invalid community: %s", s)
 }
 
-// Helper parse communities from a section body
+// normalizeSectionBody strips a leading UTF-8 byte order
+// mark, normalizes CRLF line endings and replaces invalid
+// UTF-8 sequences.
+func normalizeSectionBody(body string) string {
+	body = strings.TrimPrefix(body, "\uFEFF")
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	return strings.ToValidUTF8(body, "\uFFFD")
+}
+
+// envVarPattern matches references to environment
+// variables like ${ASN}.
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces references to environment variables
+// like ${ASN} with their values. References to unset
+// variables are left unchanged.
+func expandEnv(s string) string {
+	return envVarPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			log.Println("Environment variable", name, "is not set in:", s)
+			return ref
+		}
+		return value
+	})
+}
+
+// A CommunityParseError is a line in a communities
+// section which could not be parsed.
+type CommunityParseError struct {
+	Line   int
+	Text   string
+	Reason string
+}
+
+// Error implements the error interface
+func (err *CommunityParseError) Error() string {
+	return fmt.Sprintf("line %d: %s: %q", err.Line, err.Reason, err.Text)
+}
+
+// validateCommunityKey checks that the community has
+// at least two components and none of them is empty.
+func validateCommunityKey(community string) error {
+	components := strings.Split(community, ":")
+	if len(components) < 2 {
+		return fmt.Errorf("incomplete community")
+	}
+	for _, c := range components {
+		if c == "" || strings.ContainsAny(c, " \t") {
+			return fmt.Errorf("invalid community component")
+		}
+	}
+	return nil
+}
+
+// Helper parse communities from a section body.
+// Malformed lines are skipped and logged.
 func parseAndMergeCommunities(
 	communities api.BGPCommunityMap, body string,
 ) api.BGPCommunityMap {
+	for _, err := range parseCommunitiesSection(communities, body) {
+		log.Println("Skipping malformed BGP community:", err)
+	}
+	return communities
+}
 
-	// Parse and merge communities
-	for line := range strings.Lines(body) {
-		kv := strings.SplitN(line, "=", 2)
+// parseCommunitiesSection merges the communities of a
+// section body into the communities map. An error is
+// returned for each line which could not be parsed.
+func parseCommunitiesSection(
+	communities api.BGPCommunityMap, body string,
+) []error {
+	errs := []error{}
+	lineNum := 0
+	for line := range strings.Lines(normalizeSectionBody(body)) {
+		lineNum++
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(text, "=", 2)
 		if len(kv) != 2 {
-			log.Println("Skipping malformed BGP community:", line)
+			errs = append(errs, &CommunityParseError{
+				Line:   lineNum,
+				Text:   text,
+				Reason: "missing label",
+			})
 			continue
 		}
 
-		community := strings.TrimSpace(kv[0])
-		label := strings.TrimSpace(kv[1])
+		community := expandEnv(strings.TrimSpace(kv[0]))
+		label := expandEnv(strings.TrimSpace(kv[1]))
+		if err := validateCommunityKey(community); err != nil {
+			errs = append(errs, &CommunityParseError{
+				Line:   lineNum,
+				Text:   text,
+				Reason: err.Error(),
+			})
+			continue
+		}
 		communities.Set(community, label)
 	}
 
-	return communities
+	return errs
 }
 
 // Parse a communities set with ranged communities
 func parseRangeCommunitiesSet(body string) (*api.BGPCommunitiesSet, error) {
-	comms := []api.BGPCommunityRange{}
-	large := []api.BGPCommunityRange{}
-	ext := []api.BGPCommunityRange{}
-
-	for line := range strings.Lines(body) {
+	lines := []string{}
+	for line := range strings.Lines(normalizeSectionBody(body)) {
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue // Empty
@@ -50,7 +140,20 @@ func parseRangeCommunitiesSet(body string) (*api.BGPCommunitiesSet, error) {
 		if strings.HasPrefix(line, "#") {
 			continue // Comment
 		}
-		comm, err := parseRangeCommunity(line)
+		lines = append(lines, line)
+	}
+	return makeRangeCommunitiesSet(lines)
+}
+
+// makeRangeCommunitiesSet creates a communities set
+// from a list of ranged communities.
+func makeRangeCommunitiesSet(ranges []string) (*api.BGPCommunitiesSet, error) {
+	comms := []api.BGPCommunityRange{}
+	large := []api.BGPCommunityRange{}
+	ext := []api.BGPCommunityRange{}
+
+	for _, r := range ranges {
+		comm, err := parseRangeCommunity(expandEnv(r))
 		if err != nil {
 			return nil, err
 		}
@@ -72,11 +175,156 @@ func parseRangeCommunitiesSet(body string) (*api.BGPCommunitiesSet, error) {
 	return set, nil
 }
 
+// ParseBGPCommunitiesJSON merges communities defined
+// in a JSON document into a communities map.
+// See mergeCommunitiesTree for the supported structure.
+func ParseBGPCommunitiesJSON(
+	communities api.BGPCommunityMap,
+	data []byte,
+) error {
+	tree := map[string]any{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return err
+	}
+	return mergeCommunitiesTree(communities, "", tree)
+}
+
+// ParseBGPCommunitiesYAML merges communities defined
+// in a YAML document into a communities map.
+// See mergeCommunitiesTree for the supported structure.
+func ParseBGPCommunitiesYAML(
+	communities api.BGPCommunityMap,
+	data []byte,
+) error {
+	tree := map[string]any{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return err
+	}
+	return mergeCommunitiesTree(communities, "", tree)
+}
+
+// mergeCommunitiesTree merges a decoded JSON or YAML
+// structure into the communities map. Communities may be
+// nested by component or use the full community as key:
+//
+//	{"65000": {"100": "label"}, "65000:200": "other label"}
+//
+// Both forms can be mixed.
+func mergeCommunitiesTree(
+	communities api.BGPCommunityMap,
+	prefix string,
+	tree any,
+) error {
+	var nodes map[string]any
+	switch t := tree.(type) {
+	case map[string]any:
+		nodes = t
+	case map[any]any: // YAML with non string keys
+		nodes = make(map[string]any, len(t))
+		for k, v := range t {
+			nodes[fmt.Sprint(k)] = v
+		}
+	case string:
+		if prefix == "" {
+			return ErrInvalidCommunity(t)
+		}
+		communities.Set(prefix, expandEnv(t))
+		return nil
+	default:
+		return ErrInvalidCommunity(fmt.Sprintf("%s = %v", prefix, tree))
+	}
+
+	for key, node := range nodes {
+		key = expandEnv(strings.TrimSpace(key))
+		if prefix != "" {
+			key = prefix + ":" + key
+		}
+		if err := mergeCommunitiesTree(communities, key, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseBGPCommunitiesSetJSON creates a communities set
+// from a JSON list of ranged communities, e.g.
+//
+//	["65535:666", "65000:100-200", "rt:1:2"]
+func ParseBGPCommunitiesSetJSON(data []byte) (*api.BGPCommunitiesSet, error) {
+	ranges := []string{}
+	if err := json.Unmarshal(data, &ranges); err != nil {
+		return nil, err
+	}
+	return makeRangeCommunitiesSet(ranges)
+}
+
+// ParseBGPCommunitiesSetYAML creates a communities set
+// from a YAML list of ranged communities.
+func ParseBGPCommunitiesSetYAML(data []byte) (*api.BGPCommunitiesSet, error) {
+	ranges := []string{}
+	if err := yaml.Unmarshal(data, &ranges); err != nil {
+		return nil, err
+	}
+	return makeRangeCommunitiesSet(ranges)
+}
+
+// loadBGPCommunitiesFile merges the communities of a
+// JSON or YAML file into the communities map. The
+// format is selected by the file extension.
+func loadBGPCommunitiesFile(
+	communities api.BGPCommunityMap,
+	filename string,
+) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return ParseBGPCommunitiesJSON(communities, data)
+	case ".yaml", ".yml":
+		return ParseBGPCommunitiesYAML(communities, data)
+	}
+	return fmt.Errorf("unsupported communities file format: %s", filename)
+}
+
+// loadBGPCommunitiesSetFile creates a communities set
+// from a JSON or YAML file. The format is selected by
+// the file extension.
+func loadBGPCommunitiesSetFile(filename string) (*api.BGPCommunitiesSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return ParseBGPCommunitiesSetJSON(data)
+	case ".yaml", ".yml":
+		return ParseBGPCommunitiesSetYAML(data)
+	}
+	return nil, fmt.Errorf("unsupported communities file format: %s", filename)
+}
+
+// ExtCommunityTypes maps the known textual type prefixes
+// of extended communities to the expected number of
+// components, including the type prefix.
+var ExtCommunityTypes = map[string]int{
+	"rt":      3,
+	"ro":      3,
+	"soo":     3,
+	"generic": 3,
+}
+
 func parseRangeCommunity(s string) (api.BGPCommunityRange, error) {
 	tokens := strings.Split(s, ":")
 	if len(tokens) < 2 {
 		return nil, ErrInvalidCommunity(s)
 	}
+	if !strings.Contains(s, "-") {
+		if err := validateSingleCommunity(tokens, s); err != nil {
+			return nil, err
+		}
+	}
 
 	// Extract ranges and make uniform structure
 	parts := make([][]string, 0, len(tokens))
@@ -85,6 +333,9 @@ func parseRangeCommunity(s string) (api.BGPCommunityRange, error) {
 		if len(values) == 0 {
 			return nil, ErrInvalidCommunity(s)
 		}
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
 		if len(values) == 1 {
 			parts = append(parts, []string{values[0], values[0]})
 		} else {
@@ -95,21 +346,27 @@ func parseRangeCommunity(s string) (api.BGPCommunityRange, error) {
 		return nil, ErrInvalidCommunity(s)
 	}
 
-	// Check if this might be an ext community
-	isExt := false
+	// Check if this is an ext community, starting
+	// with a known type prefix like rt or ro.
 	if _, err := strconv.Atoi(parts[0][0]); err != nil {
-		isExt = true // At least it looks like...
-	}
-
-	if isExt && len(parts) != 3 {
-		return nil, ErrInvalidCommunity(s)
-	}
-	if isExt {
-		return api.BGPCommunityRange{
-			[]string{parts[0][0], parts[0][0]},
-			decoders.IntListFromStrings(parts[1]),
-			decoders.IntListFromStrings(parts[2]),
-		}, nil
+		prefix := parts[0][0]
+		n, ok := ExtCommunityTypes[strings.ToLower(prefix)]
+		if !ok {
+			return nil, ErrInvalidCommunity(fmt.Sprintf(
+				"%s: unknown extended community type %q", s, prefix))
+		}
+		if len(parts) != n {
+			return nil, ErrInvalidCommunity(fmt.Sprintf(
+				"%s: extended community type %q expects %d components",
+				s, prefix, n))
+		}
+		comm := api.BGPCommunityRange{
+			[]string{prefix, prefix},
+		}
+		for _, p := range parts[1:] {
+			comm = append(comm, decoders.IntListFromStrings(p))
+		}
+		return comm, nil
 	}
 	comm := api.BGPCommunityRange{}
 	for _, p := range parts {
@@ -118,6 +375,74 @@ func parseRangeCommunity(s string) (api.BGPCommunityRange, error) {
 	return comm, nil
 }
 
+// validateSingleCommunity checks a community without
+// ranges using the canonical community parsers.
+func validateSingleCommunity(tokens []string, s string) error {
+	var err error
+	if _, convErr := strconv.Atoi(strings.TrimSpace(tokens[0])); convErr != nil {
+		_, err = api.ParseExtCommunity(s)
+	} else if len(tokens) == 2 {
+		_, err = api.ParseCommunity(s)
+	} else {
+		_, err = api.ParseLargeCommunity(s)
+	}
+	return err
+}
+
+// Parse the community aliases section. Aliases
+// colliding with a community literal (containing a ':'),
+// defined more than once, or not referring to a single
+// standard community are skipped.
+func parseCommunityAliases(body string) api.CommunityAliases {
+	aliases := api.CommunityAliases{}
+	for line := range strings.Lines(normalizeSectionBody(body)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			log.Println("Skipping malformed BGP community alias:", line)
+			continue
+		}
+		alias := strings.TrimSpace(kv[0])
+		community := expandEnv(strings.TrimSpace(kv[1]))
+		if alias == "" || strings.Contains(alias, ":") {
+			log.Println(
+				"Skipping BGP community alias colliding with a community:",
+				alias)
+			continue
+		}
+		if _, ok := aliases[alias]; ok {
+			log.Println("Skipping duplicate BGP community alias:", alias)
+			continue
+		}
+		if err := validateAliasCommunity(community); err != nil {
+			log.Println("Skipping BGP community alias", alias+":", err)
+			continue
+		}
+		aliases[alias] = community
+	}
+	return aliases
+}
+
+// validateAliasCommunity checks that the community is
+// a single standard community. Aliases are not supported
+// for extended and large communities.
+func validateAliasCommunity(s string) error {
+	tokens := strings.Split(s, ":")
+	if len(tokens) != 2 {
+		return ErrInvalidCommunity(s)
+	}
+	for _, t := range tokens {
+		v, err := strconv.Atoi(t)
+		if err != nil || v < 0 || v > 65535 {
+			return ErrInvalidCommunity(s)
+		}
+	}
+	return nil
+}
+
 // Parse rejection candidate section
 func parseRejectionCandidateCommunities(comms api.BGPCommunityMap, s string) error {
 	lines := strings.Split(s, "\n")
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alice-lg/alice-lg/pkg/api"
+)
+
+func TestParseBGPCommunitiesStructured(t *testing.T) {
+	text := parseAndMergeCommunities(api.BGPCommunityMap{}, `
+65000:100 = customer
+65000:200 = peer
+65000:1:23 = large tag
+`)
+
+	jsonComms := api.BGPCommunityMap{}
+	err := ParseBGPCommunitiesJSON(jsonComms, []byte(`{
+		"65000": {"100": "customer", "1": {"23": "large tag"}},
+		"65000:200": "peer"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yamlComms := api.BGPCommunityMap{}
+	err = ParseBGPCommunitiesYAML(yamlComms, []byte(`
+65000:
+  100: customer
+  1:
+    23: large tag
+"65000:200": peer
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(text, jsonComms) {
+		t.Error("expected JSON communities", jsonComms, "to equal", text)
+	}
+	if !reflect.DeepEqual(text, yamlComms) {
+		t.Error("expected YAML communities", yamlComms, "to equal", text)
+	}
+
+	label, err := yamlComms.Lookup("65000:1:23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label != "large tag" {
+		t.Error("unexpected label:", label)
+	}
+}
+
+func TestLoadBGPCommunitiesFile(t *testing.T) {
+	communities := api.BGPCommunityMap{}
+	err := loadBGPCommunitiesFile(communities, "testdata/bgp_communities.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label, _ := communities.Lookup("65000:200"); label != "peer" {
+		t.Error("unexpected label:", label)
+	}
+
+	set, err := loadBGPCommunitiesSetFile("testdata/blackhole_communities.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Standard) != 1 || len(set.Large) != 1 {
+		t.Error("unexpected communities:", set)
+	}
+
+	err = loadBGPCommunitiesFile(communities, "testdata/alice.conf")
+	if err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestParseBGPCommunitiesStructuredInvalid(t *testing.T) {
+	err := ParseBGPCommunitiesJSON(
+		api.BGPCommunityMap{}, []byte(`{"65000": {"100": 42}}`))
+	if err == nil {
+		t.Error("expected error for non string label")
+	}
+	err = ParseBGPCommunitiesJSON(api.BGPCommunityMap{}, []byte(`[1, 2]`))
+	if err == nil {
+		t.Error("expected error for list")
+	}
+}
+
+func TestParseBGPCommunitiesSetStructured(t *testing.T) {
+	text, err := parseRangeCommunitiesSet(`
+65535:666
+1337:0-10
+rt:1324:4200000000
+23:24:25-30
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonSet, err := ParseBGPCommunitiesSetJSON([]byte(`[
+		"65535:666", "1337:0-10", "rt:1324:4200000000", "23:24:25-30"
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yamlSet, err := ParseBGPCommunitiesSetYAML([]byte(`
+- "65535:666"
+- "1337:0-10"
+- "rt:1324:4200000000"
+- "23:24:25-30"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(text, jsonSet) {
+		t.Error("expected JSON set", jsonSet, "to equal", text)
+	}
+	if !reflect.DeepEqual(text, yamlSet) {
+		t.Error("expected YAML set", yamlSet, "to equal", text)
+	}
+}
+
+func TestParseCommunityAliases(t *testing.T) {
+	aliases := parseCommunityAliases(`
+# comment
+bh = 65535:666
+rt-cust = rt:65000:1
+large = 65000:1:2
+65000:100 = 65000:200
+bh = 65535:667
+broken = 65000
+range = 65000:1-10
+overflow = 65000:70000
+`)
+	expected := api.CommunityAliases{
+		"bh": "65535:666",
+	}
+	if !reflect.DeepEqual(aliases, expected) {
+		t.Error("unexpected aliases:", aliases)
+	}
+}
+
+func TestParseCommunityAliasesBOMAndCRLF(t *testing.T) {
+	aliases := parseCommunityAliases(
+		"\uFEFFbh = 65535:666\r\n# comment\r\nrtbh = 65535:667\r\n")
+	expected := api.CommunityAliases{
+		"bh":   "65535:666",
+		"rtbh": "65535:667",
+	}
+	if !reflect.DeepEqual(aliases, expected) {
+		t.Error("unexpected aliases:", aliases)
+	}
+}
+
+func TestParseCommunitiesBOMAndCRLF(t *testing.T) {
+	body := "\uFEFF1:23 = some tag\r\n9033:65666:1 = bogon \xff\r\n"
+	communities := parseAndMergeCommunities(make(api.BGPCommunityMap), body)
+
+	label, err := communities.Lookup("1:23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label != "some tag" {
+		t.Error("unexpected label:", label)
+	}
+	label, err = communities.Lookup("9033:65666:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label != "bogon \uFFFD" {
+		t.Errorf("unexpected label: %q", label)
+	}
+
+	set, err := parseRangeCommunitiesSet("\uFEFF65535:666\r\n1337:0-10\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Standard) != 2 {
+		t.Fatal("unexpected communities:", set.Standard)
+	}
+	if set.Standard[0][0].([]int)[0] != 65535 {
+		t.Error("unexpected community:", set.Standard[0])
+	}
+}
+
+func TestParseCommunitiesExpandEnv(t *testing.T) {
+	t.Setenv("ALICE_TEST_ASN", "64500")
+	t.Setenv("ALICE_TEST_SWITCH", "switch01")
+
+	body := "${ALICE_TEST_ASN}:911:1 = Redistribute to ${ALICE_TEST_SWITCH}\n" +
+		"${ALICE_TEST_UNSET}:1 = Unset ${ALICE_TEST_UNSET}\n"
+	communities := parseAndMergeCommunities(make(api.BGPCommunityMap), body)
+
+	label, err := communities.Lookup("64500:911:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label != "Redistribute to switch01" {
+		t.Error("unexpected label:", label)
+	}
+
+	// Unset variables are left unchanged
+	label, err = communities.Lookup("${ALICE_TEST_UNSET}:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label != "Unset ${ALICE_TEST_UNSET}" {
+		t.Error("unexpected label:", label)
+	}
+
+	set, err := parseRangeCommunitiesSet("${ALICE_TEST_ASN}:666\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Standard[0][0].([]int)[0] != 64500 {
+		t.Error("unexpected community:", set.Standard[0])
+	}
+	if _, err := parseRangeCommunitiesSet("${ALICE_TEST_UNSET}:666\n"); err == nil {
+		t.Error("expected error for unset variable in community")
+	}
+}
+
+func TestParseCommunitiesSectionErrors(t *testing.T) {
+	body := "# Communities\n" +
+		"1:23 = some tag\n" +
+		"\n" +
+		"1:42 some tag\n" +
+		"23 = incomplete\n" +
+		"23::1 = empty component\n" +
+		"0:* = wildcard\n"
+	communities := make(api.BGPCommunityMap)
+	errs := parseCommunitiesSection(communities, body)
+	if len(errs) != 3 {
+		t.Fatal("unexpected errors:", errs)
+	}
+
+	lines := []int{4, 5, 6}
+	for i, err := range errs {
+		parseErr, ok := err.(*CommunityParseError)
+		if !ok {
+			t.Fatal("unexpected error:", err)
+		}
+		if parseErr.Line != lines[i] {
+			t.Error("unexpected line:", parseErr.Line, err)
+		}
+	}
+	if errs[0].Error() != `line 4: missing label: "1:42 some tag"` {
+		t.Error("unexpected error message:", errs[0])
+	}
+
+	if _, err := communities.Lookup("1:23"); err != nil {
+		t.Error(err)
+	}
+	if _, err := communities.Lookup("0:1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseRangeCommunityExt(t *testing.T) {
+	comm, err := parseRangeCommunity("rt:65000:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comm.Type() != api.BGPCommunityTypeExt {
+		t.Error("expected ext community, got:", comm)
+	}
+	if comm.String() != "rt:65000:100" {
+		t.Error("unexpected community:", comm)
+	}
+
+	if _, err := parseRangeCommunity("rt:65000"); err == nil {
+		t.Error("expected error for incomplete ext community")
+	}
+	_, err = parseRangeCommunity("foo:65000:100")
+	if err == nil || !strings.Contains(err.Error(), "unknown extended community type") {
+		t.Error("expected unknown type error, got:", err)
+	}
+}
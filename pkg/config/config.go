@@ -9,7 +9,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -97,6 +100,14 @@ type ServerConfig struct {
 	EnableNeighborsStatusRefresh      bool   `ini:"enable_neighbors_status_refresh"`
 	StreamParserThrottle              int    `ini:"stream_parser_throttle"`
 	EnableMetrics                     bool   `ini:"enable_metrics"`
+	ASPathLengthIgnorePrepends        bool   `ini:"as_path_length_ignore_prepends"`
+	QueryDefaults                     string `ini:"query_defaults"`
+	BGPCommunitiesFile                string `ini:"bgp_communities_file"`
+	BlackholeCommunitiesFile          string `ini:"blackhole_communities_file"`
+
+	// QueryDefaultFilters are parsed from QueryDefaults
+	// and applied to route queries missing these filters.
+	QueryDefaultFilters url.Values `ini:"-"`
 }
 
 // PostgresConfig is the configuration for the database
@@ -162,9 +173,15 @@ type UIConfig struct {
 	RoutesRejectCandidates RejectCandidatesConfig
 
 	BGPCommunities          api.BGPCommunityMap
+	BGPCommunityAliases     api.CommunityAliases
 	BGPBlackholeCommunities api.BGPCommunitiesSet
 	Rpki                    RpkiConfig
 
+	// BGPCommunitiesExpanded are the BGPCommunities with
+	// the ranges of the BGPBlackholeCommunities expanded
+	// into labeled communities. Other sets are not expanded.
+	BGPCommunitiesExpanded api.BGPCommunityMap
+
 	Theme ThemeConfig
 
 	Pagination PaginationConfig
@@ -193,6 +210,15 @@ type SourceConfig struct {
 	// Blackhole IPs
 	Blackholes []string
 
+	// Addresses of the route server itself
+	SelfAddresses []string
+
+	// BlackholeIPs and SelfAddressIPs are parsed from
+	// Blackholes and SelfAddresses. Invalid addresses
+	// are skipped.
+	BlackholeIPs   []net.IP
+	SelfAddressIPs []net.IP
+
 	// Source configurations
 	Type        string
 	Backend     string
@@ -404,15 +430,35 @@ func getLookupColumns(config *ini.File) (
 }
 
 // Get UI config: BGP Communities
-func getBGPCommunityMap(config *ini.File) api.BGPCommunityMap {
+// Communities from the communities file are merged after
+// the communities of the config section.
+func getBGPCommunityMap(
+	config *ini.File,
+	communitiesFile string,
+) (api.BGPCommunityMap, error) {
 	// Load defaults
 	communities := api.MakeWellKnownBGPCommunities()
 	communitiesConfig := config.Section("bgp_communities")
-	if communitiesConfig == nil {
-		return communities // nothing else to do here, go with the default
+	if communitiesConfig != nil {
+		communities = parseAndMergeCommunities(
+			communities, communitiesConfig.Body())
+	}
+	if communitiesFile == "" {
+		return communities, nil
+	}
+	if err := loadBGPCommunitiesFile(communities, communitiesFile); err != nil {
+		return nil, err
 	}
+	return communities, nil
+}
 
-	return parseAndMergeCommunities(communities, communitiesConfig.Body())
+// Get UI config: BGP community aliases
+func getBGPCommunityAliases(config *ini.File) api.CommunityAliases {
+	section := config.Section("bgp_community_aliases")
+	if section == nil {
+		return api.CommunityAliases{}
+	}
+	return parseCommunityAliases(section.Body())
 }
 
 // Get UI config: Get rejections
@@ -454,19 +500,34 @@ func getRoutesNoexports(config *ini.File) (NoexportsConfig, error) {
 }
 
 // Get UI config: blackhole communities
-func getBlackholeCommunities(config *ini.File) (api.BGPCommunitiesSet, error) {
+// Communities from the communities file are added to
+// the communities of the config section.
+func getBlackholeCommunities(
+	config *ini.File,
+	communitiesFile string,
+) (api.BGPCommunitiesSet, error) {
 	section := config.Section("blackhole_communities")
 	defaultBlackholes := api.BGPCommunitiesSet{
 		Standard: []api.BGPCommunityRange{
 			{[]any{65535, 65535}, []any{666, 666}},
 		},
 	}
-	if section == nil {
-		return defaultBlackholes, nil
+	set := &api.BGPCommunitiesSet{}
+	if section != nil {
+		s, err := parseRangeCommunitiesSet(section.Body())
+		if err != nil {
+			return defaultBlackholes, err
+		}
+		set = s
 	}
-	set, err := parseRangeCommunitiesSet(section.Body())
-	if err != nil {
-		return defaultBlackholes, err
+	if communitiesFile != "" {
+		s, err := loadBGPCommunitiesSetFile(communitiesFile)
+		if err != nil {
+			return defaultBlackholes, err
+		}
+		set.Standard = append(set.Standard, s.Standard...)
+		set.Extended = append(set.Extended, s.Extended...)
+		set.Large = append(set.Large, s.Large...)
 	}
 	set.Standard = append(set.Standard, defaultBlackholes.Standard...)
 	return *set, nil
@@ -617,7 +678,7 @@ func getPaginationConfig(config *ini.File) PaginationConfig {
 }
 
 // Get the UI configuration from the config file
-func getUIConfig(config *ini.File) (UIConfig, error) {
+func getUIConfig(config *ini.File, server ServerConfig) (UIConfig, error) {
 	uiConfig := UIConfig{}
 
 	// Get route columns
@@ -661,10 +722,23 @@ func getUIConfig(config *ini.File) (UIConfig, error) {
 	}
 
 	// Blackhole communities
-	blackholeCommunities, err := getBlackholeCommunities(config)
+	blackholeCommunities, err := getBlackholeCommunities(
+		config, server.BlackholeCommunitiesFile)
+	if err != nil {
+		return uiConfig, err
+	}
+
+	// Community labels
+	bgpCommunities, err := getBGPCommunityMap(config, server.BGPCommunitiesFile)
 	if err != nil {
 		return uiConfig, err
 	}
+	blackholeCommunities.Labels = bgpCommunities
+
+	// Expand the blackhole community ranges into the labels
+	// once, so clients can resolve them without ranges.
+	bgpCommunitiesExpanded := blackholeCommunities.ExpandLabels(
+		bgpCommunities, api.MaxCommunityRangeExpansion)
 
 	// Theme configuration: Theming is optional, if no settings
 	// are found, it will be ignored
@@ -689,9 +763,12 @@ func getUIConfig(config *ini.File) (UIConfig, error) {
 		RoutesRejectCandidates: rejectCandidates,
 
 		BGPBlackholeCommunities: blackholeCommunities,
-		BGPCommunities:          getBGPCommunityMap(config),
+		BGPCommunities:          bgpCommunities,
+		BGPCommunityAliases:     getBGPCommunityAliases(config),
 		Rpki:                    rpki,
 
+		BGPCommunitiesExpanded: bgpCommunitiesExpanded,
+
 		Theme: themeConfig,
 
 		Pagination: paginationConfig,
@@ -700,6 +777,21 @@ func getUIConfig(config *ini.File) (UIConfig, error) {
 	return uiConfig, nil
 }
 
+// parseIPAddrs parses the configured addresses of a
+// source. Invalid addresses are logged and skipped.
+func parseIPAddrs(sourceID string, addrs []string) []net.IP {
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			log.Println("Ignoring invalid address", addr, "of source", sourceID)
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
 func getSources(config *ini.File) ([]*SourceConfig, error) {
 	sources := []*SourceConfig{}
 
@@ -740,15 +832,21 @@ func getSources(config *ini.File) ([]*SourceConfig, error) {
 		sourceGroup := section.Key("group").MustString("")
 		sourceBlackholes := decoders.TrimmedCSVStringList(
 			section.Key("blackholes").MustString(""))
+		sourceSelfAddresses := decoders.TrimmedCSVStringList(
+			section.Key("self_addresses").MustString(""))
 
 		srcCfg := &SourceConfig{
-			ID:         sourceID,
-			Order:      order,
-			Name:       sourceName,
-			Group:      sourceGroup,
-			Blackholes: sourceBlackholes,
-			Backend:    backendType,
-			Type:       sourceType,
+			ID:            sourceID,
+			Order:         order,
+			Name:          sourceName,
+			Group:         sourceGroup,
+			Blackholes:    sourceBlackholes,
+			Backend:       backendType,
+			Type:          sourceType,
+			SelfAddresses: sourceSelfAddresses,
+
+			BlackholeIPs:   parseIPAddrs(sourceID, sourceBlackholes),
+			SelfAddressIPs: parseIPAddrs(sourceID, sourceSelfAddresses),
 		}
 
 		// Register route server ID with pool
@@ -922,6 +1020,7 @@ func LoadConfig(file string) (*Config, error) {
 	parsedConfig, err := ini.LoadSources(ini.LoadOptions{
 		UnparseableSections: []string{
 			"bgp_communities",
+			"bgp_community_aliases",
 			"blackhole_communities",
 			"rejection_reasons",
 			"rejection_candidates",
@@ -946,6 +1045,17 @@ func LoadConfig(file string) (*Config, error) {
 	if err := parsedConfig.Section("server").MapTo(&server); err != nil {
 		return nil, err
 	}
+	defaults, err := parseQueryDefaults(server.QueryDefaults)
+	if err != nil {
+		return nil, err
+	}
+	server.QueryDefaultFilters = defaults
+
+	// Communities files are relative to the config file
+	server.BGPCommunitiesFile = configRelativePath(
+		file, server.BGPCommunitiesFile)
+	server.BlackholeCommunitiesFile = configRelativePath(
+		file, server.BlackholeCommunitiesFile)
 
 	// Database config
 	psql := &PostgresConfig{
@@ -971,7 +1081,7 @@ func LoadConfig(file string) (*Config, error) {
 	}
 
 	// Get UI configurations
-	ui, err := getUIConfig(parsedConfig)
+	ui, err := getUIConfig(parsedConfig, server)
 	if err != nil {
 		return nil, err
 	}
@@ -1017,6 +1127,16 @@ func (cfg *SourceConfig) GetInstance() sources.Source {
 	return instance
 }
 
+// configRelativePath resolves a path relative to
+// the directory of the config file. Empty and
+// absolute paths are returned as they are.
+func configRelativePath(configFile, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configFile), path)
+}
+
 // Get configuration file with fallbacks
 func getConfigFile(filename string) (string, error) {
 	// Check if requested file is present
@@ -1035,3 +1155,16 @@ func getConfigFile(filename string) (string, error) {
 
 	return filename, nil
 }
+
+// parseQueryDefaults parses the default filters
+// of a query, e.g. addr_family=1&asns=23,42.
+func parseQueryDefaults(defaults string) (url.Values, error) {
+	query, err := url.ParseQuery(defaults)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query_defaults: %w", err)
+	}
+	if _, _, err := api.NormalizeQuery(query); err != nil {
+		return nil, fmt.Errorf("invalid query_defaults: %w", err)
+	}
+	return query, nil
+}
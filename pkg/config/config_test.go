@@ -3,6 +3,7 @@ package config
 import (
 	"testing"
 
+	"github.com/alice-lg/alice-lg/pkg/api"
 	"github.com/alice-lg/alice-lg/pkg/sources/birdwatcher"
 	"github.com/alice-lg/alice-lg/pkg/sources/gobgp"
 )
@@ -43,6 +44,13 @@ func TestLoadConfigs(t *testing.T) {
 	if config.Server.PrefixLookupCommunityFilterCutoff != 123 {
 		t.Error("Expected PrefixLookupCommunityFilterCutoff to be 123")
 	}
+
+	if !config.Server.ASPathLengthIgnorePrepends {
+		t.Error("Expected ASPathLengthIgnorePrepends to be set")
+	}
+	if config.Server.QueryDefaultFilters.Get("addr_family") != "1" {
+		t.Error("Unexpected query defaults:", config.Server.QueryDefaultFilters)
+	}
 }
 
 // TestSourceConfig checks that the proper backend type was identified for each
@@ -170,6 +178,18 @@ func TestBlackholeParsing(t *testing.T) {
 	if rs1.Blackholes[0] != "10.23.6.666" {
 		t.Error("Unexpected blackhole, got:", rs1.Blackholes[0])
 	}
+
+	if len(rs1.SelfAddresses) != 1 || rs1.SelfAddresses[0] != "10.23.6.1" {
+		t.Error("Unexpected self addresses, got:", rs1.SelfAddresses)
+	}
+
+	// The blackholes of the test config are not valid addresses
+	if len(rs1.BlackholeIPs) != 0 {
+		t.Error("Expected invalid blackholes to be skipped, got:", rs1.BlackholeIPs)
+	}
+	if len(rs1.SelfAddressIPs) != 1 || rs1.SelfAddressIPs[0].String() != "10.23.6.1" {
+		t.Error("Unexpected parsed self addresses, got:", rs1.SelfAddressIPs)
+	}
 }
 
 func TestRpkiConfig(t *testing.T) {
@@ -267,8 +287,60 @@ func TestGetBlackholeCommunities(t *testing.T) {
 	if len(comms.Extended) != 1 {
 		t.Error("unexpected communities:", comms.Extended)
 	}
-	if len(comms.Large) != 1 {
+	if len(comms.Large) != 2 {
 		t.Error("unexpected communities:", comms.Large)
 	}
 	t.Log(comms)
+
+	// The labels are expanded once when loading the config
+	label, err := config.UI.BGPCommunitiesExpanded.Lookup("1:23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label != "some tag" {
+		t.Error("unexpected label:", label)
+	}
+	if _, ok := comms.Label(api.Community{1, 23}); !ok {
+		t.Error("expected label for 1:23 from the blackhole set")
+	}
+}
+
+func TestBGPCommunitiesFiles(t *testing.T) {
+	config, err := LoadConfig("testdata/alice.conf")
+	if err != nil {
+		t.Fatal("Could not load test config:", err)
+	}
+
+	label, err := config.UI.BGPCommunities.Lookup("65000:1:23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label != "large tag" {
+		t.Error("unexpected label:", label)
+	}
+	// Communities from the config section are kept
+	if _, err := config.UI.BGPCommunities.Lookup("1:23"); err != nil {
+		t.Error(err)
+	}
+
+	comms := config.UI.BGPBlackholeCommunities
+	if !comms.Matches(api.Community{65000, 666}) {
+		t.Error("expected 65000:666 in blackhole communities")
+	}
+	if !comms.Matches(api.Community{65000, 1, 666}) {
+		t.Error("expected 65000:1:666 in blackhole communities")
+	}
+	if !comms.Matches(api.Community{1337, 666}) {
+		t.Error("expected 1337:666 in blackhole communities")
+	}
+}
+
+func TestBGPCommunityAliasesConfig(t *testing.T) {
+	config, err := LoadConfig("testdata/alice.conf")
+	if err != nil {
+		t.Fatal("Could not load test config:", err)
+	}
+	if config.UI.BGPCommunityAliases.Expand("blackhole") != "65535:666" {
+		t.Error("unexpected aliases:", config.UI.BGPCommunityAliases)
+	}
 }
@@ -129,7 +129,9 @@ func (e ExpandMap) Expand(s string) ([]string, error) {
 		subs := e.getSubstitutions(key)
 		if len(subs) == 0 {
 			level := expandGetLevel(p)
-			if level == 1 {
+			// References to environment variables like ${VAR}
+			// are expanded later and not by the config.
+			if level == 1 && !strings.Contains(s, "$"+p) {
 				err := fmt.Errorf("no substitution for %s in '%s'", p, s)
 				return []string{}, err
 			}
@@ -137,6 +139,9 @@ func (e ExpandMap) Expand(s string) ([]string, error) {
 		}
 		substitutions[p] = subs
 	}
+	if len(substitutions) == 0 {
+		return []string{s}, nil
+	}
 
 	// Apply substitutions
 	subsRes := []string{s}
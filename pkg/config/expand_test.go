@@ -120,3 +120,16 @@ func TestExpandBgpCommunities(t *testing.T) {
 	}
 	t.Log(exp)
 }
+
+func TestExpandEnvReference(t *testing.T) {
+	exp := ExpandMap{
+		"FOO": "foo",
+	}
+	results, err := exp.Expand("{FOO}:${ASN}:1 = label")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0] != "foo:${ASN}:1 = label" {
+		t.Error("unexpected result:", results)
+	}
+}
@@ -1,5 +1,19 @@
 package decoders
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors of the strict decoders
+var (
+	ErrKeyMissing     = errors.New("key is missing")
+	ErrUnexpectedType = errors.New("unexpected type")
+)
+
 // MapGet retrieves a key from an expected map
 // it falls back if the input is not a map
 // or the key was not found.
@@ -19,13 +33,204 @@ func MapGet(m any, key string, fallback any) any {
 // asserts its type is a string. Otherwise fallback
 // will be returned.
 func MapGetString(m any, key string, fallback string) string {
-	val := MapGet(m, key, fallback)
-	return val.(string)
+	val, ok := MapGet(m, key, fallback).(string)
+	if !ok {
+		return fallback
+	}
+	return val
+}
+
+// MapGetStringCoerce retrieves a value for a given key
+// as string. Unlike MapGetString, numbers and booleans are
+// converted to their string representation instead of
+// falling back, e.g. a router ID sent as a number.
+// Floats are formatted without an exponent.
+// For other types, fallback will be returned.
+func MapGetStringCoerce(m any, key string, fallback string) string {
+	switch val := MapGet(m, key, fallback).(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case int, int64, bool:
+		return fmt.Sprint(val)
+	}
+	return fallback
 }
 
 // MapGetBool will retrieve a boolean value
-// for a given key.
+// for a given key. If the value is not a boolean,
+// fallback will be returned.
 func MapGetBool(m any, key string, fallback bool) bool {
-	val := MapGet(m, key, fallback)
-	return val.(bool)
+	val, ok := MapGet(m, key, fallback).(bool)
+	if !ok {
+		return fallback
+	}
+	return val
+}
+
+// MapGetInt retrieves an integer value for a given key.
+// JSON numbers are decoded as float64, numeric strings
+// are parsed. Otherwise fallback will be returned.
+func MapGetInt(m any, key string, fallback int) int {
+	switch val := MapGet(m, key, fallback).(type) {
+	case int:
+		return val
+	case float64:
+		return int(val)
+	case string:
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			return fallback
+		}
+		return i
+	}
+	return fallback
+}
+
+// mapRequire retrieves a key from an expected map.
+// Unlike MapGet, an error is returned if the input
+// is not a map or the key is missing.
+func mapRequire(m any, key string) (any, error) {
+	smap, ok := m.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not a map", ErrUnexpectedType, m)
+	}
+	val, ok := smap[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyMissing, key)
+	}
+	return val, nil
+}
+
+// MapRequireString retrieves a string for a given key.
+// Unlike MapGetString, an error is returned if the key
+// is missing or the value is not a string.
+func MapRequireString(m any, key string) (string, error) {
+	val, err := mapRequire(m, key)
+	if err != nil {
+		return "", err
+	}
+	sval, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s is %T, expected string",
+			ErrUnexpectedType, key, val)
+	}
+	return sval, nil
+}
+
+// MapRequireInt retrieves an integer for a given key.
+// The same values as in MapGetInt are accepted, however
+// an error is returned if the key is missing or the
+// value is not a number.
+func MapRequireInt(m any, key string) (int, error) {
+	val, err := mapRequire(m, key)
+	if err != nil {
+		return 0, err
+	}
+	switch v := val.(type) {
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	case string:
+		i, err := strconv.Atoi(v)
+		if err == nil {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s is %T, expected int",
+		ErrUnexpectedType, key, val)
+}
+
+// MapGetList retrieves a list for a given key.
+// If the value is not a list, fallback will be returned.
+func MapGetList(m any, key string, fallback []any) []any {
+	val, ok := MapGet(m, key, fallback).([]any)
+	if !ok {
+		return fallback
+	}
+	return val
+}
+
+// MapGetMap retrieves a nested map for a given key.
+// If the value is not a map, fallback will be returned.
+func MapGetMap(m any, key string, fallback map[string]any) map[string]any {
+	val, ok := MapGet(m, key, fallback).(map[string]any)
+	if !ok {
+		return fallback
+	}
+	return val
+}
+
+// MapGetSlice retrieves a list for a given key and
+// converts each element. Elements which can not be
+// converted are dropped. If the value is not a list,
+// an empty slice will be returned.
+func MapGetSlice[T any](m any, key string, conv func(any) (T, bool)) []T {
+	list := MapGetList(m, key, nil)
+	result := make([]T, 0, len(list))
+	for _, e := range list {
+		if v, ok := conv(e); ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// MapGetPath retrieves a value from nested maps and
+// lists by a dotted path, e.g. routes[0].bgp.as_path.
+// If any step of the path is missing or of an unexpected
+// type, fallback will be returned.
+func MapGetPath(m any, path string, fallback any) any {
+	val := m
+	for _, step := range strings.Split(path, ".") {
+		key, indices, _ := strings.Cut(step, "[")
+		if key != "" {
+			smap, ok := val.(map[string]any)
+			if !ok {
+				return fallback
+			}
+			val, ok = smap[key]
+			if !ok {
+				return fallback
+			}
+		}
+		if indices == "" {
+			continue
+		}
+		for _, index := range strings.Split(indices, "[") {
+			i, err := strconv.Atoi(strings.TrimSuffix(index, "]"))
+			if err != nil {
+				return fallback
+			}
+			list, ok := val.([]any)
+			if !ok || i < 0 || i >= len(list) {
+				return fallback
+			}
+			val = list[i]
+		}
+	}
+	return val
+}
+
+// MapGetTime retrieves a timestamp for a given key and
+// parses it using the layout. If the value is missing or
+// can not be parsed, fallback will be returned.
+func MapGetTime(m any, key string, layout string, fallback time.Time) time.Time {
+	val := MapGetString(m, key, "")
+	if val == "" {
+		return fallback
+	}
+	t, err := time.Parse(layout, val)
+	if err != nil {
+		return fallback
+	}
+	return t
+}
+
+// MapGetTimeRFC3339 retrieves an RFC3339 timestamp
+// for a given key. See MapGetTime.
+func MapGetTimeRFC3339(m any, key string, fallback time.Time) time.Time {
+	return MapGetTime(m, key, time.RFC3339, fallback)
 }
@@ -0,0 +1,277 @@
+package decoders
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMapGetInt(t *testing.T) {
+	m := map[string]any{
+		"float":   float64(23),
+		"int":     42,
+		"string":  "2342",
+		"invalid": "foo",
+		"bool":    true,
+	}
+
+	tests := []struct {
+		key    string
+		expect int
+	}{
+		{"float", 23},
+		{"int", 42},
+		{"string", 2342},
+		{"invalid", -1},
+		{"bool", -1},
+		{"missing", -1},
+	}
+	for _, tt := range tests {
+		if v := MapGetInt(m, tt.key, -1); v != tt.expect {
+			t.Error("unexpected value for", tt.key, ":", v)
+		}
+	}
+
+	if v := MapGetInt("not a map", "int", -1); v != -1 {
+		t.Error("expected fallback, got:", v)
+	}
+}
+
+func TestMapGetStringTypeMismatch(t *testing.T) {
+	m := map[string]any{
+		"name":   "rs1",
+		"number": float64(23),
+	}
+	if v := MapGetString(m, "name", "fallback"); v != "rs1" {
+		t.Error("unexpected value:", v)
+	}
+	if v := MapGetString(m, "number", "fallback"); v != "fallback" {
+		t.Error("expected fallback, got:", v)
+	}
+	if v := MapGetString(m, "missing", "fallback"); v != "fallback" {
+		t.Error("expected fallback, got:", v)
+	}
+}
+
+func TestMapGetStringCoerce(t *testing.T) {
+	m := map[string]any{
+		"name":      "rs1",
+		"router_id": float64(3232235777),
+		"weight":    float64(0.5),
+		"count":     42,
+		"enabled":   true,
+		"list":      []any{"a"},
+	}
+	tests := []struct {
+		key    string
+		expect string
+	}{
+		{"name", "rs1"},
+		{"router_id", "3232235777"},
+		{"weight", "0.5"},
+		{"count", "42"},
+		{"enabled", "true"},
+		{"list", "fallback"},
+		{"missing", "fallback"},
+	}
+	for _, tt := range tests {
+		if v := MapGetStringCoerce(m, tt.key, "fallback"); v != tt.expect {
+			t.Error("unexpected value for", tt.key, ":", v)
+		}
+	}
+
+	// The strict variant does not convert
+	if v := MapGetString(m, "router_id", "fallback"); v != "fallback" {
+		t.Error("expected fallback, got:", v)
+	}
+}
+
+func TestMapRequireString(t *testing.T) {
+	m := map[string]any{
+		"name":   "rs1",
+		"number": float64(23),
+	}
+	if v, err := MapRequireString(m, "name"); err != nil || v != "rs1" {
+		t.Error("unexpected value:", v, err)
+	}
+	if _, err := MapRequireString(m, "number"); !errors.Is(err, ErrUnexpectedType) {
+		t.Error("expected unexpected type error, got:", err)
+	}
+	if _, err := MapRequireString(m, "missing"); !errors.Is(err, ErrKeyMissing) {
+		t.Error("expected missing key error, got:", err)
+	}
+	if _, err := MapRequireString(nil, "name"); !errors.Is(err, ErrUnexpectedType) {
+		t.Error("expected error for nil map, got:", err)
+	}
+}
+
+func TestMapRequireInt(t *testing.T) {
+	m := map[string]any{
+		"float":   float64(23),
+		"int":     42,
+		"string":  "2342",
+		"invalid": "foo",
+		"bool":    true,
+	}
+	for key, expect := range map[string]int{
+		"float": 23, "int": 42, "string": 2342,
+	} {
+		if v, err := MapRequireInt(m, key); err != nil || v != expect {
+			t.Error("unexpected value for", key, ":", v, err)
+		}
+	}
+	for _, key := range []string{"invalid", "bool"} {
+		if _, err := MapRequireInt(m, key); !errors.Is(err, ErrUnexpectedType) {
+			t.Error("expected unexpected type error for", key, "got:", err)
+		}
+	}
+	if _, err := MapRequireInt(m, "missing"); !errors.Is(err, ErrKeyMissing) {
+		t.Error("expected missing key error, got:", err)
+	}
+}
+
+func TestMapGetBoolTypeMismatch(t *testing.T) {
+	m := map[string]any{
+		"enabled": true,
+		"number":  float64(1),
+	}
+	if v := MapGetBool(m, "enabled", false); v != true {
+		t.Error("unexpected value:", v)
+	}
+	if v := MapGetBool(m, "number", false); v != false {
+		t.Error("expected fallback, got:", v)
+	}
+	if v := MapGetBool(m, "missing", true); v != true {
+		t.Error("expected fallback, got:", v)
+	}
+}
+
+func TestMapGetList(t *testing.T) {
+	m := map[string]any{
+		"as_path": []any{float64(2342), float64(23)},
+		"name":    "rs1",
+	}
+	if v := MapGetList(m, "as_path", nil); len(v) != 2 {
+		t.Error("unexpected value:", v)
+	}
+	if v := MapGetList(m, "name", nil); v != nil {
+		t.Error("expected fallback, got:", v)
+	}
+	if v := MapGetList(m, "missing", []any{}); v == nil || len(v) != 0 {
+		t.Error("expected fallback, got:", v)
+	}
+	if v := MapGetList(nil, "as_path", nil); v != nil {
+		t.Error("expected fallback, got:", v)
+	}
+}
+
+func TestMapGetMap(t *testing.T) {
+	m := map[string]any{
+		"bgp":  map[string]any{"med": float64(0)},
+		"name": "rs1",
+	}
+	if v := MapGetMap(m, "bgp", nil); v["med"] != float64(0) {
+		t.Error("unexpected value:", v)
+	}
+	if v := MapGetMap(m, "name", nil); v != nil {
+		t.Error("expected fallback, got:", v)
+	}
+	if v := MapGetMap(m, "missing", map[string]any{}); v == nil {
+		t.Error("expected fallback, got:", v)
+	}
+}
+
+func TestMapGetPath(t *testing.T) {
+	m := map[string]any{
+		"result": map[string]any{
+			"routes": []any{
+				map[string]any{
+					"network": "10.0.0.0/8",
+					"bgp": map[string]any{
+						"as_path": []any{float64(2342), float64(23)},
+					},
+				},
+			},
+			"matrix": []any{
+				[]any{"a", "b"},
+			},
+		},
+	}
+
+	tests := []struct {
+		path   string
+		expect any
+	}{
+		{"result.routes[0].network", "10.0.0.0/8"},
+		{"result.routes[0].bgp.as_path[1]", float64(23)},
+		{"result.matrix[0][1]", "b"},
+		{"result.routes[1].network", "fallback"},
+		{"result.routes[-1].network", "fallback"},
+		{"result.routes[x].network", "fallback"},
+		{"result.routes[0].network.foo", "fallback"},
+		{"result.routes.network", "fallback"},
+		{"result.missing", "fallback"},
+	}
+	for _, tt := range tests {
+		if v := MapGetPath(m, tt.path, "fallback"); v != tt.expect {
+			t.Error("unexpected value for", tt.path, ":", v)
+		}
+	}
+}
+
+func TestMapGetTime(t *testing.T) {
+	m := map[string]any{
+		"last_reboot": "2023-04-05T10:42:23+02:00",
+		"garbage":     "yesterday",
+	}
+	fallback := time.Unix(0, 0)
+
+	v := MapGetTimeRFC3339(m, "last_reboot", fallback)
+	expect := time.Date(2023, 4, 5, 8, 42, 23, 0, time.UTC)
+	if !v.Equal(expect) {
+		t.Error("unexpected time:", v)
+	}
+
+	v = MapGetTime(m, "garbage", time.RFC3339, fallback)
+	if !v.Equal(fallback) {
+		t.Error("expected fallback, got:", v)
+	}
+
+	v = MapGetTimeRFC3339(m, "missing", fallback)
+	if !v.Equal(fallback) {
+		t.Error("expected fallback, got:", v)
+	}
+
+	v = MapGetTime(map[string]any{"date": "05.04.2023"}, "date",
+		"02.01.2006", fallback)
+	if v.Day() != 5 || v.Month() != 4 {
+		t.Error("unexpected time:", v)
+	}
+}
+
+func TestMapGetSlice(t *testing.T) {
+	m := map[string]any{
+		"as_path":  []any{float64(31078), "201785", "foo"},
+		"networks": []any{"10.0.0.0/8", 23, "fd00::/8"},
+		"garbage":  "foo",
+	}
+
+	asPath := MapGetSlice(m, "as_path", intFromInterface)
+	if len(asPath) != 2 || asPath[0] != 31078 || asPath[1] != 201785 {
+		t.Error("unexpected as path:", asPath)
+	}
+
+	networks := MapGetSlice(m, "networks", func(v any) (string, bool) {
+		s, ok := v.(string)
+		return s, ok
+	})
+	if len(networks) != 2 ||
+		networks[0] != "10.0.0.0/8" || networks[1] != "fd00::/8" {
+		t.Error("unexpected networks:", networks)
+	}
+
+	garbage := MapGetSlice(m, "garbage", intFromInterface)
+	if garbage == nil || len(garbage) != 0 {
+		t.Error("expected empty slice, got:", garbage)
+	}
+}
@@ -5,6 +5,7 @@ package decoders
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -62,6 +63,57 @@ func IntList(data any) []int {
 	return list
 }
 
+// intFromInterface decodes an integer from a JSON
+// number, an int or a numeric string.
+func intFromInterface(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false
+		}
+		return int(v), true
+	case string:
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, false
+		}
+		return i, true
+	}
+	return 0, false
+}
+
+// IntListFromInterfaces decodes a list of mixed numeric
+// types, e.g. an AS path of float64 or strings, into a
+// list of integers. Elements which can not be decoded
+// are skipped.
+func IntListFromInterfaces(xs []any) []int {
+	list := make([]int, 0, len(xs))
+	for _, x := range xs {
+		if v, ok := intFromInterface(x); ok {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// IntListFromInterfacesStrict decodes a list like
+// IntListFromInterfaces, but fails with an error if
+// an element can not be decoded.
+func IntListFromInterfacesStrict(xs []any) ([]int, error) {
+	list := make([]int, 0, len(xs))
+	for i, x := range xs {
+		v, ok := intFromInterface(x)
+		if !ok {
+			return nil, fmt.Errorf("%w: element %d is %T, expected int",
+				ErrUnexpectedType, i, x)
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
 // IntListFromStrings decodes a list of strings
 // into a list of integers.
 func IntListFromStrings(strs []string) []int {
@@ -1,6 +1,7 @@
 package decoders
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -34,3 +35,45 @@ func TestTrimmedCSVStringList(t *testing.T) {
 		t.Error("Expected list of [foo, bar, dreiundzwanzig], got:", l)
 	}
 }
+
+func TestIntList(t *testing.T) {
+	l := IntList([]any{"31078", "201785", "foo", float64(23)})
+	if len(l) != 2 {
+		t.Fatal("Expected length to be 2, got:", len(l))
+	}
+	if l[0] != 31078 || l[1] != 201785 {
+		t.Error("Expected list of [31078, 201785], got:", l)
+	}
+}
+
+func TestIntListFromInterfaces(t *testing.T) {
+	l := IntListFromInterfaces([]any{
+		float64(31078), "201785", 23, "foo", 1.5, nil, true,
+	})
+	if len(l) != 3 {
+		t.Fatal("Expected length to be 3, got:", len(l))
+	}
+	if l[0] != 31078 || l[1] != 201785 || l[2] != 23 {
+		t.Error("Expected list of [31078, 201785, 23], got:", l)
+	}
+
+	l = IntListFromInterfaces([]any{})
+	if l == nil || len(l) != 0 {
+		t.Error("Expected an empty list, got:", l)
+	}
+}
+
+func TestIntListFromInterfacesStrict(t *testing.T) {
+	l, err := IntListFromInterfacesStrict([]any{float64(23), "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 2 || l[0] != 23 || l[1] != 42 {
+		t.Error("Expected list of [23, 42], got:", l)
+	}
+
+	_, err = IntListFromInterfacesStrict([]any{float64(23), "foo"})
+	if !errors.Is(err, ErrUnexpectedType) {
+		t.Error("Expected ErrUnexpectedType, got:", err)
+	}
+}
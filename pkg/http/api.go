@@ -4,6 +4,7 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"io"
 	"log"
 	"net/http"
 	"strings"
@@ -61,6 +62,16 @@ func endpoint(wrapped apiEndpoint) httprouter.Handle {
 			return
 		}
 
+		// Stream large responses without buffering
+		if stream, ok := result.(jsonWriter); ok {
+			w := responseWriter(res, req)
+			defer w.Close()
+			if err := stream.WriteJSON(w); err != nil {
+				log.Println("Could not stream result as json:", err)
+			}
+			return
+		}
+
 		// Encode json
 		payload, err := json.Marshal(result)
 		if err != nil {
@@ -72,20 +83,41 @@ func endpoint(wrapped apiEndpoint) httprouter.Handle {
 			return
 		}
 
-		// Set response header
-		res.Header().Set("Content-Type", "application/json")
-
-		// Check if compression is supported
-		if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
-			// Compress response
-			res.Header().Set("Content-Encoding", "gzip")
-			gz := gzip.NewWriter(res)
-			defer gz.Close()
-			gz.Write(payload)
-		} else {
-			res.Write(payload) // Fall back to uncompressed response
-		}
+		w := responseWriter(res, req)
+		defer w.Close()
+		w.Write(payload)
+	}
+}
+
+// A jsonWriter is a response encoding itself as JSON,
+// e.g. to stream large results without buffering.
+type jsonWriter interface {
+	WriteJSON(w io.Writer) error
+}
+
+// nopWriteCloser adds a no-op Close to a writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// responseWriter sets the response header and returns
+// a gzip writer, if compression is supported by the
+// client. Otherwise the response is uncompressed.
+func responseWriter(
+	res http.ResponseWriter,
+	req *http.Request,
+) io.WriteCloser {
+	res.Header().Set("Content-Type", "application/json")
+	if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+		res.Header().Set("Content-Encoding", "gzip")
+		return gzip.NewWriter(res)
 	}
+	return nopWriteCloser{res}
 }
 
 // Register api endpoints
@@ -20,14 +20,16 @@ func (s *Server) apiStatusShow(
 	return status, err
 }
 
-// Handle Config Endpoint
+// Handle Config Endpoint. The BGP communities include
+// the ranges of the blackhole communities expanded into
+// labeled communities, see UIConfig.BGPCommunitiesExpanded.
 func (s *Server) apiConfigShow(
 	_ctx context.Context,
 	_req *http.Request,
 	_params httprouter.Params,
 ) (response, error) {
 	result := api.ConfigResponse{
-		BGPCommunities:          s.cfg.UI.BGPCommunities,
+		BGPCommunities:          s.cfg.UI.BGPCommunitiesExpanded,
 		BGPBlackholeCommunities: s.cfg.UI.BGPBlackholeCommunities,
 		RejectReasons:           s.cfg.UI.RoutesRejections.Reasons,
 		Noexport: api.Noexport{
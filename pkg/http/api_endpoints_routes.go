@@ -65,26 +65,23 @@ func (s *Server) apiRoutesListReceived(
 
 	// Filter routes based on criteria if present
 	allRoutes := apiQueryFilterNextHopGateway(req, "q", result.Imported)
-	routes := api.Routes{}
 
 	// Apply other (community) filters
-	filtersApplied, err := api.FiltersFromQuery(req.URL.Query())
+	filtersApplied, filterWarnings, err := apiQueryFilters(
+		req, s.filterContext)
 	if err != nil {
 		return nil, err
 	}
 
-	var hasIP4, hasIP6 bool
-	filtersAvailable := api.NewSearchFilters()
-	for _, r := range allRoutes {
-		if !filtersApplied.MatchRoute(r) {
-			continue // Exclude route from results set
-		}
-		routes = append(routes, r)
-		filtersAvailable.UpdateFromRoute(r)
-		hasIP4 = hasIP4 || r.AddrFamily == api.AddrFamilyIPv4
-		hasIP6 = hasIP6 || r.AddrFamily == api.AddrFamilyIPv6
+	routes, err := api.MatchRoutesCtx(ctx, filtersApplied, allRoutes)
+	if err != nil {
+		return nil, err
 	}
-	filtersAvailable.SetFilterAddrFamilies(hasIP4, hasIP6)
+
+	// Count the filters over all matching routes,
+	// before the page is selected.
+	filtersAvailable := api.NewSearchFilters()
+	filtersAvailable.UpdateFromRoutes(routes)
 
 	// Remove applied filters from available
 	filtersApplied.MergeProperties(filtersAvailable)
@@ -112,6 +109,7 @@ func (s *Server) apiRoutesListReceived(
 		FilteredResponse: api.FilteredResponse{
 			FiltersAvailable: filtersAvailable,
 			FiltersApplied:   filtersApplied,
+			FilterWarnings:   filterWarnings,
 		},
 		PaginatedResponse: api.PaginatedResponse{
 			Pagination: pagination,
@@ -147,27 +145,23 @@ func (s *Server) apiRoutesListFiltered(
 
 	// Filter routes based on criteria if present
 	allRoutes := apiQueryFilterNextHopGateway(req, "q", result.Filtered)
-	routes := api.Routes{}
 
 	// Apply other (community) filters
-	filtersApplied, err := api.FiltersFromQuery(req.URL.Query())
+	filtersApplied, filterWarnings, err := apiQueryFilters(
+		req, s.filterContext)
 	if err != nil {
 		return nil, err
 	}
 
-	filtersAvailable := api.NewSearchFilters()
-	var hasIP4, hasIP6 bool
-	for _, r := range allRoutes {
-		if !filtersApplied.MatchRoute(r) {
-			continue // Exclude route from results set
-		}
-		routes = append(routes, r)
-
-		filtersAvailable.UpdateFromRoute(r)
-		hasIP4 = hasIP4 || r.AddrFamily == api.AddrFamilyIPv4
-		hasIP6 = hasIP6 || r.AddrFamily == api.AddrFamilyIPv6
+	routes, err := api.MatchRoutesCtx(ctx, filtersApplied, allRoutes)
+	if err != nil {
+		return nil, err
 	}
-	filtersAvailable.SetFilterAddrFamilies(hasIP4, hasIP6)
+
+	// Count the filters over all matching routes,
+	// before the page is selected.
+	filtersAvailable := api.NewSearchFilters()
+	filtersAvailable.UpdateFromRoutes(routes)
 
 	// Remove applied filters from available
 	filtersApplied.MergeProperties(filtersAvailable)
@@ -195,6 +189,7 @@ func (s *Server) apiRoutesListFiltered(
 		FilteredResponse: api.FilteredResponse{
 			FiltersAvailable: filtersAvailable,
 			FiltersApplied:   filtersApplied,
+			FilterWarnings:   filterWarnings,
 		},
 		PaginatedResponse: api.PaginatedResponse{
 			Pagination: pagination,
@@ -230,26 +225,23 @@ func (s *Server) apiRoutesListNotExported(
 
 	// Filter routes based on criteria if present
 	allRoutes := apiQueryFilterNextHopGateway(req, "q", result.NotExported)
-	routes := api.Routes{}
 
 	// Apply other (community) filters
-	filtersApplied, err := api.FiltersFromQuery(req.URL.Query())
+	filtersApplied, filterWarnings, err := apiQueryFilters(
+		req, s.filterContext)
 	if err != nil {
 		return nil, err
 	}
 
-	filtersAvailable := api.NewSearchFilters()
-	var hasIP4, hasIP6 bool
-	for _, r := range allRoutes {
-		if !filtersApplied.MatchRoute(r) {
-			continue // Exclude route from results set
-		}
-		routes = append(routes, r)
-		filtersAvailable.UpdateFromRoute(r)
-		hasIP4 = hasIP4 || r.AddrFamily == api.AddrFamilyIPv4
-		hasIP6 = hasIP6 || r.AddrFamily == api.AddrFamilyIPv6
+	routes, err := api.MatchRoutesCtx(ctx, filtersApplied, allRoutes)
+	if err != nil {
+		return nil, err
 	}
-	filtersAvailable.SetFilterAddrFamilies(hasIP4, hasIP6)
+
+	// Count the filters over all matching routes,
+	// before the page is selected.
+	filtersAvailable := api.NewSearchFilters()
+	filtersAvailable.UpdateFromRoutes(routes)
 
 	// Remove applied filters from available
 	filtersApplied.MergeProperties(filtersAvailable)
@@ -277,6 +269,7 @@ func (s *Server) apiRoutesListNotExported(
 		FilteredResponse: api.FilteredResponse{
 			FiltersAvailable: filtersAvailable,
 			FiltersApplied:   filtersApplied,
+			FilterWarnings:   filterWarnings,
 		},
 		PaginatedResponse: api.PaginatedResponse{
 			Pagination: pagination,
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"sort"
 	"time"
@@ -9,7 +10,6 @@ import (
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/alice-lg/alice-lg/pkg/api"
-	"github.com/alice-lg/alice-lg/pkg/decoders"
 )
 
 // Handle global lookup
@@ -30,19 +30,21 @@ func (s *Server) apiLookupPrefixGlobal(
 		return nil, err
 	}
 
-	q, filterTokens := QueryString(q).ExtractFilters()
+	prefix, tokens := QueryString(q).ExtractPrefix()
 
-	// Get filters from query string
-	queryFilters, err := api.FiltersFromTokens(filterTokens)
+	// Get filters and the neighbor name from query string
+	queryTokens, err := s.filterContext.ParseFilterTokens(tokens)
 	if err != nil {
 		return nil, &ErrValidationFailed{
 			Param:  "q",
 			Reason: err.Error(),
 		}
 	}
+	queryFilters := queryTokens.Filters
 
 	// Get additional filter criteria
-	filtersApplied, err := api.FiltersFromQuery(req.URL.Query())
+	filtersApplied, filterWarnings, err := apiQueryFilters(
+		req, s.filterContext)
 	if err != nil {
 		return nil, err
 	}
@@ -54,11 +56,14 @@ func (s *Server) apiLookupPrefixGlobal(
 	//  Prefix -> fetch prefix
 	//       _ -> fetch neighbors and routes
 	//
-	lookupPrefix := decoders.MaybePrefix(q)
+	lookupPrefix := prefix != ""
 	lookupEmptyQuery := false
-	if q == "" && (filtersApplied.HasGroup(api.SearchKeyCommunities) ||
-		filtersApplied.HasGroup(api.SearchKeyExtCommunities) ||
-		filtersApplied.HasGroup(api.SearchKeyLargeCommunities)) {
+	if !lookupPrefix &&
+		queryTokens.NeighborName == "" &&
+		!queryFilters.HasGroup(api.SearchKeyASNS) &&
+		(filtersApplied.HasGroup(api.SearchKeyCommunities) ||
+			filtersApplied.HasGroup(api.SearchKeyExtCommunities) ||
+			filtersApplied.HasGroup(api.SearchKeyLargeCommunities)) {
 		lookupPrefix = true
 		lookupEmptyQuery = true
 	}
@@ -70,23 +75,19 @@ func (s *Server) apiLookupPrefixGlobal(
 	var routes api.LookupRoutes
 	if lookupPrefix {
 		if !lookupEmptyQuery {
-			q, err = validatePrefixQuery(q)
+			prefix, err = validatePrefixQuery(prefix)
 			if err != nil {
 				return nil, err
 			}
 		}
-		routes, err = s.routesStore.LookupPrefix(ctx, q, filtersApplied)
+		routes, err = s.routesStore.LookupPrefix(ctx, prefix, filtersApplied)
 		if err != nil {
 			return nil, err
 		}
 
 	} else {
 		// Query by neighbors
-		q, err = validateNeighborsQuery(q)
-		if err != nil {
-			return nil, err
-		}
-		neighbors, err := s.neighborsStore.LookupNeighbors(ctx, q)
+		neighbors, err := s.lookupQueryNeighbors(ctx, queryTokens)
 		if err != nil {
 			return nil, err
 		}
@@ -144,6 +145,7 @@ func (s *Server) apiLookupPrefixGlobal(
 		hasIP6 = hasIP6 || r.AddrFamily == api.AddrFamilyIPv6
 	}
 	filtersAvailable.SetFilterAddrFamilies(hasIP4, hasIP6)
+	filtersAvailable.ResolveASNNames(s.filterContext.ASNNamer)
 
 	// Remove applied filters from available
 	filtersApplied.MergeProperties(filtersAvailable)
@@ -199,10 +201,42 @@ func (s *Server) apiLookupPrefixGlobal(
 			FiltersAvailable:    filtersAvailable,
 			FiltersNotAvailable: filtersNotAvailable,
 			FiltersApplied:      filtersApplied,
+			FilterWarnings:      filterWarnings,
 		},
+		IgnoredTokens: queryTokens.Ignored,
 	}
 
-	return response, nil
+	return &response, nil
+}
+
+// lookupQueryNeighbors finds the neighbors for a lookup
+// by the neighbor name from the query. Without a name,
+// the neighbors are looked up by the ASNs of the query.
+func (s *Server) lookupQueryNeighbors(
+	ctx context.Context,
+	query *api.TokenFilters,
+) (api.NeighborsLookupResults, error) {
+	asns := query.Filters.GetGroupByKey(api.SearchKeyASNS)
+	if query.NeighborName != "" || len(asns.Filters) == 0 {
+		name, err := validateNeighborsQuery(query.NeighborName)
+		if err != nil {
+			return nil, err
+		}
+		return s.neighborsStore.LookupNeighbors(ctx, name)
+	}
+
+	results := make(api.NeighborsLookupResults)
+	for _, filter := range asns.Filters {
+		neighbors, err := s.neighborsStore.LookupNeighbors(
+			ctx, fmt.Sprintf("AS%d", filter.Value))
+		if err != nil {
+			return nil, err
+		}
+		for sourceID, n := range neighbors {
+			results[sourceID] = append(results[sourceID], n...)
+		}
+	}
+	return results, nil
 }
 
 func (s *Server) apiLookupNeighborsGlobal(
@@ -1,11 +1,13 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/alice-lg/alice-lg/pkg/api"
+	"github.com/alice-lg/alice-lg/pkg/decoders"
 )
 
 /*
@@ -31,6 +33,34 @@ func apiQueryMustInt(req *http.Request, param string, defaultValue int) int {
 	return value
 }
 
+/*
+Get the search filters from the query string.
+Unknown filters and invalid values are rejected,
+the configured query defaults are applied.
+Empty filters are ignored and reported as warnings.
+*/
+func apiQueryFilters(
+	req *http.Request,
+	filterContext *api.FilterContext,
+) (*api.SearchFilters, []string, error) {
+	filters, warnings, err := filterContext.NormalizeQuery(req.URL.Query())
+	var errUnknown *api.ErrUnknownFilterKey
+	if errors.As(err, &errUnknown) {
+		return nil, nil, &ErrValidationFailed{
+			Param:  errUnknown.Key,
+			Reason: err.Error(),
+		}
+	}
+	var errParse *api.FilterParseError
+	if errors.As(err, &errParse) {
+		return nil, nil, &ErrValidationFailed{
+			Param:  errParse.Key,
+			Reason: err.Error(),
+		}
+	}
+	return filters, warnings, err
+}
+
 /*
 Filter response to match query criteria
 */
@@ -62,19 +92,20 @@ func apiQueryFilterNextHopGateway(
 // Extract the value and additional filters from the string
 type QueryString string
 
-// ExtractFilters separates query and filters from string.
-func (q QueryString) ExtractFilters() (string, []string) {
-	tokens := strings.Split(string(q), " ")
-	query := []string{}
-	filters := []string{}
-
-	for _, t := range tokens {
-		if strings.HasPrefix(t, "#") {
-			filters = append(filters, t)
-		} else {
-			query = append(query, t)
+// ExtractPrefix separates the prefix to look up from
+// the other tokens of the query. Only the first token
+// looking like a prefix is used. The remaining tokens
+// are filters, see api.ParseFilterTokens.
+func (q QueryString) ExtractPrefix() (string, []string) {
+	prefix := ""
+	tokens := []string{}
+	for _, t := range strings.Fields(string(q)) {
+		isFilter := strings.HasPrefix(t, "#") || strings.HasPrefix(t, "@")
+		if prefix == "" && !isFilter && decoders.MaybePrefix(t) {
+			prefix = t
+			continue
 		}
+		tokens = append(tokens, t)
 	}
-
-	return strings.Join(query, " "), filters
+	return prefix, tokens
 }
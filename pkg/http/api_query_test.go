@@ -3,6 +3,7 @@ package http
 import (
 	"net/http"
 	"net/url"
+	"slices"
 	"testing"
 
 	"github.com/alice-lg/alice-lg/pkg/api"
@@ -73,3 +74,72 @@ func TestApiQueryFilterNextHopGateway(t *testing.T) {
 		t.Error("Expected 142.23.0.0/16 to match criteria, got:", filtered[0])
 	}
 }
+
+func TestApiQueryFilters(t *testing.T) {
+	req := makeQueryRequest("foo&page=2&asns=2342")
+	filters, _, err := apiQueryFilters(req, &api.FilterContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filters.HasGroup(api.SearchKeyASNS) {
+		t.Error("expected asns filter")
+	}
+
+	req = makeQueryRequest("foo&comunities=23:42")
+	_, _, err = apiQueryFilters(req, &api.FilterContext{})
+	errValidation, ok := err.(*ErrValidationFailed)
+	if !ok {
+		t.Fatal("expected validation error, got:", err)
+	}
+	if errValidation.Param != "comunities" {
+		t.Error("unexpected param:", errValidation.Param)
+	}
+
+	req = makeQueryRequest("foo&asns=23,foo")
+	_, _, err = apiQueryFilters(req, &api.FilterContext{})
+	errValidation, ok = err.(*ErrValidationFailed)
+	if !ok {
+		t.Fatal("expected validation error, got:", err)
+	}
+	if errValidation.Param != api.SearchKeyASNS {
+		t.Error("unexpected param:", errValidation.Param)
+	}
+
+	req = makeQueryRequest("foo&asns=23,0")
+	_, _, err = apiQueryFilters(req, &api.FilterContext{})
+	errValidation, ok = err.(*ErrValidationFailed)
+	if !ok {
+		t.Fatal("expected validation error, got:", err)
+	}
+	if errValidation.Param != api.SearchKeyASNS {
+		t.Error("unexpected param:", errValidation.Param)
+	}
+}
+
+func TestQueryStringExtractPrefix(t *testing.T) {
+	prefix, tokens := QueryString(
+		"#23:42 AS2342 10.23.42.0/24 cloud @rs1 ???").ExtractPrefix()
+	if prefix != "10.23.42.0/24" {
+		t.Error("unexpected prefix:", prefix)
+	}
+	expected := []string{"#23:42", "AS2342", "cloud", "@rs1", "???"}
+	if !slices.Equal(tokens, expected) {
+		t.Error("unexpected tokens:", tokens)
+	}
+
+	query, err := (&api.FilterContext{}).ParseFilterTokens(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.NeighborName != "cloud" {
+		t.Error("unexpected neighbor name:", query.NeighborName)
+	}
+	if !slices.Equal(query.Ignored, []string{"???"}) {
+		t.Error("unexpected ignored tokens:", query.Ignored)
+	}
+
+	prefix, tokens = QueryString("  cloud  foo ").ExtractPrefix()
+	if prefix != "" || !slices.Equal(tokens, []string{"cloud", "foo"}) {
+		t.Error("unexpected prefix and tokens:", prefix, tokens)
+	}
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/alice-lg/alice-lg/pkg/api"
+)
+
+func TestEndpointStreamJSON(t *testing.T) {
+	handler := endpoint(func(
+		context.Context,
+		*http.Request,
+		httprouter.Params,
+	) (response, error) {
+		return &api.PaginatedRoutesLookupResponse{
+			Imported:      &api.RoutesLookup{Routes: api.LookupRoutes{}},
+			IgnoredTokens: []string{"foo"},
+		}, nil
+	})
+
+	for _, compressed := range []bool{false, true} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/lookup/prefix", nil)
+		if compressed {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req, nil)
+
+		var body io.Reader = rec.Body
+		if compressed {
+			gz, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = gz
+		}
+		res := api.PaginatedRoutesLookupResponse{}
+		if err := json.NewDecoder(body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Imported == nil || len(res.IgnoredTokens) != 1 {
+			t.Error("unexpected response:", res)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Error("unexpected content type:", ct)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/alice-lg/alice-lg/pkg/api"
 	"github.com/alice-lg/alice-lg/pkg/config"
 	"github.com/alice-lg/alice-lg/pkg/store"
 )
@@ -23,6 +24,7 @@ type Server struct {
 	routesStore    *store.RoutesStore
 	neighborsStore *store.NeighborsStore
 	pool           *pgxpool.Pool
+	filterContext  *api.FilterContext
 }
 
 // NewServer creates a new server
@@ -37,9 +39,41 @@ func NewServer(
 		routesStore:    routesStore,
 		neighborsStore: neighborsStore,
 		pool:           pool,
+		filterContext:  newFilterContext(cfg),
 	}
 }
 
+// SetASNNamer sets the namer used for labeling ASN
+// filters without a name in the search results, e.g.
+// from a PeeringDB dump. There is no namer by default
+// and a nil namer disables the lookup.
+// This must be called before Start.
+func (s *Server) SetASNNamer(namer api.ASNNamer) {
+	s.filterContext.ASNNamer = namer
+}
+
+// newFilterContext creates the context for parsing
+// search filters and matching routes from the config.
+// The ASNNamer is not configured, see SetASNNamer.
+func newFilterContext(cfg *config.Config) *api.FilterContext {
+	fc := &api.FilterContext{
+		CommunityAliases:           cfg.UI.BGPCommunityAliases,
+		QueryDefaults:              cfg.Server.QueryDefaultFilters,
+		CommunityLabels:            cfg.UI.BGPCommunities,
+		BlackholeCommunities:       cfg.UI.BGPBlackholeCommunities,
+		ASPathLengthIgnorePrepends: cfg.Server.ASPathLengthIgnorePrepends,
+	}
+	if cfg.UI.Rpki.Enabled {
+		fc.RpkiCommunities = api.RpkiCommunities{
+			Valid:      cfg.UI.Rpki.Valid,
+			Unknown:    cfg.UI.Rpki.Unknown,
+			NotChecked: cfg.UI.Rpki.NotChecked,
+			Invalid:    cfg.UI.Rpki.Invalid,
+		}
+	}
+	return fc
+}
+
 // Start starts a HTTP server and begins to listen
 // on the configured port.
 func (s *Server) Start(ctx context.Context) {
@@ -3,64 +3,549 @@ package birdwatcher
 // Http Birdwatcher Client
 
 import (
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"math/rand/v2"
+	"mime"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/alice-lg/alice-lg/pkg/decoders"
 )
 
 // ClientResponse is a json key value mapping
 type ClientResponse map[string]any
 
+// Errors
+var (
+	ErrInvalidTable = errors.New(
+		"invalid table name, expected letters, digits and underscores")
+	ErrNoTable      = errors.New("endpoint requires a table, but none is set")
+	ErrPingNoStatus = errors.New(
+		"unexpected response from API: status is missing")
+)
+
+// TablePlaceholder is replaced with the table of the
+// client in an endpoint, e.g. /routes/table/{table}.
+const TablePlaceholder = "{table}"
+
+// tableNamePattern is the allowlist for table names:
+// bird symbols are made of letters, digits and underscores.
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateTableName checks if the table name is safe
+// to be used in an endpoint path.
+func ValidateTableName(table string) error {
+	if !tableNamePattern.MatchString(table) {
+		return ErrInvalidTable
+	}
+	return nil
+}
+
+// DefaultRetryStatusCodes are the http status codes
+// of responses for which a request is retried.
+var DefaultRetryStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// defaultHTTPClient is shared by all clients, so
+// connections to the APIs are reused.
+var defaultHTTPClient = newDefaultHTTPClient()
+
+func newDefaultHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 10
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
+// ClientOptions configure the behavior of the client
+type ClientOptions struct {
+	// HTTPClient is used for making the requests. This
+	// can be used to configure e.g. TLS or proxies.
+	// If not set, a shared client is used.
+	HTTPClient *http.Client
+
+	// Timeout of a request, including reading the
+	// response body. The timeout is only applied if
+	// the context of the request has no deadline.
+	Timeout time.Duration
+
+	// Credentials for APIs behind an authenticating
+	// proxy. The bearer token takes precedence over
+	// basic auth if both are set.
+	BasicAuthUser string
+	BasicAuthPass string
+	BearerToken   string
+
+	// Headers are added to each request, e.g. an X-Api-Key.
+	Headers map[string]string
+
+	// Host overrides the host of the requests. Go ignores
+	// a Host in the headers, so this must be used instead.
+	Host string
+
+	// MaxResponseBytes limits the size of a response body.
+	// Reading a larger body fails with an
+	// *http.MaxBytesError. No limit is applied if zero.
+	MaxResponseBytes int64
+
+	// ObserveRequest is called after each attempt of a
+	// request, e.g. for collecting metrics. The status is
+	// 0 if no response was received.
+	ObserveRequest func(
+		endpoint string,
+		status int,
+		dur time.Duration,
+		err error,
+	)
+
+	// MaxRetries is the number of retries of a failed
+	// request. No request is retried by default.
+	MaxRetries int
+
+	// BaseDelay is the delay before the first retry.
+	// The delay is doubled for each retry and a random
+	// jitter is added.
+	BaseDelay time.Duration
+
+	// RetryStatusCodes are the status codes of responses
+	// which are retried. Other responses, e.g. a 404, are
+	// returned immediately. If not set, the
+	// DefaultRetryStatusCodes are used.
+	RetryStatusCodes []int
+
+	// CacheTTL enables caching of JSON responses for the
+	// duration. Concurrent requests of the same endpoint
+	// are collapsed into a single request. The cache is
+	// disabled if zero.
+	CacheTTL time.Duration
+
+	// Table replaces the TablePlaceholder in endpoints,
+	// for setups with multiple routing tables. Endpoints
+	// without a placeholder are not affected.
+	Table string
+}
+
 // A Client uses the http client to talk
 // to the birdwatcher API.
 type Client struct {
-	api string
+	api   string
+	opts  ClientOptions
+	cache *responseCache
 }
 
 // NewClient creates a new client instance
 func NewClient(api string) *Client {
+	return NewClientWithOptions(api, ClientOptions{})
+}
+
+// NewClientWithHTTPClient creates a new client instance
+// making requests with the http client.
+func NewClientWithHTTPClient(api string, httpClient *http.Client) *Client {
+	return NewClientWithOptions(api, ClientOptions{
+		HTTPClient: httpClient,
+	})
+}
+
+// NewClientWithOptions creates a new client instance
+// configured with options.
+func NewClientWithOptions(api string, opts ClientOptions) *Client {
 	// Strip trailing slashes from api base
 	api = strings.TrimSuffix(api, "/")
 
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = defaultHTTPClient
+	}
+	// Copy the headers, so changes by the caller
+	// do not race with requests.
+	opts.Headers = maps.Clone(opts.Headers)
+	if opts.RetryStatusCodes == nil {
+		opts.RetryStatusCodes = DefaultRetryStatusCodes
+	}
+
 	client := &Client{
-		api: api,
+		api:  api,
+		opts: opts,
+	}
+	if opts.CacheTTL > 0 {
+		client.cache = newResponseCache(opts.CacheTTL)
 	}
 	return client
 }
 
+// retryDelay calculates the exponential backoff
+// with jitter before the retry.
+func (c *Client) retryDelay(retry int) time.Duration {
+	delay := c.opts.BaseDelay << retry
+	if delay <= 0 {
+		return 0
+	}
+	return delay + rand.N(delay/2+1)
+}
+
+// shouldRetry checks if the request should
+// be attempted again.
+func (c *Client) shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return slices.Contains(c.opts.RetryStatusCodes, res.StatusCode)
+}
+
+// tableEndpoint injects the table of the client into
+// the endpoint. The table name is validated, so it can
+// not change the path of the endpoint.
+func (c *Client) tableEndpoint(endpoint string) (string, error) {
+	if !strings.Contains(endpoint, TablePlaceholder) {
+		return endpoint, nil
+	}
+	if c.opts.Table == "" {
+		return "", ErrNoTable
+	}
+	if err := ValidateTableName(c.opts.Table); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(endpoint, TablePlaceholder, c.opts.Table), nil
+}
+
 // GetEndpoint makes an API request and returns the
 // response. The response body will be parsed further
 // downstream.
+//
+// Failed requests are retried with an exponential
+// backoff, if configured.
 func (c *Client) GetEndpoint(
 	ctx context.Context,
 	endpoint string,
 ) (*http.Response, error) {
-	client := &http.Client{}
-	url := c.api + endpoint
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	endpoint, err := c.tableEndpoint(endpoint)
 	if err != nil {
 		return nil, err
 	}
+	for retry := 0; ; retry++ {
+		start := time.Now()
+		res, err := c.doRequest(ctx, endpoint)
+		c.observeRequest(endpoint, res, time.Since(start), err)
+		if retry >= c.opts.MaxRetries || !c.shouldRetry(res, err) {
+			return res, err
+		}
+		if ctx.Err() != nil {
+			return res, err
+		}
+		if res != nil {
+			res.Body.Close()
+		}
 
-	return client.Do(req)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.retryDelay(retry)):
+		}
+	}
 }
 
-// GetJSON makes an API request.
-// Parse JSON response and return map or error.
-func (c *Client) GetJSON(
+// GetEndpointf makes an API request like GetEndpoint to
+// the endpoint formatted with the arguments. The arguments
+// are escaped as path segments, so e.g. a neighbor ID with
+// a '/' or '?' can not change the path of the endpoint.
+func (c *Client) GetEndpointf(
 	ctx context.Context,
+	format string,
+	args ...any,
+) (*http.Response, error) {
+	return c.GetEndpoint(ctx, formatEndpoint(format, args...))
+}
+
+// formatEndpoint formats the endpoint with
+// the arguments escaped as path segments.
+func formatEndpoint(format string, args ...any) string {
+	escaped := make([]any, len(args))
+	for i, arg := range args {
+		escaped[i] = url.PathEscape(fmt.Sprint(arg))
+	}
+	return fmt.Sprintf(format, escaped...)
+}
+
+// setAuthorization adds the credentials to the request
+func (c *Client) setAuthorization(req *http.Request) {
+	if c.opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.BearerToken)
+		return
+	}
+	if c.opts.BasicAuthUser != "" {
+		req.SetBasicAuth(c.opts.BasicAuthUser, c.opts.BasicAuthPass)
+	}
+}
+
+// cancelBody releases the context of the
+// request when the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the context
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// observeRequest invokes the ObserveRequest callback
+func (c *Client) observeRequest(
 	endpoint string,
-) (ClientResponse, error) {
-	res, err := c.GetEndpoint(ctx, endpoint)
+	res *http.Response,
+	dur time.Duration,
+	err error,
+) {
+	if c.opts.ObserveRequest == nil {
+		return
+	}
+	status := 0
+	if res != nil {
+		status = res.StatusCode
+	}
+	c.opts.ObserveRequest(endpoint, status, dur, err)
+}
+
+// doRequest makes a single request to the endpoint.
+// The timeout is applied if the context has no deadline.
+func (c *Client) doRequest(
+	ctx context.Context,
+	endpoint string,
+) (*http.Response, error) {
+	if _, ok := ctx.Deadline(); ok || c.opts.Timeout <= 0 {
+		return c.do(ctx, endpoint)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	res, err := c.do(ctx, endpoint)
 	if err != nil {
-		return ClientResponse{}, err
+		cancel()
+		return nil, err
+	}
+	res.Body = &cancelBody{
+		ReadCloser: res.Body,
+		cancel:     cancel,
+	}
+	return res, nil
+}
+
+// do makes the request
+func (c *Client) do(
+	ctx context.Context,
+	endpoint string,
+) (*http.Response, error) {
+	reqURL := c.api + endpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range c.opts.Headers {
+		req.Header.Set(key, value)
+	}
+	if c.opts.Host != "" {
+		req.Host = c.opts.Host
 	}
+	c.setAuthorization(req)
 
-	// Read body
+	// Request a compressed response. As the encoding is set
+	// explicitly, the response must be decompressed here.
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	res, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err = decompressResponse(res)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.MaxResponseBytes > 0 {
+		res.Body = http.MaxBytesReader(nil, res.Body, c.opts.MaxResponseBytes)
+	}
+	return res, nil
+}
+
+// apiErrorBodyMaxLen is the length of the response
+// body included in an APIError.
+const apiErrorBodyMaxLen = 512
+
+// APIError is returned if the API responds with an
+// error status or not with JSON, e.g. an HTML error page.
+type APIError struct {
+	StatusCode  int
+	ContentType string
+
+	// Body is the beginning of the response body
+	Body string
+}
+
+// Error implements the error interface
+func (err *APIError) Error() string {
+	return fmt.Sprintf(
+		"unexpected response from API: %d (%s): %s",
+		err.StatusCode, err.ContentType, err.Body)
+}
+
+// BirdwatcherError is returned if the API responds with
+// an error envelope instead of data, e.g. while bird is
+// reconfiguring. The request can be retried later.
+type BirdwatcherError struct {
+	Code    int
+	Message string
+}
+
+// Error implements the error interface
+func (err *BirdwatcherError) Error() string {
+	if err.Code == 0 {
+		return "birdwatcher error: " + err.Message
+	}
+	return fmt.Sprintf("birdwatcher error: %s (code %d)", err.Message, err.Code)
+}
+
+// checkErrorEnvelope returns a BirdwatcherError if the
+// decoded response has a top level error or code.
+func checkErrorEnvelope(res ClientResponse) error {
+	message := decoders.String(res["error"], "")
+	code, hasCode := res["code"]
+	if message == "" && !hasCode {
+		return nil
+	}
+	return &BirdwatcherError{
+		Code:    decoders.Int(code, 0),
+		Message: message,
+	}
+}
+
+// checkResponse returns an APIError if the response
+// is not successful or not JSON.
+func checkResponse(res *http.Response) error {
+	contentType := res.Header.Get("Content-Type")
+	success := res.StatusCode >= 200 && res.StatusCode < 300
+	if success && isJSONContentType(contentType) {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, apiErrorBodyMaxLen))
+	return &APIError{
+		StatusCode:  res.StatusCode,
+		ContentType: contentType,
+		Body:        string(body),
+	}
+}
+
+// isJSONContentType checks the media type of the
+// response. A missing content type is accepted.
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" ||
+		strings.HasSuffix(mediaType, "+json")
+}
+
+// decompressedBody closes the decompressor
+// and the response body.
+type decompressedBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+// Close closes the decompressor and the body
+func (b *decompressedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.body.Close()
+}
+
+// decompressResponse replaces the body of a gzip or
+// deflate encoded response with the decompressed body.
+func decompressResponse(res *http.Response) (*http.Response, error) {
+	var (
+		reader io.ReadCloser
+		err    error
+	)
+	switch strings.ToLower(res.Header.Get("Content-Encoding")) {
+	case "gzip":
+		reader, err = gzip.NewReader(res.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(res.Body)
+	default:
+		return res, nil
+	}
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	res.Body = &decompressedBody{
+		ReadCloser: reader,
+		body:       res.Body,
+	}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return res, nil
+}
+
+// getPayload retrieves the body of a successful
+// JSON response, from the cache if enabled.
+func (c *Client) getPayload(
+	ctx context.Context,
+	endpoint string,
+) ([]byte, error) {
+	if c.cache == nil {
+		return c.readPayload(ctx, endpoint)
+	}
+	return c.cache.get(ctx, endpoint, func() ([]byte, error) {
+		return c.readPayload(ctx, endpoint)
+	})
+}
+
+// readPayload makes an API request and reads the
+// body of a successful JSON response.
+func (c *Client) readPayload(
+	ctx context.Context,
+	endpoint string,
+) ([]byte, error) {
+	res, err := c.GetEndpoint(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
 	defer res.Body.Close()
-	payload, err := io.ReadAll(res.Body)
+	if err := checkResponse(res); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(res.Body)
+}
+
+// GetJSON makes an API request.
+// Parse JSON response and return map or error.
+// An error envelope is returned as a *BirdwatcherError.
+func (c *Client) GetJSON(
+	ctx context.Context,
+	endpoint string,
+) (ClientResponse, error) {
+	payload, err := c.getPayload(ctx, endpoint)
 	if err != nil {
 		return ClientResponse{}, err
 	}
@@ -71,5 +556,56 @@ func (c *Client) GetJSON(
 	if err != nil {
 		return ClientResponse{}, err
 	}
+	if err := checkErrorEnvelope(result); err != nil {
+		return ClientResponse{}, err
+	}
 	return result, nil
 }
+
+// GetJSONInto makes an API request and decodes the
+// JSON response into v. Unlike GetJSON, the response
+// is decoded while reading, without buffering the body.
+// If caching is enabled, the cached payload is decoded.
+func (c *Client) GetJSONInto(
+	ctx context.Context,
+	endpoint string,
+	v any,
+) error {
+	if c.cache != nil {
+		payload, err := c.getPayload(ctx, endpoint)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(payload, v)
+	}
+
+	res, err := c.GetEndpoint(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := checkResponse(res); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Ping checks if the API is reachable and responds
+// with the birdwatcher status. The configured timeout
+// is applied, if the context has no deadline.
+// The response cache is bypassed.
+func (c *Client) Ping(ctx context.Context) error {
+	payload, err := c.readPayload(ctx, "/status")
+	if err != nil {
+		return err
+	}
+	res := make(ClientResponse)
+	if err := json.Unmarshal(payload, &res); err != nil {
+		return err
+	}
+	if _, ok := res["status"].(map[string]any); !ok {
+		return ErrPingNoStatus
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package birdwatcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// responseCache collapses concurrent requests of an
+// endpoint into a single upstream request and keeps
+// the payload of the response for a short time.
+//
+// Expired entries are released when looked up and
+// swept at most once per TTL on access.
+type responseCache struct {
+	ttl       time.Duration
+	entries   map[string]*responseCacheEntry
+	nextSweep time.Time
+	sync.Mutex
+}
+
+// responseCacheEntry is the payload of a request,
+// available when done is closed.
+type responseCacheEntry struct {
+	done    chan struct{}
+	payload []byte
+	err     error
+	expires time.Time
+}
+
+// newResponseCache creates a new response cache
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[string]*responseCacheEntry),
+	}
+}
+
+// get retrieves the payload of the endpoint from the cache.
+// If there is no valid entry, the payload is fetched. Callers
+// requesting the endpoint in the meantime wait for the
+// result, so the request is made with the context of the
+// first caller. Errors are not cached.
+func (c *responseCache) get(
+	ctx context.Context,
+	endpoint string,
+	fetch func() ([]byte, error),
+) ([]byte, error) {
+	now := time.Now()
+	c.Lock()
+	if now.After(c.nextSweep) {
+		c.sweep(now)
+	}
+	entry, ok := c.entries[endpoint]
+	if ok && entry.isExpired(now) {
+		delete(c.entries, endpoint)
+		ok = false
+	}
+	if ok {
+		c.Unlock()
+		select {
+		case <-entry.done:
+			return entry.payload, entry.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	entry = &responseCacheEntry{
+		done: make(chan struct{}),
+	}
+	c.entries[endpoint] = entry
+	c.Unlock()
+
+	entry.payload, entry.err = fetch()
+	entry.expires = time.Now().Add(c.ttl)
+	if entry.err != nil {
+		c.Lock()
+		if c.entries[endpoint] == entry {
+			delete(c.entries, endpoint)
+		}
+		c.Unlock()
+	}
+	close(entry.done)
+
+	return entry.payload, entry.err
+}
+
+// sweep removes all expired entries from the cache.
+// The cache must be locked.
+func (c *responseCache) sweep(now time.Time) {
+	for endpoint, entry := range c.entries {
+		if entry.isExpired(now) {
+			delete(c.entries, endpoint)
+		}
+	}
+	c.nextSweep = now.Add(c.ttl)
+}
+
+// isExpired checks if the entry is done and
+// the TTL has passed. Entries of requests in
+// flight never expire.
+func (e *responseCacheEntry) isExpired(now time.Time) bool {
+	select {
+	case <-e.done:
+		return now.After(e.expires)
+	default:
+		return false
+	}
+}
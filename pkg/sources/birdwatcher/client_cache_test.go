@@ -0,0 +1,144 @@
+package birdwatcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientCacheConcurrentRequests(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			writeJSON(w, `{"status": {"version": "2.0.0"}}`)
+		}))
+	defer srv.Close()
+
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		CacheTTL: time.Minute,
+	})
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.GetJSON(context.Background(), "/status")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if res["status"] == nil {
+				t.Error("unexpected response:", res)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Error("expected a single upstream request, got:", n)
+	}
+
+	// Other endpoints are requested
+	if _, err := client.GetJSON(context.Background(), "/protocols"); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Error("expected a request for another endpoint, got:", n)
+	}
+}
+
+func TestClientCacheExpire(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(w, `{}`)
+		}))
+	defer srv.Close()
+
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		CacheTTL: 50 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	// Errors are not cached
+	if _, err := client.GetJSON(ctx, "/status"); err == nil {
+		t.Error("expected error")
+	}
+	for range 2 {
+		if _, err := client.GetJSON(ctx, "/status"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Error("expected 2 requests, got:", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := client.GetJSON(ctx, "/status"); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Error("expected request after expiry, got:", n)
+	}
+}
+
+func TestResponseCacheRelease(t *testing.T) {
+	cache := newResponseCache(50 * time.Millisecond)
+	ctx := context.Background()
+	fetch := func() ([]byte, error) {
+		return []byte("{}"), nil
+	}
+
+	for _, endpoint := range []string{"/routes/1", "/routes/2"} {
+		if _, err := cache.get(ctx, endpoint, fetch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cache.entries) != 2 {
+		t.Fatal("expected 2 entries, got:", len(cache.entries))
+	}
+
+	// Requesting any endpoint after expiry
+	// releases all expired entries.
+	time.Sleep(60 * time.Millisecond)
+	if _, err := cache.get(ctx, "/status", fetch); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.entries) != 1 {
+		t.Error("expected expired entries to be released, got:",
+			len(cache.entries))
+	}
+	if _, ok := cache.entries["/routes/1"]; ok {
+		t.Error("expected /routes/1 to be released")
+	}
+}
+
+func TestClientCacheDisabled(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			writeJSON(w, `{}`)
+		}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	for range 3 {
+		if _, err := client.GetJSON(context.Background(), "/status"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := requests.Load(); n != 3 {
+		t.Error("expected 3 requests, got:", n)
+	}
+}
@@ -0,0 +1,598 @@
+package birdwatcher
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeJSON(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(body))
+}
+
+func TestClientRetry(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(w, `{"status": "ok"}`)
+		}))
+	defer srv.Close()
+
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		MaxRetries: 3,
+		BaseDelay:  time.Millisecond,
+	})
+	res, err := client.GetJSON(context.Background(), "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["status"] != "ok" {
+		t.Error("unexpected response:", res)
+	}
+	if requests != 3 {
+		t.Error("expected 3 requests, got:", requests)
+	}
+}
+
+func TestClientRetryFailFast(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+	defer srv.Close()
+
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		MaxRetries: 3,
+		BaseDelay:  time.Millisecond,
+	})
+	res, err := client.GetEndpoint(context.Background(), "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Error("unexpected status:", res.StatusCode)
+	}
+	if requests != 1 {
+		t.Error("expected a single request, got:", requests)
+	}
+}
+
+func TestClientRetryCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+	defer srv.Close()
+
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		MaxRetries: 10,
+		BaseDelay:  time.Hour,
+	})
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.GetEndpoint(ctx, "/status")
+	if err != context.DeadlineExceeded {
+		t.Error("expected deadline exceeded, got:", err)
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientWithHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, `{}`)
+		}))
+	defer srv.Close()
+
+	transport := &countingTransport{}
+	client := NewClientWithHTTPClient(srv.URL, &http.Client{
+		Transport: transport,
+	})
+	for range 2 {
+		if _, err := client.GetJSON(context.Background(), "/status"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if transport.requests != 2 {
+		t.Error("expected requests using the http client, got:",
+			transport.requests)
+	}
+
+	if NewClient(srv.URL).opts.HTTPClient != defaultHTTPClient {
+		t.Error("expected default client to be shared")
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/body" {
+				writeJSON(w, `{"status": `)
+				w.(http.Flusher).Flush()
+			}
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+	defer srv.Close()
+
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		Timeout: 50 * time.Millisecond,
+	})
+
+	for _, endpoint := range []string{"/status", "/body"} {
+		start := time.Now()
+		_, err := client.GetJSON(context.Background(), endpoint)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Error("expected deadline exceeded, got:", err)
+		}
+		if time.Since(start) > time.Second {
+			t.Error("timeout was not applied for", endpoint)
+		}
+	}
+}
+
+func TestClientAuthorization(t *testing.T) {
+	auth := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			auth = append(auth, r.Header.Get("Authorization"))
+			if len(auth) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(w, `{}`)
+		}))
+	defer srv.Close()
+
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		MaxRetries:    1,
+		BasicAuthUser: "alice",
+		BasicAuthPass: "secret",
+	})
+	if _, err := client.GetJSON(context.Background(), "/status"); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range auth {
+		if a != "Basic YWxpY2U6c2VjcmV0" {
+			t.Error("unexpected authorization:", a)
+		}
+	}
+	if len(auth) != 2 {
+		t.Error("expected authorization on retry")
+	}
+
+	auth = []string{}
+	client = NewClientWithOptions(srv.URL, ClientOptions{
+		BasicAuthUser: "alice",
+		BasicAuthPass: "secret",
+		BearerToken:   "t0k3n",
+	})
+	client.GetJSON(context.Background(), "/status")
+	if auth[0] != "Bearer t0k3n" {
+		t.Error("expected bearer token to take precedence, got:", auth[0])
+	}
+}
+
+func TestClientHeaders(t *testing.T) {
+	var (
+		apiKey string
+		host   string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			apiKey = r.Header.Get("X-Api-Key")
+			host = r.Host
+			writeJSON(w, `{}`)
+		}))
+	defer srv.Close()
+
+	headers := map[string]string{"X-Api-Key": "k3y"}
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		Headers: headers,
+		Host:    "rs1.example.net",
+	})
+	headers["X-Api-Key"] = "changed"
+
+	if _, err := client.GetJSON(context.Background(), "/status"); err != nil {
+		t.Fatal(err)
+	}
+	if apiKey != "k3y" {
+		t.Error("unexpected api key:", apiKey)
+	}
+	if host != "rs1.example.net" {
+		t.Error("unexpected host:", host)
+	}
+}
+
+func TestClientGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				t.Error("expected gzip to be accepted")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte(`{"routes": [{"network": "10.0.0.0/8"}]}`))
+			gz.Close()
+		}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	res, err := client.GetJSON(context.Background(), "/routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, ok := res["routes"].([]any)
+	if !ok || len(routes) != 1 {
+		t.Error("unexpected response:", res)
+	}
+}
+
+func TestClientGetJSONInto(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, `{"api": {"version": "2.0.0"}}`)
+		}))
+	defer srv.Close()
+
+	res := struct {
+		API struct {
+			Version string `json:"version"`
+		} `json:"api"`
+	}{}
+	client := NewClient(srv.URL)
+	if err := client.GetJSONInto(context.Background(), "/status", &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.API.Version != "2.0.0" {
+		t.Error("unexpected version:", res.API.Version)
+	}
+}
+
+type benchmarkRoutesResponse struct {
+	Routes []struct {
+		Network string `json:"network"`
+		Gateway string `json:"gateway"`
+		BGP     struct {
+			ASPath      []int   `json:"as_path"`
+			Communities [][]int `json:"communities"`
+		} `json:"bgp"`
+	} `json:"routes"`
+}
+
+func makeBenchmarkRoutesServer(n int) *httptest.Server {
+	routes := make([]string, n)
+	for i := range routes {
+		routes[i] = fmt.Sprintf(`{
+			"network": "10.%d.%d.0/24",
+			"gateway": "192.168.0.1",
+			"bgp": {
+				"as_path": [64500, 64501, 64502],
+				"communities": [[64500, 1], [64500, 2]]
+			}
+		}`, i/256%256, i%256)
+	}
+	body := []byte(`{"routes": [` + strings.Join(routes, ",") + `]}`)
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(body)
+		}))
+}
+
+func BenchmarkClientGetJSON(b *testing.B) {
+	srv := makeBenchmarkRoutesServer(10000)
+	defer srv.Close()
+	client := NewClient(srv.URL)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := client.GetJSON(context.Background(), "/routes"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClientGetJSONInto(b *testing.B) {
+	srv := makeBenchmarkRoutesServer(10000)
+	defer srv.Close()
+	client := NewClient(srv.URL)
+	b.ReportAllocs()
+	for b.Loop() {
+		res := benchmarkRoutesResponse{}
+		if err := client.GetJSONInto(context.Background(), "/routes", &res); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			if r.URL.Path == "/error" {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			w.Write([]byte("<html><body>" +
+				strings.Repeat("Internal Server Error ", 100) +
+				"</body></html>"))
+		}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	for _, endpoint := range []string{"/error", "/status"} {
+		_, err := client.GetJSON(context.Background(), endpoint)
+		var errAPI *APIError
+		if !errors.As(err, &errAPI) {
+			t.Fatal("expected api error, got:", err)
+		}
+		if !strings.HasPrefix(errAPI.Body, "<html>") {
+			t.Error("unexpected body:", errAPI.Body)
+		}
+		if len(errAPI.Body) > apiErrorBodyMaxLen {
+			t.Error("expected body to be truncated")
+		}
+	}
+}
+
+func TestClientMaxResponseBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, `{"routes": [`+
+				strings.Repeat(`{"network": "10.0.0.0/8"},`, 10000)+
+				`{}]}`)
+		}))
+	defer srv.Close()
+
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		MaxResponseBytes: 1024,
+	})
+	_, err := client.GetJSON(context.Background(), "/routes")
+	var errMaxBytes *http.MaxBytesError
+	if !errors.As(err, &errMaxBytes) {
+		t.Error("expected max bytes error, got:", err)
+	}
+}
+
+func TestClientObserveRequest(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			writeJSON(w, `{}`)
+		}))
+	defer srv.Close()
+
+	observed := []int{}
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		MaxRetries: 1,
+		ObserveRequest: func(
+			endpoint string, status int, dur time.Duration, err error,
+		) {
+			if endpoint != "/status" {
+				t.Error("unexpected endpoint:", endpoint)
+			}
+			observed = append(observed, status)
+		},
+	})
+	if _, err := client.GetJSON(context.Background(), "/status"); err != nil {
+		t.Fatal(err)
+	}
+	if len(observed) != 2 ||
+		observed[0] != http.StatusBadGateway ||
+		observed[1] != http.StatusOK {
+		t.Error("unexpected observed requests:", observed)
+	}
+
+	// Errors are observed
+	srv.Close()
+	observed = []int{}
+	var errObserved error
+	client.opts.ObserveRequest = func(
+		endpoint string, status int, dur time.Duration, err error,
+	) {
+		observed = append(observed, status)
+		errObserved = err
+	}
+	client.opts.MaxRetries = 0
+	if _, err := client.GetJSON(context.Background(), "/status"); err == nil {
+		t.Error("expected error")
+	}
+	if len(observed) != 1 || observed[0] != 0 || errObserved == nil {
+		t.Error("expected error to be observed:", observed, errObserved)
+	}
+}
+
+func TestClientTable(t *testing.T) {
+	paths := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			writeJSON(w, `{}`)
+		}))
+	defer srv.Close()
+
+	client := NewClientWithOptions(srv.URL, ClientOptions{
+		Table: "master4",
+	})
+	for _, endpoint := range []string{
+		"/routes/table/{table}",
+		"/routes/table/{table}/filtered",
+		"/status",
+	} {
+		if _, err := client.GetJSON(context.Background(), endpoint); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		"/routes/table/master4",
+		"/routes/table/master4/filtered",
+		"/status",
+	}
+	if !slices.Equal(paths, expected) {
+		t.Error("unexpected paths:", paths)
+	}
+
+	// Without a table, the endpoints are not changed
+	client = NewClient(srv.URL)
+	if _, err := client.GetJSON(context.Background(), "/routes/table/master"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetJSON(context.Background(), "/routes/table/{table}"); !errors.Is(err, ErrNoTable) {
+		t.Error("expected no table error, got:", err)
+	}
+
+	paths = []string{}
+	for _, table := range []string{"master/../status", "../x", "t1?x=1", "t 1", "1t"} {
+		client = NewClientWithOptions(srv.URL, ClientOptions{Table: table})
+		_, err := client.GetJSON(context.Background(), "/routes/table/{table}")
+		if !errors.Is(err, ErrInvalidTable) {
+			t.Error("expected invalid table error for", table, "got:", err)
+		}
+	}
+	if len(paths) != 0 {
+		t.Error("expected no requests with invalid tables:", paths)
+	}
+}
+
+func TestClientPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch strings.TrimSuffix(r.URL.Path, "/status") {
+			case "/html":
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte("<html><body>It works!</body></html>"))
+			case "/empty":
+				writeJSON(w, `{"api": {}}`)
+			case "/slow":
+				<-r.Context().Done()
+			default:
+				writeJSON(w, `{"api": {}, "status": {"version": "2.0.0"}}`)
+			}
+		}))
+	defer srv.Close()
+
+	ping := func(path string, opts ClientOptions) error {
+		client := NewClientWithOptions(srv.URL+path, opts)
+		return client.Ping(context.Background())
+	}
+
+	if err := ping("", ClientOptions{}); err != nil {
+		t.Error("expected healthy server, got:", err)
+	}
+
+	var errAPI *APIError
+	if err := ping("/html", ClientOptions{}); !errors.As(err, &errAPI) {
+		t.Error("expected api error for html response, got:", err)
+	}
+
+	if err := ping("/empty", ClientOptions{}); !errors.Is(err, ErrPingNoStatus) {
+		t.Error("expected missing status error, got:", err)
+	}
+
+	err := ping("/slow", ClientOptions{Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected deadline exceeded, got:", err)
+	}
+}
+
+func TestClientGetEndpointf(t *testing.T) {
+	paths := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.EscapedPath())
+			if r.URL.RawQuery != "" {
+				t.Error("unexpected query:", r.URL.RawQuery)
+			}
+			writeJSON(w, `{}`)
+		}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	for _, id := range []string{"R192_168_0_1", "ID1.2:3", "ID1/../status?x=1"} {
+		res, err := client.GetEndpointf(
+			context.Background(), "/routes/protocol/%s", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	expected := []string{
+		"/routes/protocol/R192_168_0_1",
+		"/routes/protocol/ID1.2:3",
+		"/routes/protocol/ID1%2F..%2Fstatus%3Fx=1",
+	}
+	if !slices.Equal(paths, expected) {
+		t.Error("unexpected paths:", paths)
+	}
+}
+
+func TestClientErrorEnvelope(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/reconfigure":
+				writeJSON(w, `{"error": "bird is reconfiguring", "code": 503}`)
+			case "/message":
+				writeJSON(w, `{"error": "protocol not found"}`)
+			default:
+				writeJSON(w, `{"api": {}, "protocols": {}}`)
+			}
+		}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	_, err := client.GetJSON(context.Background(), "/reconfigure")
+	var errBird *BirdwatcherError
+	if !errors.As(err, &errBird) {
+		t.Fatal("expected birdwatcher error, got:", err)
+	}
+	if errBird.Code != 503 || errBird.Message != "bird is reconfiguring" {
+		t.Error("unexpected error:", errBird)
+	}
+
+	_, err = client.GetJSON(context.Background(), "/message")
+	if !errors.As(err, &errBird) || errBird.Code != 0 {
+		t.Error("expected birdwatcher error without code, got:", err)
+	}
+
+	res, err := client.GetJSON(context.Background(), "/protocols")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res["protocols"]; !ok {
+		t.Error("unexpected response:", res)
+	}
+}
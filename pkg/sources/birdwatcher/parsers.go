@@ -185,44 +185,43 @@ func parseRoutesChannels(protocol map[string]any) map[string]*api.RoutesChannel
 	return routesChannels
 }
 
-// Parse neighbors response
+// Parse neighbors response. Protocols of an unexpected
+// type are skipped. An empty list is returned if there
+// are no protocols.
 func parseNeighbors(bird ClientResponse, config Config) (api.Neighbors, error) {
 	rsID := config.ID
-	neighbors := api.Neighbors{}
-	protocols := bird["protocols"].(map[string]any)
+	protocols := decoders.MapGetMap(map[string]any(bird), "protocols", nil)
+	neighbors := make(api.Neighbors, 0, len(protocols))
 
 	// Iterate over protocols map:
 	for protocolID, proto := range protocols {
-		protocol := proto.(map[string]any)
-		routes := protocol["routes"].(map[string]any)
+		protocol, ok := proto.(map[string]any)
+		if !ok {
+			continue
+		}
+		routes := decoders.MapGetMap(protocol, "routes", nil)
 
 		uptime := parseRelativeServerTime(protocol["state_changed"], config)
-		lastError := decoders.String(protocol["last_error"], "")
-
-		routesReceived := float64(0)
-		if routes != nil {
-			if _, ok := routes["imported"]; ok {
-				routesReceived = routesReceived + routes["imported"].(float64)
-			}
-			if _, ok := routes["filtered"]; ok {
-				routesReceived = routesReceived + routes["filtered"].(float64)
-			}
-		}
+		lastError := decoders.MapGetString(protocol, "last_error", "")
+
+		imported := decoders.MapGetInt(routes, "imported", 0)
+		filtered := decoders.MapGetInt(routes, "filtered", 0)
 
 		neighbor := &api.Neighbor{
 			ID: protocolID,
 
-			Address: decoders.String(protocol["neighbor_address"], "error"),
-			ASN:     decoders.Int(protocol["neighbor_as"], 0),
+			Address: decoders.MapGetString(protocol, "neighbor_address", "error"),
+			ASN:     decoders.MapGetInt(protocol, "neighbor_as", 0),
 			State: strings.ToLower(
-				decoders.String(protocol["state"], "unknown")),
-			Description: decoders.String(protocol["description"], "no description"),
-
-			RoutesReceived:  decoders.Int(routesReceived, 0),
-			RoutesAccepted:  decoders.Int(routes["imported"], 0),
-			RoutesFiltered:  decoders.Int(routes["filtered"], 0),
-			RoutesExported:  decoders.Int(routes["exported"], 0), //TODO protocol_exported?
-			RoutesPreferred: decoders.Int(routes["preferred"], 0),
+				decoders.MapGetString(protocol, "state", "unknown")),
+			Description: decoders.MapGetString(
+				protocol, "description", "no description"),
+
+			RoutesReceived:  imported + filtered,
+			RoutesAccepted:  imported,
+			RoutesFiltered:  filtered,
+			RoutesExported:  decoders.MapGetInt(routes, "exported", 0), //TODO protocol_exported?
+			RoutesPreferred: decoders.MapGetInt(routes, "preferred", 0),
 
 			RoutesChannels: parseRoutesChannels(protocol),
 
@@ -93,6 +93,24 @@ func Test_NeighborsParsing(t *testing.T) {
 	}
 }
 
+func Test_NeighborsParsingEmpty(t *testing.T) {
+	config := Config{Timezone: "UTC"}
+	for _, payload := range []string{
+		`{"protocols": {}}`,
+		`{"api": {}}`,
+		`{"protocols": {"p1": "unexpected"}}`,
+	} {
+		bird, _ := parseTestResponse(payload)
+		neighbors, err := parseNeighbors(bird, config)
+		if err != nil {
+			t.Error(err)
+		}
+		if neighbors == nil || len(neighbors) != 0 {
+			t.Error("expected empty neighbors for:", payload)
+		}
+	}
+}
+
 func Test_RoutesParsing(t *testing.T) {
 	config := Config{Timezone: "UTC"} // Or ""
 	bird, _ := parseTestResponse(APIResponseRoutes)
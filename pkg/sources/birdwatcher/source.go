@@ -205,6 +205,36 @@ func (b *GenericBirdwatcher) fetchProtocolsShort(ctx context.Context) (
 	return apiStatus, bird, nil
 }
 
+// GetNeighbors retrieves the BGP protocols from the
+// birdwatcher API and decodes them into neighbors.
+// Without any protocols, an empty list is returned.
+func (b *GenericBirdwatcher) GetNeighbors(
+	ctx context.Context,
+) ([]*api.Neighbor, error) {
+	_, neighbors, err := b.getNeighbors(ctx)
+	return neighbors, err
+}
+
+// getNeighbors retrieves the BGP protocols like
+// GetNeighbors, including the api status.
+func (b *GenericBirdwatcher) getNeighbors(
+	ctx context.Context,
+) (*api.Meta, []*api.Neighbor, error) {
+	bird, err := b.client.GetJSON(ctx, "/protocols/bgp")
+	if err != nil {
+		return nil, nil, err
+	}
+	apiStatus, err := parseAPIStatus(bird, b.config)
+	if err != nil {
+		return nil, nil, err
+	}
+	neighbors, err := parseNeighbors(bird, b.config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return apiStatus, neighbors, nil
+}
+
 // ExpireCaches clears all local caches
 func (b *GenericBirdwatcher) ExpireCaches() int {
 	count := b.routesRequiredCache.Expire()
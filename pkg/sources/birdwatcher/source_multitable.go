@@ -120,9 +120,9 @@ func (src *MultiTableBirdwatcher) fetchReceivedRoutes(
 	table := protocols[neighborID].(map[string]any)["table"].(string)
 	pipe := src.getMasterPipeName(table)
 
-	qryURL := "/routes/peer/" + peer
+	qryURL := formatEndpoint("/routes/peer/%s", peer)
 	if src.isAltSession(pipe) || src.config.PeerTableOnly {
-		qryURL = "/routes/table/" + table + "/peer/" + peer
+		qryURL = formatEndpoint("/routes/table/%s/peer/%s", table, peer)
 	}
 
 	res, err := src.client.GetEndpoint(ctx, qryURL)
@@ -213,7 +213,7 @@ func (src *MultiTableBirdwatcher) fetchFilteredRoutes(
 	}
 
 	// Stage 1 filters
-	res, err := src.client.GetEndpoint(ctx, "/routes/filtered/"+neighborID)
+	res, err := src.client.GetEndpointf(ctx, "/routes/filtered/%s", neighborID)
 	if err != nil {
 		log.Println("WARNING Could not retrieve filtered routes:", err)
 		log.Println("Is the 'routes_filtered' module active in birdwatcher?")
@@ -295,8 +295,7 @@ func (src *MultiTableBirdwatcher) fetchNotExportedRoutes(
 	}
 
 	// Query birdwatcher
-	qryURL := "/routes/noexport/" + pipeName
-	res, err := src.client.GetEndpoint(ctx, qryURL)
+	res, err := src.client.GetEndpointf(ctx, "/routes/noexport/%s", pipeName)
 	if err != nil {
 		log.Println("WARNING Could not retrieve routes not exported:", err)
 		log.Println("Is the 'routes_noexport' module active in birdwatcher?")
@@ -587,19 +586,7 @@ func (src *MultiTableBirdwatcher) fetchNeighbors(
 	ctx context.Context,
 ) (*api.NeighborsResponse, error) {
 	// Query birdwatcher
-	bird, err := src.client.GetJSON(ctx, "/protocols/bgp")
-	if err != nil {
-		return nil, err
-	}
-
-	// Use api status from first request
-	apiStatus, err := parseAPIStatus(bird, src.config)
-	if err != nil {
-		return nil, err
-	}
-
-	// Parse the neighbors
-	neighbors, err := parseNeighbors(bird, src.config)
+	apiStatus, neighbors, err := src.getNeighbors(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -872,7 +859,7 @@ func (src *MultiTableBirdwatcher) AllRoutes(
 	mainTable := src.GenericBirdwatcher.config.MainTable
 
 	// Fetch received routes first
-	res, err := src.client.GetEndpoint(ctx, "/routes/table/"+mainTable)
+	res, err := src.client.GetEndpointf(ctx, "/routes/table/%s", mainTable)
 	if err != nil {
 		return nil, err
 	}
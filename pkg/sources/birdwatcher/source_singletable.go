@@ -16,7 +16,7 @@ func (src *SingleTableBirdwatcher) fetchReceivedRoutes(
 	ctx context.Context,
 	neighborID string,
 ) (*api.Meta, api.Routes, error) {
-	res, err := src.client.GetEndpoint(ctx, "/routes/protocol/"+neighborID)
+	res, err := src.client.GetEndpointf(ctx, "/routes/protocol/%s", neighborID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -34,7 +34,7 @@ func (src *SingleTableBirdwatcher) fetchFilteredRoutes(
 	ctx context.Context,
 	neighborID string,
 ) (*api.Meta, api.Routes, error) {
-	res, err := src.client.GetEndpoint(ctx, "/routes/filtered/"+neighborID)
+	res, err := src.client.GetEndpointf(ctx, "/routes/filtered/%s", neighborID)
 	if err != nil {
 		log.Println("WARNING Could not retrieve filtered routes:", err)
 		log.Println("Is the 'routes_filtered' module active in birdwatcher?")
@@ -54,7 +54,7 @@ func (src *SingleTableBirdwatcher) fetchNotExportedRoutes(
 	ctx context.Context,
 	neighborID string,
 ) (*api.Meta, api.Routes, error) {
-	res, err := src.client.GetEndpoint(ctx, "/routes/noexport/"+neighborID)
+	res, err := src.client.GetEndpointf(ctx, "/routes/noexport/%s", neighborID)
 	if err != nil {
 		log.Println("WARNING Could not retrieve routes not exported:", err)
 		log.Println("Is the 'routes_noexport' module active in birdwatcher?")
@@ -142,19 +142,7 @@ func (src *SingleTableBirdwatcher) Neighbors(
 	}
 
 	// Query birdwatcher
-	bird, err := src.client.GetJSON(ctx, "/protocols/bgp")
-	if err != nil {
-		return nil, err
-	}
-
-	// Use api status from first request
-	apiStatus, err := parseAPIStatus(bird, src.config)
-	if err != nil {
-		return nil, err
-	}
-
-	// Parse the neighbors
-	neighbors, err := parseNeighbors(bird, src.config)
+	apiStatus, neighbors, err := src.getNeighbors(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -297,7 +285,7 @@ func (src *SingleTableBirdwatcher) fetchAllRoutesTable(
 	mainTable string,
 ) (*api.RoutesResponse, error) {
 	// Routes received
-	res, err := src.client.GetEndpoint(ctx, "/routes/table/"+mainTable)
+	res, err := src.client.GetEndpointf(ctx, "/routes/table/%s", mainTable)
 	if err != nil {
 		return nil, err
 	}
@@ -309,7 +297,7 @@ func (src *SingleTableBirdwatcher) fetchAllRoutesTable(
 	}
 
 	// Routes filtered
-	res, err = src.client.GetEndpoint(ctx, "/routes/table/"+mainTable+"/filtered")
+	res, err = src.client.GetEndpointf(ctx, "/routes/table/%s/filtered", mainTable)
 	if err != nil {
 		return nil, err
 	}
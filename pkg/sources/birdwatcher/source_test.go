@@ -0,0 +1,56 @@
+package birdwatcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetNeighbors(t *testing.T) {
+	payload := APIResponseNeighbors
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/protocols/bgp" {
+				t.Error("unexpected path:", r.URL.Path)
+			}
+			writeJSON(w, payload)
+		}))
+	defer srv.Close()
+
+	src := &GenericBirdwatcher{
+		config: Config{
+			ID:         "rs1",
+			Timezone:   "UTC",
+			ServerTime: "2006-01-02T15:04:05.999999999Z07:00",
+		},
+		client: NewClient(srv.URL),
+	}
+	neighbors, err := src.GetNeighbors(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(neighbors) != 2 {
+		t.Fatal("expected 2 neighbors, got:", len(neighbors))
+	}
+	n := neighbors[0]
+	if n.ASN != 25074 || n.RouteServerID != "rs1" {
+		t.Error("unexpected neighbor:", n)
+	}
+	if n.RoutesReceived != 139 || n.RoutesAccepted != 135 {
+		t.Error("unexpected route counts:", n.RoutesReceived, n.RoutesAccepted)
+	}
+
+	payload = `{"api": {"Version": "2.0.0", "result_from_cache": false},
+		"protocols": {}, "ttl": "2017-05-22T08:34:04Z"}`
+	status, neighbors, err := src.getNeighbors(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != "2.0.0" {
+		t.Error("unexpected api status:", status)
+	}
+	if neighbors == nil || len(neighbors) != 0 {
+		t.Error("expected empty neighbors, got:", neighbors)
+	}
+}
@@ -364,8 +364,10 @@ func (s *NeighborsStore) Status(ctx context.Context) *api.StoreStatus {
 		status[s.SourceID] = &api.SourceStatus{
 			RefreshInterval: s.RefreshInterval,
 			LastRefresh:     s.LastRefresh,
-			State:           s.State.String(),
+			State:           api.SourceState(s.State.String()),
 			Initialized:     s.Initialized,
+			LastError:       s.LastErrorMessage(),
+			ErrorCount:      s.ErrorCount,
 		}
 	}
 
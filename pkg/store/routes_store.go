@@ -207,8 +207,10 @@ func (s *RoutesStore) updateSource(
 
 	// Prepare imported routes for lookup
 	srcRS := &api.LookupRouteServer{
-		ID:   pools.RouteServers.Acquire(src.ID),
-		Name: src.Name,
+		ID:            pools.RouteServers.Acquire(src.ID),
+		Name:          src.Name,
+		SelfAddresses: src.SelfAddressIPs,
+		Blackholes:    src.BlackholeIPs,
 	}
 	imported := res.Imported.ToLookupRoutes("imported", srcRS, neighbors)
 	filtered := res.Filtered.ToLookupRoutes("filtered", srcRS, neighbors)
@@ -255,8 +257,10 @@ func (s *RoutesStore) Status(ctx context.Context) *api.StoreStatus {
 		status[s.SourceID] = &api.SourceStatus{
 			RefreshInterval: s.RefreshInterval,
 			LastRefresh:     s.LastRefresh,
-			State:           s.State.String(),
+			State:           api.SourceState(s.State.String()),
 			Initialized:     s.Initialized,
+			LastError:       s.LastErrorMessage(),
+			ErrorCount:      s.ErrorCount,
 		}
 	}
 
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"sort"
 	"sync"
@@ -50,6 +51,7 @@ type Status struct {
 	LastRefresh         time.Time     `json:"last_refresh"`
 	LastRefreshDuration time.Duration `json:"-"`
 	LastError           any           `json:"-"`
+	ErrorCount          int           `json:"-"`
 	State               State         `json:"state"`
 	Initialized         bool          `json:"initialized"`
 	SourceID            string        `json:"source_id"`
@@ -57,6 +59,15 @@ type Status struct {
 	lastRefreshStart time.Time
 }
 
+// LastErrorMessage returns the last refresh error
+// as a string or an empty string if there is none.
+func (s *Status) LastErrorMessage() string {
+	if s.LastError == nil {
+		return ""
+	}
+	return fmt.Sprint(s.LastError)
+}
+
 // SourceStatusList is a sortable list of source status
 type SourceStatusList []*Status
 
@@ -313,6 +324,7 @@ func (s *SourcesStore) RefreshSuccess(sourceID string) error {
 	status.LastRefresh = time.Now().UTC()
 	status.LastRefreshDuration = time.Since(status.lastRefreshStart)
 	status.LastError = nil
+	status.ErrorCount = 0
 	status.Initialized = true // We now have data
 	return nil
 }
@@ -333,4 +345,5 @@ func (s *SourcesStore) RefreshError(
 	status.LastRefresh = time.Now().UTC()
 	status.LastRefreshDuration = time.Since(status.lastRefreshStart)
 	status.LastError = sourceErr
+	status.ErrorCount++
 }
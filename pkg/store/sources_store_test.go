@@ -1,6 +1,7 @@
 package store
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -102,3 +103,37 @@ func TestGetSourceIDsForRefreshParallel(t *testing.T) {
 		t.Error("expected src3 to be least refreshed")
 	}
 }
+
+func TestRefreshErrorCount(t *testing.T) {
+	s := &SourcesStore{
+		status: map[string]*Status{
+			"src1": {
+				SourceID: "src1",
+			},
+		},
+	}
+
+	s.RefreshError("src1", errors.New("connection refused"))
+	s.RefreshError("src1", errors.New("connection refused"))
+
+	status, err := s.GetStatus("src1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ErrorCount != 2 {
+		t.Error("expected 2 errors, got:", status.ErrorCount)
+	}
+	if status.LastErrorMessage() != "connection refused" {
+		t.Error("unexpected last error:", status.LastErrorMessage())
+	}
+
+	if err := s.RefreshSuccess("src1"); err != nil {
+		t.Fatal(err)
+	}
+	if status.ErrorCount != 0 {
+		t.Error("expected error count to be reset")
+	}
+	if status.LastErrorMessage() != "" {
+		t.Error("expected last error to be cleared")
+	}
+}